@@ -64,6 +64,10 @@ func (d *Driver) GetSSHKeyPath() string {
 	return ""
 }
 
+func (d *Driver) GetSSHKnownHostsPath() string {
+	return ""
+}
+
 func (d *Driver) GetSSHPort() (int, error) {
 	return 0, nil
 }
@@ -77,6 +81,7 @@ func (d *Driver) GetState() (state.State, error) {
 }
 
 func (d *Driver) Create() error {
+	d.MockState = state.Running
 	return nil
 }
 
@@ -0,0 +1,19 @@
+package none
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Config{
+		New: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		Flags: map[string]interface{}{
+			"url": "tcp://1.2.3.4:2376",
+		},
+	})
+}
@@ -0,0 +1,96 @@
+package virtualbox
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+const noSnapshots = "does not have any snapshots"
+
+// TakeSnapshot saves the state of the VM with `VBoxManage snapshot take`.
+func (d *Driver) TakeSnapshot(name, description string) error {
+	args := []string{"snapshot", d.MachineName, "take", name}
+	if description != "" {
+		args = append(args, "--description", description)
+	}
+	return d.vbm(args...)
+}
+
+// ListSnapshots returns the snapshots of the VM, parents first.
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return listSnapshots(d.MachineName, d.VBoxManager)
+}
+
+// RestoreSnapshot brings the VM back to a snapshot. VirtualBox refuses to do
+// so while the VM is running.
+func (d *Driver) RestoreSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "restore", name)
+}
+
+// DeleteSnapshot removes a snapshot of the VM.
+func (d *Driver) DeleteSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "delete", name)
+}
+
+// listSnapshots parses the snapshot tree printed by `VBoxManage snapshot list
+// --machinereadable`, where the keys of each snapshot share a suffix telling
+// its position in the tree, e.g. SnapshotName-1-1 for the first child of the
+// second root.
+func listSnapshots(name string, vbox VBoxManager) ([]drivers.Snapshot, error) {
+	stdOut, err := vbox.vbmOut("snapshot", name, "list", "--machinereadable")
+	if err != nil {
+		// VBoxManage fails when there is nothing to list
+		if strings.Contains(stdOut, noSnapshots) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	snapshots := []drivers.Snapshot{}
+	bySuffix := map[string]int{}
+	currentSuffix := ""
+
+	err = parseKeyValues(stdOut, reEqualLine, func(key, val string) error {
+		val = strings.Trim(val, `"`)
+
+		if key == "CurrentSnapshotNode" {
+			currentSuffix = strings.TrimPrefix(val, "SnapshotName")
+			return nil
+		}
+
+		for _, field := range []string{"SnapshotName", "SnapshotUUID", "SnapshotDescription"} {
+			if !strings.HasPrefix(key, field) {
+				continue
+			}
+
+			suffix := strings.TrimPrefix(key, field)
+			i, ok := bySuffix[suffix]
+			if !ok {
+				i = len(snapshots)
+				bySuffix[suffix] = i
+				snapshots = append(snapshots, drivers.Snapshot{})
+			}
+
+			switch field {
+			case "SnapshotName":
+				snapshots[i].Name = val
+			case "SnapshotUUID":
+				snapshots[i].ID = val
+			case "SnapshotDescription":
+				snapshots[i].Description = val
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if i, ok := bySuffix[currentSuffix]; ok {
+		snapshots[i].Current = true
+	}
+
+	return snapshots, nil
+}
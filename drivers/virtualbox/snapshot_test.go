@@ -0,0 +1,89 @@
+package virtualbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+var stdOutSnapshotList = `SnapshotName="base"
+SnapshotUUID="1111-aaaa"
+SnapshotName-1="provisioned"
+SnapshotUUID-1="2222-bbbb"
+SnapshotDescription-1="after provisioning"
+SnapshotName-1-1="patched"
+SnapshotUUID-1-1="3333-cccc"
+CurrentSnapshotName="provisioned"
+CurrentSnapshotUUID="2222-bbbb"
+CurrentSnapshotNode="SnapshotName-1"
+`
+
+func TestListSnapshots(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args:   "snapshot host list --machinereadable",
+		stdOut: stdOutSnapshotList,
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{Name: "base", ID: "1111-aaaa"},
+		{Name: "provisioned", ID: "2222-bbbb", Description: "after provisioning", Current: true},
+		{Name: "patched", ID: "3333-cccc"},
+	}, snapshots)
+}
+
+func TestListSnapshotsEmpty(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args:   "snapshot host list --machinereadable",
+		stdOut: "This machine does not have any snapshots\n",
+		err:    errors.New("exit status 1"),
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestListSnapshotsError(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args: "snapshot host list --machinereadable",
+		err:  errors.New("BUG"),
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.Nil(t, snapshots)
+	assert.EqualError(t, err, "BUG")
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	driver := NewDriver("host", "")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot host take base --description clean install",
+	}
+
+	assert.NoError(t, driver.TakeSnapshot("base", "clean install"))
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	driver := NewDriver("host", "")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot host restore base",
+	}
+
+	assert.NoError(t, driver.RestoreSnapshot("base"))
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	driver := NewDriver("host", "")
+	driver.VBoxManager = &VBoxManagerMock{
+		args: "snapshot host delete base",
+	}
+
+	assert.NoError(t, driver.DeleteSnapshot("base"))
+}
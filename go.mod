@@ -8,6 +8,7 @@ require (
 	github.com/moby/term v0.0.0-20200416134343-063f2cd0b49d
 	github.com/sayboras/dockerclient v1.0.0
 	github.com/stretchr/testify v1.2.2
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527
 )
@@ -0,0 +1,229 @@
+package libmachine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// DefaultParallelism is the number of hosts a batch operation works on at
+// once when BatchOptions does not say otherwise.
+var DefaultParallelism = 4
+
+// BatchOptions selects the hosts a batch operation applies to, and how many
+// of them are operated on at once.
+type BatchOptions struct {
+	// Names holds patterns, in the syntax of path.Match, selecting hosts by
+	// name. No patterns select every host.
+	Names []string
+
+	// Labels is a label selector, such as "team=ci,env!=prod", selecting
+	// hosts by their labels. See host.ParseLabelSelector.
+	Labels string
+
+	// Parallelism bounds the number of hosts operated on concurrently. Zero
+	// means DefaultParallelism.
+	Parallelism int
+}
+
+// HostResult is the outcome of a batch operation on a single host.
+type HostResult struct {
+	Name string
+	Err  error
+}
+
+func (o BatchOptions) matchName(name string) (bool, error) {
+	if len(o.Names) == 0 {
+		return true, nil
+	}
+
+	for _, pattern := range o.Names {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("Invalid host name pattern %q: %s", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RunAll loads every host selected by opts and runs action on them, at most
+// opts.Parallelism at a time. Hosts whose driver is a drivers.SerialDriver
+// are operated on one after the other, since their drivers must not run
+// concurrently anyway.
+//
+// A result is returned for each selected host, in the order of the store.
+// The error aggregates the failures in a mcnutils.MultiError.
+func (api *Client) RunAll(opts BatchOptions, action func(h *host.Host) error) ([]HostResult, error) {
+	hostNames, err := api.Store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []string{}
+	for _, hostName := range hostNames {
+		matched, err := opts.matchName(hostName)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			selected = append(selected, hostName)
+		}
+	}
+
+	hosts, hostsInError, err := persist.LoadByLabels(api.Store, selected, opts.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []HostResult{}
+	for _, hostName := range selected {
+		if err, ok := hostsInError[hostName]; ok {
+			results = append(results, HostResult{Name: hostName, Err: err})
+		}
+	}
+
+	loaded := []*host.Host{}
+	for _, h := range hosts {
+		if err := api.loadDriver(h); err != nil {
+			results = append(results, HostResult{Name: h.Name, Err: err})
+			continue
+		}
+		loaded = append(loaded, h)
+	}
+
+	results = append(results, runAll(loaded, opts.Parallelism, action)...)
+
+	order := map[string]int{}
+	for i, hostName := range selected {
+		order[hostName] = i
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return order[results[i].Name] < order[results[j].Name]
+	})
+
+	return results, resultsError(results)
+}
+
+func runAll(hosts []*host.Host, parallelism int, action func(h *host.Host) error) []HostResult {
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	var (
+		results = make([]HostResult, len(hosts))
+		serial  = []int{}
+		slots   = make(chan struct{}, parallelism)
+		wg      sync.WaitGroup
+	)
+
+	run := func(i int) {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+
+		results[i] = HostResult{
+			Name: hosts[i].Name,
+			Err:  action(hosts[i]),
+		}
+	}
+
+	for i, h := range hosts {
+		if _, ok := h.Driver.(*drivers.SerialDriver); ok {
+			serial = append(serial, i)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(i)
+	}
+
+	if len(serial) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range serial {
+				run(i)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func resultsError(results []HostResult) error {
+	errs := []error{}
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Name, result.Err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return mcnutils.MultiError{Errs: errs}
+	}
+
+	return nil
+}
+
+// ignoreAlreadyInState turns a host action into one which succeeds when the
+// host is already in the desired state, so batch operations are idempotent.
+func ignoreAlreadyInState(action func(h *host.Host) error) func(h *host.Host) error {
+	return func(h *host.Host) error {
+		err := action(h)
+		if _, ok := err.(mcnerror.ErrHostAlreadyInState); ok {
+			return nil
+		}
+		return err
+	}
+}
+
+// saving turns a host action into one which saves the host once it succeeded,
+// as starting or provisioning a host can change e.g. its IP or certificates.
+func (api *Client) saving(action func(h *host.Host) error) func(h *host.Host) error {
+	return func(h *host.Host) error {
+		if err := action(h); err != nil {
+			return err
+		}
+		return api.saveProgress(h)
+	}
+}
+
+// StartAll starts the selected hosts. Hosts already running are left as is.
+func (api *Client) StartAll(opts BatchOptions) ([]HostResult, error) {
+	return api.RunAll(opts, api.saving(ignoreAlreadyInState((*host.Host).Start)))
+}
+
+// StopAll stops the selected hosts. Hosts already stopped are left as is.
+func (api *Client) StopAll(opts BatchOptions) ([]HostResult, error) {
+	return api.RunAll(opts, ignoreAlreadyInState((*host.Host).Stop))
+}
+
+// RestartAll restarts the selected hosts.
+func (api *Client) RestartAll(opts BatchOptions) ([]HostResult, error) {
+	return api.RunAll(opts, api.saving((*host.Host).Restart))
+}
+
+// ProvisionAll re-provisions the selected hosts.
+func (api *Client) ProvisionAll(opts BatchOptions) ([]HostResult, error) {
+	return api.RunAll(opts, api.saving((*host.Host).Provision))
+}
+
+// UpgradeAll upgrades Docker on the selected hosts.
+func (api *Client) UpgradeAll(opts BatchOptions) ([]HostResult, error) {
+	return api.RunAll(opts, (*host.Host).Upgrade)
+}
@@ -0,0 +1,173 @@
+package libmachine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist/persisttest"
+	"github.com/stretchr/testify/assert"
+)
+
+type concurrencyRecorder struct {
+	sync.Mutex
+	current, max int
+}
+
+func (r *concurrencyRecorder) action(h *host.Host) error {
+	r.Lock()
+	r.current++
+	if r.current > r.max {
+		r.max = r.current
+	}
+	r.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	r.Lock()
+	r.current--
+	r.Unlock()
+
+	if h.Name == "broken" {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestBatchOptionsMatchName(t *testing.T) {
+	opts := BatchOptions{Names: []string{"ci-*", "build"}}
+
+	for name, expected := range map[string]bool{
+		"ci-1":    true,
+		"build":   true,
+		"builder": false,
+		"dev":     false,
+	} {
+		matched, err := opts.matchName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, matched, name)
+	}
+
+	matched, err := BatchOptions{}.matchName("anything")
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	_, err = BatchOptions{Names: []string{"["}}.matchName("anything")
+	assert.Error(t, err)
+}
+
+func TestRunAllBoundsParallelism(t *testing.T) {
+	hosts := []*host.Host{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		hosts = append(hosts, &host.Host{Name: name, Driver: &fakedriver.Driver{}})
+	}
+
+	recorder := &concurrencyRecorder{}
+	results := runAll(hosts, 2, recorder.action)
+
+	assert.Len(t, results, 6)
+	assert.Equal(t, "a", results[0].Name)
+	assert.True(t, recorder.max <= 2, "ran %d hosts at once", recorder.max)
+	assert.NoError(t, resultsError(results))
+}
+
+func TestRunAllSerializesSerialDrivers(t *testing.T) {
+	hosts := []*host.Host{}
+	for _, name := range []string{"a", "b", "c"} {
+		hosts = append(hosts, &host.Host{Name: name, Driver: drivers.NewSerialDriver(&fakedriver.Driver{})})
+	}
+
+	recorder := &concurrencyRecorder{}
+	runAll(hosts, 10, recorder.action)
+
+	assert.Equal(t, 1, recorder.max)
+}
+
+func TestRunAllAggregatesErrors(t *testing.T) {
+	hosts := []*host.Host{
+		{Name: "ok", Driver: &fakedriver.Driver{}},
+		{Name: "broken", Driver: &fakedriver.Driver{}},
+	}
+
+	results := runAll(hosts, 0, (&concurrencyRecorder{}).action)
+
+	assert.NoError(t, results[0].Err)
+	assert.EqualError(t, results[1].Err, "boom")
+
+	err := resultsError(results)
+	multiErr, ok := err.(mcnutils.MultiError)
+	assert.True(t, ok)
+	assert.EqualError(t, multiErr.Errs[0], "broken: boom")
+}
+
+// recordingStore records the hosts saved into it.
+type recordingStore struct {
+	persisttest.FakeStore
+	saved []string
+}
+
+func (s *recordingStore) Save(h *host.Host) error {
+	s.saved = append(s.saved, h.Name)
+	return s.FakeStore.Save(h)
+}
+
+func newTestBatchClient() (*Client, *recordingStore) {
+	store := &recordingStore{}
+	for _, name := range []string{"a", "b", "c"} {
+		rawDriver := []byte(`{"MockState":1}`)
+		if name == "b" {
+			rawDriver = []byte(`not json`)
+		}
+		store.Hosts = append(store.Hosts, &host.Host{
+			Name:       name,
+			DriverName: "inproc",
+			RawDriver:  rawDriver,
+		})
+	}
+
+	return &Client{Store: store}, store
+}
+
+func TestRunAllReturnsResultsInStoreOrder(t *testing.T) {
+	drivers.Register("inproc", func() drivers.Driver {
+		return &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}}
+	})
+	defer drivers.Unregister("inproc")
+
+	api, _ := newTestBatchClient()
+
+	results, err := api.RunAll(BatchOptions{}, func(h *host.Host) error {
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.Len(t, results, 3)
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Equal(t, name, results[i].Name)
+	}
+	assert.Error(t, results[1].Err)
+}
+
+func TestRunAllSavingSavesChangedHosts(t *testing.T) {
+	drivers.Register("inproc", func() drivers.Driver {
+		return &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}}
+	})
+	defer drivers.Unregister("inproc")
+
+	api, store := newTestBatchClient()
+
+	_, err := api.RunAll(BatchOptions{Names: []string{"a", "c"}}, api.saving(func(h *host.Host) error {
+		if h.Name == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	}))
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"a"}, store.saved)
+}
@@ -68,6 +68,15 @@ func (d *BaseDriver) GetSSHUsername() string {
 	return d.SSHUser
 }
 
+// GetSSHKnownHostsPath returns the known_hosts file in the machine's
+// directory, or "" if the driver is not attached to a store
+func (d *BaseDriver) GetSSHKnownHostsPath() string {
+	if d.StorePath == "" || d.MachineName == "" {
+		return ""
+	}
+	return d.ResolveStorePath("known_hosts")
+}
+
 // PreCreateCheck is called to enforce pre-creation steps
 func (d *BaseDriver) PreCreateCheck() error {
 	return nil
@@ -2,6 +2,7 @@ package drivers
 
 import (
 	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/machine/libmachine/mcnflag"
@@ -28,6 +29,12 @@ func TestIP(t *testing.T) {
 	}
 }
 
+func TestGetSSHKnownHostsPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("store", "machines", "dev", "known_hosts"), (&BaseDriver{StorePath: "store", MachineName: "dev"}).GetSSHKnownHostsPath())
+	assert.Equal(t, "", (&BaseDriver{MachineName: "dev"}).GetSSHKnownHostsPath())
+	assert.Equal(t, "", GetSSHKnownHostsPath(&MockDriver{calls: &CallRecorder{}}))
+}
+
 func TestEngineInstallUrlFlagEmpty(t *testing.T) {
 	assert.False(t, EngineInstallURLFlagSet(&CheckDriverOptions{}))
 }
@@ -0,0 +1,55 @@
+package drivers
+
+// Capability names an optional feature of a driver.
+type Capability string
+
+const (
+	// CapabilitySnapshots is set for drivers implementing Snapshotter.
+	CapabilitySnapshots Capability = "snapshots"
+	// CapabilityResize is set for drivers which can change the resources of
+	// an existing machine.
+	CapabilityResize Capability = "resize"
+	// CapabilityPause is set for drivers which can pause a machine.
+	CapabilityPause Capability = "pause"
+	// CapabilityContext is set for drivers implementing ContextDriver, which
+	// abort operations underway when their context is cancelled.
+	CapabilityContext Capability = "context"
+	// CapabilityLogStream is set for plugins which send their logs to the
+	// client as structured records.
+	CapabilityLogStream Capability = "log-stream"
+)
+
+// CapabilityReporter is implemented by drivers which tell which optional
+// features they support, rather than having them detected from the
+// interfaces they implement. This is how features without an interface in
+// libmachine are advertised, and how plugin clients report the features of
+// the driver on the other end of the connection.
+type CapabilityReporter interface {
+	Capabilities() []Capability
+}
+
+// Capabilities returns the optional features supported by d.
+func Capabilities(d Driver) []Capability {
+	if r, ok := d.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+
+	var capabilities []Capability
+	if _, ok := d.(Snapshotter); ok {
+		capabilities = append(capabilities, CapabilitySnapshots)
+	}
+	if _, ok := d.(ContextDriver); ok {
+		capabilities = append(capabilities, CapabilityContext)
+	}
+	return capabilities
+}
+
+// Supports tells whether d supports the optional feature capability.
+func Supports(d Driver, capability Capability) bool {
+	for _, c := range Capabilities(d) {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package drivers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reportingDriver struct {
+	*MockDriver
+	capabilities []Capability
+}
+
+func (d *reportingDriver) Capabilities() []Capability {
+	return d.capabilities
+}
+
+func TestCapabilitiesDetectsInterfaces(t *testing.T) {
+	assert.Empty(t, Capabilities(&MockDriver{}))
+	assert.Equal(t, []Capability{CapabilityContext}, Capabilities(NewContextDriver(&MockDriver{})))
+}
+
+func TestCapabilitiesReported(t *testing.T) {
+	d := &reportingDriver{
+		MockDriver:   &MockDriver{},
+		capabilities: []Capability{CapabilityPause},
+	}
+
+	assert.True(t, Supports(d, CapabilityPause))
+	assert.False(t, Supports(d, CapabilitySnapshots))
+}
+
+func TestNewSnapshotterNotSupported(t *testing.T) {
+	_, err := NewSnapshotter(&MockDriver{calls: &CallRecorder{}, driverName: "mock"})
+
+	assert.Equal(t, ErrNotSupported{DriverName: "mock", Capability: CapabilitySnapshots}, err)
+	assert.True(t, errors.Is(err, ErrSnapshotsNotSupported))
+	assert.False(t, errors.Is(ErrNotSupported{Capability: CapabilityPause}, ErrSnapshotsNotSupported))
+}
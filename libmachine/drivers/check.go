@@ -1,6 +1,109 @@
 package drivers
 
-import "github.com/docker/machine/libmachine/mcnflag"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+// InvalidFlag tells why the value of a flag is invalid.
+type InvalidFlag struct {
+	Name  string
+	Value interface{}
+	Err   error
+}
+
+func (f InvalidFlag) Error() string {
+	if f.Value == nil {
+		return fmt.Sprintf("--%s: %s", f.Name, f.Err)
+	}
+	return fmt.Sprintf("--%s=%v: %s", f.Name, f.Value, f.Err)
+}
+
+// ErrInvalidFlags reports all the invalid flag values passed to a driver at
+// once, sorted by flag name.
+type ErrInvalidFlags struct {
+	Flags []InvalidFlag
+}
+
+func (e ErrInvalidFlags) Error() string {
+	messages := []string{}
+	for _, f := range e.Flags {
+		messages = append(messages, f.Error())
+	}
+	return "Invalid flag values: " + strings.Join(messages, ", ")
+}
+
+var valueTypes = map[mcnflag.Kind]string{
+	mcnflag.KindString:      "a string",
+	mcnflag.KindStringSlice: "a list of strings",
+	mcnflag.KindInt:         "an integer",
+	mcnflag.KindBool:        "a boolean",
+}
+
+// ValidateFlagValues checks the values passed for the flags of a driver, by
+// flag name, against their types and the flags' Validate hooks. Values of
+// flags which are not declared are ignored, as the global flags are passed
+// to drivers along with their own. It returns an ErrInvalidFlags, or nil if
+// every value is valid.
+func ValidateFlagValues(flags []mcnflag.Flag, values map[string]interface{}) error {
+	invalid := []InvalidFlag{}
+	for _, flag := range flags {
+		value, present := values[flag.String()]
+		if !present || value == nil {
+			continue
+		}
+
+		if err := validateFlagValue(flag, value); err != nil {
+			invalid = append(invalid, InvalidFlag{Name: flag.String(), Value: value, Err: err})
+		}
+	}
+
+	return newErrInvalidFlags(invalid)
+}
+
+func validateFlagValue(flag mcnflag.Flag, value interface{}) error {
+	kind := mcnflag.KindOf(flag)
+	if kind == "" {
+		return fmt.Errorf("unsupported flag type %T", flag)
+	}
+
+	ok := false
+	switch value.(type) {
+	case string:
+		ok = kind == mcnflag.KindString
+	case []string:
+		ok = kind == mcnflag.KindStringSlice
+	case int:
+		ok = kind == mcnflag.KindInt
+	case bool:
+		ok = kind == mcnflag.KindBool
+	}
+	if !ok {
+		return fmt.Errorf("expected %s", valueTypes[kind])
+	}
+
+	if validator, ok := flag.(mcnflag.Validator); ok {
+		return validator.Validate(value)
+	}
+
+	return nil
+}
+
+func newErrInvalidFlags(invalid []InvalidFlag) error {
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(invalid, func(i, j int) bool {
+		return invalid[i].Name < invalid[j].Name
+	})
+
+	return ErrInvalidFlags{Flags: invalid}
+}
 
 // CheckDriverOptions implements DriverOptions and is used to validate flag parsing
 type CheckDriverOptions struct {
@@ -12,8 +115,7 @@ type CheckDriverOptions struct {
 func (o *CheckDriverOptions) String(key string) string {
 	for _, flag := range o.CreateFlags {
 		if flag.String() == key {
-			f, ok := flag.(mcnflag.StringFlag)
-			if !ok {
+			if mcnflag.KindOf(flag) != mcnflag.KindString {
 				o.InvalidFlags = append(o.InvalidFlags, flag.String())
 			}
 
@@ -21,7 +123,8 @@ func (o *CheckDriverOptions) String(key string) string {
 			if present {
 				return value
 			}
-			return f.Value
+			value, _ = flag.Default().(string)
+			return value
 		}
 	}
 
@@ -31,8 +134,7 @@ func (o *CheckDriverOptions) String(key string) string {
 func (o *CheckDriverOptions) StringSlice(key string) []string {
 	for _, flag := range o.CreateFlags {
 		if flag.String() == key {
-			f, ok := flag.(mcnflag.StringSliceFlag)
-			if !ok {
+			if mcnflag.KindOf(flag) != mcnflag.KindStringSlice {
 				o.InvalidFlags = append(o.InvalidFlags, flag.String())
 			}
 
@@ -40,7 +142,8 @@ func (o *CheckDriverOptions) StringSlice(key string) []string {
 			if present {
 				return value
 			}
-			return f.Value
+			value, _ = flag.Default().([]string)
+			return value
 		}
 	}
 
@@ -50,8 +153,7 @@ func (o *CheckDriverOptions) StringSlice(key string) []string {
 func (o *CheckDriverOptions) Int(key string) int {
 	for _, flag := range o.CreateFlags {
 		if flag.String() == key {
-			f, ok := flag.(mcnflag.IntFlag)
-			if !ok {
+			if mcnflag.KindOf(flag) != mcnflag.KindInt {
 				o.InvalidFlags = append(o.InvalidFlags, flag.String())
 			}
 
@@ -59,7 +161,8 @@ func (o *CheckDriverOptions) Int(key string) int {
 			if present {
 				return value
 			}
-			return f.Value
+			value, _ = flag.Default().(int)
+			return value
 		}
 	}
 
@@ -69,8 +172,7 @@ func (o *CheckDriverOptions) Int(key string) int {
 func (o *CheckDriverOptions) Bool(key string) bool {
 	for _, flag := range o.CreateFlags {
 		if flag.String() == key {
-			_, ok := flag.(mcnflag.BoolFlag)
-			if !ok {
+			if mcnflag.KindOf(flag) != mcnflag.KindBool {
 				o.InvalidFlags = append(o.InvalidFlags, flag.String())
 			}
 		}
@@ -82,3 +184,19 @@ func (o *CheckDriverOptions) Bool(key string) bool {
 	}
 	return false
 }
+
+// Validate returns an ErrInvalidFlags listing every invalid value in
+// FlagsValues, along with the flags read with the accessor of another type
+// than theirs, or nil if there are none.
+func (o *CheckDriverOptions) Validate() error {
+	invalid := []InvalidFlag{}
+	if err, ok := ValidateFlagValues(o.CreateFlags, o.FlagsValues).(ErrInvalidFlags); ok {
+		invalid = err.Flags
+	}
+
+	for _, name := range o.InvalidFlags {
+		invalid = append(invalid, InvalidFlag{Name: name, Err: errors.New("read with the accessor of another type than its own")})
+	}
+
+	return newErrInvalidFlags(invalid)
+}
@@ -0,0 +1,58 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+var checkedFlags = []mcnflag.Flag{
+	mcnflag.StringFlag{Name: "name"},
+	mcnflag.IntFlag{Name: "cpus", Value: 1},
+	mcnflag.SizeFlag{Name: "memory", Value: 1024},
+	mcnflag.EnumFlag{Name: "mode", Value: "nat", Values: []string{"nat", "bridged"}},
+	mcnflag.CIDRFlag{Name: "cidr"},
+}
+
+func TestValidateFlagValues(t *testing.T) {
+	assert.NoError(t, ValidateFlagValues(checkedFlags, map[string]interface{}{
+		"name":         "default",
+		"cpus":         2,
+		"memory":       "2GB",
+		"mode":         "bridged",
+		"swarm-master": true,
+	}))
+
+	err := ValidateFlagValues(checkedFlags, map[string]interface{}{
+		"name":   "default",
+		"mode":   "host",
+		"cpus":   "2",
+		"memory": "2GB",
+		"cidr":   "10.0.0.1",
+	})
+
+	assert.IsType(t, ErrInvalidFlags{}, err)
+	invalid := err.(ErrInvalidFlags).Flags
+	assert.Len(t, invalid, 3)
+	assert.Equal(t, []string{"cidr", "cpus", "mode"}, []string{invalid[0].Name, invalid[1].Name, invalid[2].Name})
+	assert.Equal(t, "10.0.0.1", invalid[0].Value)
+	assert.EqualError(t, err, `Invalid flag values: --cidr=10.0.0.1: "10.0.0.1" is not a CIDR, e.g. 192.168.99.1/24, --cpus=2: expected an integer, --mode=host: "host" is not one of nat, bridged`)
+}
+
+func TestCheckDriverOptions(t *testing.T) {
+	options := &CheckDriverOptions{
+		FlagsValues: map[string]interface{}{"memory": "2GB"},
+		CreateFlags: checkedFlags,
+	}
+
+	assert.Equal(t, "2GB", options.String("memory"))
+	assert.Equal(t, "nat", options.String("mode"))
+	assert.Equal(t, 1, options.Int("cpus"))
+	assert.Empty(t, options.InvalidFlags)
+	assert.NoError(t, options.Validate())
+
+	options.Int("memory")
+	assert.Equal(t, []string{"memory"}, options.InvalidFlags)
+	assert.EqualError(t, options.Validate(), "Invalid flag values: --memory: read with the accessor of another type than its own")
+}
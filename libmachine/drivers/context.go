@@ -0,0 +1,113 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/machine/libmachine/state"
+)
+
+// ContextDriver is implemented by drivers whose long running operations can
+// be cancelled, or bounded by a deadline, through a context.Context.
+type ContextDriver interface {
+	Driver
+
+	// CreateContext creates a host using the driver's config
+	CreateContext(ctx context.Context) error
+
+	// GetStateContext returns the state that the host is in
+	GetStateContext(ctx context.Context) (state.State, error)
+
+	// KillContext stops a host forcefully
+	KillContext(ctx context.Context) error
+
+	// RemoveContext removes a host
+	RemoveContext(ctx context.Context) error
+
+	// RestartContext restarts a host
+	RestartContext(ctx context.Context) error
+
+	// StartContext starts a host
+	StartContext(ctx context.Context) error
+
+	// StopContext stops a host gracefully
+	StopContext(ctx context.Context) error
+}
+
+// contextAdapter makes a plain Driver usable as a ContextDriver. The wrapped
+// driver has no way to abort an operation which is already underway, so the
+// adapter refuses to start new operations once the context is done and stops
+// waiting for a running one as soon as the context is cancelled.
+type contextAdapter struct {
+	Driver
+}
+
+// NewContextDriver returns d itself if it already supports contexts, and
+// otherwise wraps it in an adapter which honors cancellation between calls.
+func NewContextDriver(d Driver) ContextDriver {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd
+	}
+	return &contextAdapter{Driver: d}
+}
+
+// RunContext calls f, returning early with the context error if ctx is done
+// before f returns. f is not interrupted and keeps running in the background.
+func RunContext(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *contextAdapter) CreateContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Create)
+}
+
+func (a *contextAdapter) GetStateContext(ctx context.Context) (state.State, error) {
+	s := state.Error
+	err := RunContext(ctx, func() error {
+		var err error
+		s, err = a.Driver.GetState()
+		return err
+	})
+	if err != nil {
+		return state.Error, err
+	}
+	return s, nil
+}
+
+func (a *contextAdapter) KillContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Kill)
+}
+
+func (a *contextAdapter) RemoveContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Remove)
+}
+
+func (a *contextAdapter) RestartContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Restart)
+}
+
+func (a *contextAdapter) StartContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Start)
+}
+
+func (a *contextAdapter) StopContext(ctx context.Context) error {
+	return RunContext(ctx, a.Driver.Stop)
+}
+
+func (a *contextAdapter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Driver)
+}
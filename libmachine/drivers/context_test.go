@@ -0,0 +1,136 @@
+package drivers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingDriver struct {
+	*MockDriver
+	release chan struct{}
+}
+
+func (d *blockingDriver) Create() error {
+	<-d.release
+	return d.MockDriver.Create()
+}
+
+func TestNewContextDriverCallsThrough(t *testing.T) {
+	calls := &CallRecorder{}
+	d := NewContextDriver(&MockDriver{calls: calls, state: state.Running})
+
+	s, err := d.GetStateContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	assert.NoError(t, d.StartContext(context.Background()))
+	assert.NoError(t, d.StopContext(context.Background()))
+	assert.Equal(t, []string{"GetState", "Start", "Stop"}, calls.calls)
+}
+
+func TestNewContextDriverReturnsContextDriver(t *testing.T) {
+	d := NewContextDriver(&MockDriver{calls: &CallRecorder{}})
+
+	assert.Equal(t, d, NewContextDriver(d))
+}
+
+func TestContextDriverCancelledBeforeCall(t *testing.T) {
+	calls := &CallRecorder{}
+	d := NewContextDriver(&MockDriver{calls: calls})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, d.CreateContext(ctx))
+	assert.Empty(t, calls.calls)
+}
+
+func TestContextDriverDeadlineDuringCall(t *testing.T) {
+	d := &blockingDriver{
+		MockDriver: &MockDriver{calls: &CallRecorder{}},
+		release:    make(chan struct{}),
+	}
+	defer close(d.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, NewContextDriver(d).CreateContext(ctx))
+}
+
+func TestSerialDriverContextHoldsLockUntilCallReturns(t *testing.T) {
+	calls := &CallRecorder{}
+	d := &blockingDriver{
+		MockDriver: &MockDriver{calls: calls},
+		release:    make(chan struct{}),
+	}
+	driver := newSerialDriverWithLock(d, &sync.Mutex{}).(*SerialDriver)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, driver.CreateContext(ctx))
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		close(started)
+		done <- driver.StartContext(context.Background())
+	}()
+	<-started
+
+	// Create is still running in the background, Start must wait for it.
+	select {
+	case err := <-done:
+		t.Fatalf("Start entered the driver while Create was running: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(d.release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"Create", "Start"}, calls.calls)
+}
+
+// adaptedDriver is a ContextDriver which cannot abort its operations, like
+// the client of a plugin whose driver does not support contexts.
+type adaptedDriver struct {
+	ContextDriver
+}
+
+func (d *adaptedDriver) Capabilities() []Capability {
+	return nil
+}
+
+func TestSerialDriverContextHoldsLockUntilAdaptedCallReturns(t *testing.T) {
+	calls := &CallRecorder{}
+	d := &blockingDriver{
+		MockDriver: &MockDriver{calls: calls},
+		release:    make(chan struct{}),
+	}
+	driver := newSerialDriverWithLock(&adaptedDriver{NewContextDriver(d)}, &sync.Mutex{}).(*SerialDriver)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, driver.CreateContext(ctx))
+
+	done := make(chan error)
+	go func() {
+		done <- driver.StartContext(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Start entered the driver while Create was running: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(d.release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"Create", "Start"}, calls.calls)
+}
@@ -0,0 +1,327 @@
+// Package driverstest checks that a driver behaves the way libmachine expects
+// every driver to behave. Driver authors call Run from a test:
+//
+//	func TestConformance(t *testing.T) {
+//		driverstest.Run(t, driverstest.Config{
+//			New: func(machineName, storePath string) drivers.Driver {
+//				return NewDriver(machineName, storePath)
+//			},
+//		})
+//	}
+package driverstest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	machineName    = "driverstest"
+	defaultTimeout = 5 * time.Minute
+	pollInterval   = time.Second
+)
+
+// Config describes the driver under test.
+type Config struct {
+	// New returns a new driver for a machine, the way the NewDriver
+	// function of drivers does.
+	New func(machineName, storePath string) drivers.Driver
+
+	// Flags overrides the default values of create flags by flag name,
+	// e.g. to pass the required ones.
+	Flags map[string]interface{}
+
+	// Lifecycle enables the test creating, stopping, starting and removing
+	// a machine, which needs the infrastructure managed by the driver.
+	Lifecycle bool
+
+	// Timeout bounds the wait for each state transition of the lifecycle
+	// test. It defaults to five minutes.
+	Timeout time.Duration
+}
+
+// globalFlags are not declared by drivers, but passed to them by the CLI
+// along with their create flags.
+var globalFlags = []mcnflag.Flag{
+	mcnflag.BoolFlag{Name: "swarm-master"},
+	mcnflag.StringFlag{Name: "swarm-host", Value: "tcp://0.0.0.0:3376"},
+	mcnflag.StringFlag{Name: "swarm-discovery"},
+	mcnflag.StringFlag{Name: "engine-install-url", Value: drivers.DefaultEngineInstallURL},
+}
+
+// Run runs the conformance tests of the driver as subtests of t:
+//   - Flags checks the create flags and their values, and that
+//     SetConfigFromFlags only reads the flags declared, with the type they
+//     are declared with.
+//   - Config checks that the config survives a JSON round-trip.
+//   - RPC checks that the driver behaves the same when called through a
+//     plugin server, over every transport.
+//   - Lifecycle, if enabled, takes a machine through its states, checking
+//     that Stop and Remove are idempotent.
+func Run(t *testing.T, config Config) {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	storePath, err := ioutil.TempDir("", "driverstest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	t.Run("Flags", func(t *testing.T) {
+		testFlags(t, config, storePath)
+	})
+	t.Run("Config", func(t *testing.T) {
+		testConfig(t, config, storePath)
+	})
+	t.Run("RPC", func(t *testing.T) {
+		testRPC(t, config, storePath)
+	})
+	if config.Lifecycle {
+		t.Run("Lifecycle", func(t *testing.T) {
+			testLifecycle(t, config, storePath)
+		})
+	}
+}
+
+// newConfiguredDriver returns a driver configured from its create flags.
+func newConfiguredDriver(t *testing.T, config Config, storePath string) drivers.Driver {
+	d := config.New(machineName, storePath)
+
+	opts, err := newCheckedOptions(t, d.GetCreateFlags(), config.Flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetConfigFromFlags(opts); err != nil {
+		t.Fatalf("SetConfigFromFlags failed: %s", err)
+	}
+
+	return d
+}
+
+func testFlags(t *testing.T, config Config, storePath string) {
+	d := config.New(machineName, storePath)
+
+	names := map[string]bool{}
+	for _, f := range globalFlags {
+		names[f.String()] = true
+	}
+
+	for _, f := range d.GetCreateFlags() {
+		name := f.String()
+		switch {
+		case name == "":
+			t.Errorf("Flag %#v has no name", f)
+		case names[name]:
+			t.Errorf("Flag %s is declared more than once", name)
+		}
+		names[name] = true
+
+		if _, err := flagType(f); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for name := range config.Flags {
+		if !names[name] {
+			t.Errorf("Config sets flag %s, which the driver does not declare", name)
+		}
+	}
+
+	values := map[string]interface{}{}
+	for _, f := range d.GetCreateFlags() {
+		values[f.String()] = flagValue(f, config.Flags)
+	}
+	if err := drivers.ValidateFlagValues(d.GetCreateFlags(), values); err != nil {
+		t.Error(err)
+	}
+
+	newConfiguredDriver(t, config, storePath)
+}
+
+func testConfig(t *testing.T, config Config, storePath string) {
+	d := newConfiguredDriver(t, config, storePath)
+
+	if d.DriverName() == "" {
+		t.Error("DriverName is empty")
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Config cannot be marshalled: %s", err)
+	}
+
+	loaded := config.New("", "")
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("Config cannot be unmarshalled: %s", err)
+	}
+
+	reloaded, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatalf("Config cannot be marshalled after being unmarshalled: %s", err)
+	}
+
+	assertJSONEqual(t, data, reloaded, "Config changed during a JSON round-trip")
+
+	if loaded.GetMachineName() != d.GetMachineName() {
+		t.Errorf("GetMachineName is %q after a JSON round-trip, expected %q", loaded.GetMachineName(), d.GetMachineName())
+	}
+	if loaded.DriverName() != d.DriverName() {
+		t.Errorf("DriverName is %q after a JSON round-trip, expected %q", loaded.DriverName(), d.DriverName())
+	}
+}
+
+func testRPC(t *testing.T, config Config, storePath string) {
+	expected := newConfiguredDriver(t, config, storePath)
+	expectedConfig, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]interface{}{}
+	for _, f := range append(globalFlags, expected.GetCreateFlags()...) {
+		values[f.String()] = flagValue(f, config.Flags)
+	}
+
+	for _, transport := range []string{rpc.TransportGob, rpc.TransportJSONRPC} {
+		t.Run(transport, func(t *testing.T) {
+			c, err := rpc.NewInProcessRPCClientDriver(config.New(machineName, storePath), transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Client.RPCClient.Close()
+
+			if err := c.SetConfigFromFlags(&rpc.RPCFlags{Values: values}); err != nil {
+				t.Fatalf("SetConfigFromFlags failed: %s", err)
+			}
+
+			data, err := c.GetConfigRaw()
+			if err != nil {
+				t.Fatalf("GetConfigRaw failed: %s", err)
+			}
+			assertJSONEqual(t, expectedConfig, data, "Config differs when set from flags over RPC")
+
+			if err := c.SetConfigRaw(expectedConfig); err != nil {
+				t.Fatalf("SetConfigRaw failed: %s", err)
+			}
+
+			for _, check := range []struct {
+				name             string
+				expected, actual interface{}
+			}{
+				{"DriverName", expected.DriverName(), c.DriverName()},
+				{"GetMachineName", expected.GetMachineName(), c.GetMachineName()},
+				{"GetSSHKeyPath", expected.GetSSHKeyPath(), c.GetSSHKeyPath()},
+				{"GetSSHUsername", expected.GetSSHUsername(), c.GetSSHUsername()},
+				{"GetCreateFlags", derefFlags(expected.GetCreateFlags()), derefFlags(c.GetCreateFlags())},
+				{"Capabilities", capabilitySet(drivers.Capabilities(expected)), capabilitySet(c.Capabilities())},
+			} {
+				if !reflect.DeepEqual(check.expected, check.actual) {
+					t.Errorf("%s is %v over RPC, expected %v", check.name, check.actual, check.expected)
+				}
+			}
+		})
+	}
+}
+
+func testLifecycle(t *testing.T, config Config, storePath string) {
+	d := newConfiguredDriver(t, config, storePath)
+
+	removed := false
+	defer func() {
+		if !removed {
+			_ = d.Remove()
+		}
+	}()
+
+	step := func(name string, f func() error) {
+		if err := f(); err != nil {
+			t.Fatalf("%s failed: %s", name, err)
+		}
+	}
+	waitFor := func(desired state.State) {
+		attempts := int(config.Timeout / pollInterval)
+		if err := mcnutils.WaitForSpecific(drivers.MachineInState(d, desired), attempts, pollInterval); err != nil {
+			s, _ := d.GetState()
+			t.Fatalf("Machine is %s, expected %s after %s", s, desired, config.Timeout)
+		}
+	}
+
+	step("PreCreateCheck", d.PreCreateCheck)
+	step("Create", d.Create)
+	waitFor(state.Running)
+
+	if ip, err := d.GetIP(); err != nil || ip == "" {
+		t.Errorf("GetIP returned %q, %v for a running machine", ip, err)
+	}
+	if _, err := d.GetSSHHostname(); err != nil {
+		t.Errorf("GetSSHHostname failed for a running machine: %s", err)
+	}
+	if _, err := d.GetSSHPort(); err != nil {
+		t.Errorf("GetSSHPort failed for a running machine: %s", err)
+	}
+	if _, err := d.GetURL(); err != nil {
+		t.Errorf("GetURL failed for a running machine: %s", err)
+	}
+
+	step("Stop", d.Stop)
+	waitFor(state.Stopped)
+	step("Stop of a stopped machine", d.Stop)
+	waitFor(state.Stopped)
+
+	step("Start", d.Start)
+	waitFor(state.Running)
+	step("Restart", d.Restart)
+	waitFor(state.Running)
+
+	step("Kill", d.Kill)
+	waitFor(state.Stopped)
+
+	step("Remove", d.Remove)
+	removed = true
+	step("Remove of a removed machine", d.Remove)
+}
+
+func assertJSONEqual(t *testing.T, expected, actual []byte, message string) {
+	var expectedValue, actualValue interface{}
+	if err := json.Unmarshal(expected, &expectedValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(actual, &actualValue); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expectedValue, actualValue) {
+		t.Errorf("%s:\nexpected %s\ngot      %s", message, expected, actual)
+	}
+}
+
+// derefFlags lets flags be compared whether they are pointers or not, as gob
+// turns them into pointers.
+func derefFlags(flags []mcnflag.Flag) []interface{} {
+	values := []interface{}{}
+	for _, f := range flags {
+		values = append(values, reflect.Indirect(reflect.ValueOf(f)).Interface())
+	}
+	return values
+}
+
+func capabilitySet(capabilities []drivers.Capability) map[drivers.Capability]bool {
+	set := map[drivers.Capability]bool{}
+	for _, c := range capabilities {
+		set[c] = true
+	}
+	return set
+}
@@ -0,0 +1,54 @@
+package driverstest
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFakeDriver(t *testing.T) {
+	Run(t, Config{
+		New: func(machineName, storePath string) drivers.Driver {
+			return &fakedriver.Driver{
+				BaseDriver: &drivers.BaseDriver{
+					MachineName: machineName,
+					StorePath:   storePath,
+				},
+				MockName: machineName,
+				MockIP:   "1.2.3.4",
+			}
+		},
+		Lifecycle: true,
+	})
+}
+
+func TestCheckedOptions(t *testing.T) {
+	flags := []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "string", Value: "default"},
+		&mcnflag.StringSliceFlag{Name: "string-slice"},
+		mcnflag.IntFlag{Name: "int", Value: 42},
+		mcnflag.BoolFlag{Name: "bool"},
+	}
+
+	opts, err := newCheckedOptions(t, flags, map[string]interface{}{"int": 7})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "default", opts.String("string"))
+	assert.Equal(t, []string{}, opts.StringSlice("string-slice"))
+	assert.Equal(t, 7, opts.Int("int"))
+	assert.False(t, opts.Bool("bool"))
+	assert.Equal(t, drivers.DefaultEngineInstallURL, opts.String("engine-install-url"))
+}
+
+type unsupportedFlag struct{}
+
+func (f unsupportedFlag) String() string       { return "unsupported" }
+func (f unsupportedFlag) Default() interface{} { return nil }
+
+func TestCheckedOptionsUnsupportedFlag(t *testing.T) {
+	_, err := newCheckedOptions(t, []mcnflag.Flag{unsupportedFlag{}}, nil)
+	assert.EqualError(t, err, "Flag unsupported has an unsupported type driverstest.unsupportedFlag")
+}
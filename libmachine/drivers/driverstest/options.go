@@ -0,0 +1,107 @@
+package driverstest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+// checkedOptions holds the values of the flags passed to SetConfigFromFlags.
+// Reading a flag which is not declared, or with the accessor of another type
+// than the flag's, fails the test.
+type checkedOptions struct {
+	t      *testing.T
+	types  map[string]string
+	values map[string]interface{}
+}
+
+func newCheckedOptions(t *testing.T, flags []mcnflag.Flag, overrides map[string]interface{}) (*checkedOptions, error) {
+	o := &checkedOptions{
+		t:      t,
+		types:  map[string]string{},
+		values: map[string]interface{}{},
+	}
+
+	for _, f := range append(globalFlags, flags...) {
+		typ, err := flagType(f)
+		if err != nil {
+			return nil, err
+		}
+
+		o.types[f.String()] = typ
+		o.values[f.String()] = flagValue(f, overrides)
+	}
+
+	return o, nil
+}
+
+func (o *checkedOptions) get(key, typ string) interface{} {
+	o.t.Helper()
+
+	declared, ok := o.types[key]
+	switch {
+	case !ok:
+		o.t.Errorf("SetConfigFromFlags reads flag %s, which the driver does not declare", key)
+	case declared != typ:
+		o.t.Errorf("SetConfigFromFlags reads flag %s as %s, but it is declared as %s", key, typ, declared)
+	}
+
+	return o.values[key]
+}
+
+func (o *checkedOptions) String(key string) string {
+	o.t.Helper()
+	val, _ := o.get(key, "string").(string)
+	return val
+}
+
+func (o *checkedOptions) StringSlice(key string) []string {
+	o.t.Helper()
+	val, _ := o.get(key, "stringSlice").([]string)
+	return val
+}
+
+func (o *checkedOptions) Int(key string) int {
+	o.t.Helper()
+	val, _ := o.get(key, "int").(int)
+	return val
+}
+
+func (o *checkedOptions) Bool(key string) bool {
+	o.t.Helper()
+	val, _ := o.get(key, "bool").(bool)
+	return val
+}
+
+// flagType returns the name of the DriverOptions accessor used to read a
+// flag.
+func flagType(f mcnflag.Flag) (string, error) {
+	kind := mcnflag.KindOf(f)
+	if kind == "" {
+		return "", fmt.Errorf("Flag %s has an unsupported type %T", f, f)
+	}
+
+	return string(kind), nil
+}
+
+// flagValue returns the value of a flag: the one in overrides if any, and
+// its default otherwise, the way the CLI passes it.
+func flagValue(f mcnflag.Flag, overrides map[string]interface{}) interface{} {
+	if val, ok := overrides[f.String()]; ok {
+		return val
+	}
+
+	switch f := reflect.Indirect(reflect.ValueOf(f)).Interface().(type) {
+	case mcnflag.StringSliceFlag:
+		if f.Value == nil {
+			return []string{}
+		}
+		return f.Value
+	case mcnflag.BoolFlag:
+		return false
+	}
+
+	return f.Default()
+}
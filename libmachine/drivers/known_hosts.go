@@ -0,0 +1,19 @@
+package drivers
+
+// KnownHostsDriver is implemented by drivers which record the SSH host key of
+// their machine on first contact, and verify it on later connections.
+type KnownHostsDriver interface {
+	// GetSSHKnownHostsPath returns the path of the known_hosts file of the
+	// machine, or "" if host keys should not be verified
+	GetSSHKnownHostsPath() string
+}
+
+// GetSSHKnownHostsPath returns the known_hosts file of the machine of d, or ""
+// if d does not verify host keys.
+func GetSSHKnownHostsPath(d Driver) string {
+	if k, ok := d.(KnownHostsDriver); ok {
+		return k.GetSSHKnownHostsPath()
+	}
+
+	return ""
+}
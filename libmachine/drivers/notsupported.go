@@ -20,6 +20,23 @@ func (e NotSupported) Error() string {
 	return fmt.Sprintf("Driver %q not supported on this platform.", e.DriverName)
 }
 
+// ErrNotSupported is returned when an optional feature is requested from a
+// driver which does not support it.
+type ErrNotSupported struct {
+	DriverName string
+	Capability Capability
+}
+
+func (e ErrNotSupported) Error() string {
+	return fmt.Sprintf("Driver %q does not support %s.", e.DriverName, e.Capability)
+}
+
+// Is lets errors.Is match the ErrNotSupported of snapshots against
+// ErrSnapshotsNotSupported, which was returned before capabilities existed.
+func (e ErrNotSupported) Is(target error) bool {
+	return target == ErrSnapshotsNotSupported && e.Capability == CapabilitySnapshots
+}
+
 // NewDriverNotSupported creates a placeholder Driver that replaces
 // a driver that is not supported on a given platform. eg fusion on linux.
 func NewDriverNotSupported(driverName, hostName, storePath string) Driver {
@@ -0,0 +1,253 @@
+package localbinary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	// PluginEnvPath lists directories, separated like $PATH, which are
+	// searched for plugin binaries before the configured plugin
+	// directories and $PATH.
+	PluginEnvPath = "MACHINE_PLUGIN_PATH"
+
+	pluginBinaryPrefix = "docker-machine-driver-"
+)
+
+// PluginOptions configures how the binary of a driver plugin is found and
+// checked before it is started.
+type PluginOptions struct {
+	// Dirs are searched for plugin binaries after the directories listed
+	// in MACHINE_PLUGIN_PATH.
+	Dirs []string
+
+	// Checksum is the SHA-256 the plugin binary is expected to have, as
+	// returned by PluginBinary.Checksum. It is not checked when empty.
+	Checksum string
+
+	// CopyDir keeps copies of the plugin binaries, one per checksum, which
+	// are run instead of the binaries found so that the binary which runs
+	// is always the one which was checksummed. Plugin binaries are run
+	// where they are found when it is empty.
+	CopyDir string
+}
+
+// PluginBinary is the binary serving a driver.
+type PluginBinary struct {
+	DriverName string
+	Path       string
+
+	// Core tells whether the driver is served by the docker-machine binary
+	// itself rather than by a docker-machine-driver-<name> plugin binary.
+	Core bool
+}
+
+// ErrPluginChecksumMismatch is returned when a plugin binary was replaced
+// since the machine was pinned to it. The machine can be pinned to the new
+// binary with libmachine's Client.RepinDriver.
+type ErrPluginChecksumMismatch struct {
+	DriverName string
+	Path       string
+	Expected   string
+	Actual     string
+}
+
+func (e ErrPluginChecksumMismatch) Error() string {
+	return fmt.Sprintf("Plugin binary %s of driver %q does not match the checksum recorded for the machine (expected sha256:%s, got sha256:%s)", e.Path, e.DriverName, e.Expected, e.Actual)
+}
+
+// SearchPath returns the directories searched for plugin binaries before
+// $PATH: those listed in MACHINE_PLUGIN_PATH, followed by dirs.
+func SearchPath(dirs ...string) []string {
+	searchPath := []string{}
+	for _, dir := range append(filepath.SplitList(os.Getenv(PluginEnvPath)), dirs...) {
+		if dir != "" {
+			searchPath = append(searchPath, dir)
+		}
+	}
+
+	return searchPath
+}
+
+// FindPlugin finds the binary serving a driver by its name.
+//   - A `docker-machine-driver-driverName` binary in the search path, see
+//     SearchPath, always wins, even over a core driver.
+//   - If the driver is a core driver, there is no separate driver binary. We
+//     reuse current binary if it's `docker-machine` or we assume
+//     `docker-machine` is in the PATH.
+//   - Otherwise the `docker-machine-driver-driverName` binary must be in the
+//     PATH.
+func FindPlugin(driverName string, dirs ...string) (PluginBinary, error) {
+	binaryName := pluginBinaryPrefix + driverName
+
+	for _, dir := range SearchPath(dirs...) {
+		if path, err := exec.LookPath(filepath.Join(dir, binaryName)); err == nil {
+			return PluginBinary{DriverName: driverName, Path: path}, nil
+		}
+	}
+
+	if isCoreDriver(driverName) {
+		path := "docker-machine"
+		if CurrentBinaryIsDockerMachine {
+			path = os.Args[0]
+		}
+
+		path, err := exec.LookPath(path)
+		if err != nil {
+			return PluginBinary{}, ErrPluginBinaryNotFound{driverName, "docker-machine"}
+		}
+
+		return PluginBinary{DriverName: driverName, Path: path, Core: true}, nil
+	}
+
+	path, err := exec.LookPath(binaryName)
+	if err != nil {
+		return PluginBinary{}, ErrPluginBinaryNotFound{driverName, binaryName}
+	}
+
+	return PluginBinary{DriverName: driverName, Path: path}, nil
+}
+
+// FindPlugins returns the binaries of all the drivers which can be found,
+// core drivers included, sorted by driver name.
+func FindPlugins(dirs ...string) []PluginBinary {
+	driverNames := map[string]bool{}
+	for _, coreDriver := range CoreDrivers {
+		driverNames[coreDriver] = true
+	}
+
+	for _, dir := range append(SearchPath(dirs...), filepath.SplitList(os.Getenv("PATH"))...) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			if f.IsDir() || !strings.HasPrefix(f.Name(), pluginBinaryPrefix) {
+				continue
+			}
+
+			name := strings.TrimPrefix(f.Name(), pluginBinaryPrefix)
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			driverNames[name] = true
+		}
+	}
+
+	binaries := []PluginBinary{}
+	for driverName := range driverNames {
+		if binary, err := FindPlugin(driverName, dirs...); err == nil {
+			binaries = append(binaries, binary)
+		}
+	}
+
+	sort.Slice(binaries, func(i, j int) bool {
+		return binaries[i].DriverName < binaries[j].DriverName
+	})
+
+	return binaries
+}
+
+// Checksum returns the hex encoded SHA-256 of a plugin binary. Core drivers
+// change with every docker-machine release and are not checksummed, so their
+// checksum is empty.
+func (b PluginBinary) Checksum() (string, error) {
+	if b.Core {
+		return "", nil
+	}
+
+	return b.copyTo(ioutil.Discard)
+}
+
+// checkedCopy returns the path of a copy of the plugin binary kept in
+// dir/<checksum>, which is made on first use and reused afterwards. The copy
+// fails with ErrPluginChecksumMismatch if the binary no longer has checksum.
+// Running the copy guarantees the binary which runs is the one which was
+// checksummed, even if the original is replaced in the meantime.
+func (b PluginBinary) checkedCopy(dir, checksum string) (string, error) {
+	copyDir := filepath.Join(dir, checksum)
+	path := filepath.Join(copyDir, filepath.Base(b.Path))
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(copyDir, 0700); err != nil {
+		return "", err
+	}
+
+	// The copy is written aside and renamed once complete, so that it is
+	// never run half written, e.g. by another docker-machine process.
+	f, err := ioutil.TempFile(copyDir, filepath.Base(b.Path)+".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	actual, err := b.copyTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if actual != checksum {
+		return "", ErrPluginChecksumMismatch{
+			DriverName: b.DriverName,
+			Path:       b.Path,
+			Expected:   checksum,
+			Actual:     actual,
+		}
+	}
+
+	if err := os.Chmod(f.Name(), 0700); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		// Someone else made the copy first.
+		if _, statErr := os.Stat(path); statErr == nil {
+			return path, nil
+		}
+		return "", err
+	}
+
+	return path, nil
+}
+
+// copyTo writes the plugin binary to w, and returns its checksum.
+func (b PluginBinary) copyTo(w io.Writer) (string, error) {
+	f, err := os.Open(b.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h, w), f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isCoreDriver(driverName string) bool {
+	for _, coreDriver := range CoreDrivers {
+		if coreDriver == driverName {
+			return true
+		}
+	}
+
+	return false
+}
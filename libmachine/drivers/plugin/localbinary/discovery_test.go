@@ -0,0 +1,158 @@
+package localbinary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePlugin writes an executable plugin binary for driverName into dir.
+func writePlugin(t *testing.T, dir, driverName, content string) string {
+	name := pluginBinaryPrefix + driverName
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSearchPath(t *testing.T) {
+	defer os.Setenv(PluginEnvPath, os.Getenv(PluginEnvPath))
+	os.Setenv(PluginEnvPath, "/env/a"+string(os.PathListSeparator)+string(os.PathListSeparator)+"/env/b")
+
+	assert.Equal(t, []string{"/env/a", "/env/b", "/store/plugins"}, SearchPath("/store/plugins"))
+}
+
+func TestFindPlugin(t *testing.T) {
+	envDir, _ := ioutil.TempDir("", "machine-plugins-")
+	defer os.RemoveAll(envDir)
+	storeDir, _ := ioutil.TempDir("", "machine-plugins-")
+	defer os.RemoveAll(storeDir)
+
+	defer os.Setenv(PluginEnvPath, os.Getenv(PluginEnvPath))
+	os.Setenv(PluginEnvPath, envDir)
+
+	writePlugin(t, storeDir, "foo", "store")
+	fromEnv := writePlugin(t, envDir, "foo", "env")
+	writePlugin(t, storeDir, "virtualbox", "override")
+
+	binary, err := FindPlugin("foo", storeDir)
+	assert.NoError(t, err)
+	assert.Equal(t, PluginBinary{DriverName: "foo", Path: fromEnv}, binary)
+
+	binary, err = FindPlugin("virtualbox", storeDir)
+	assert.NoError(t, err)
+	assert.False(t, binary.Core)
+
+	_, err = FindPlugin("bar", storeDir)
+	assert.IsType(t, ErrPluginBinaryNotFound{}, err)
+}
+
+func TestFindPlugins(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "machine-plugins-")
+	defer os.RemoveAll(dir)
+
+	writePlugin(t, dir, "foo", "")
+	writePlugin(t, dir, "bar", "")
+	if err := os.Mkdir(filepath.Join(dir, pluginBinaryPrefix+"dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, binary := range FindPlugins(dir) {
+		if !binary.Core {
+			names = append(names, binary.DriverName)
+		}
+	}
+
+	assert.Equal(t, []string{"bar", "foo"}, names)
+}
+
+func TestNewPluginChecksum(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "machine-plugins-")
+	defer os.RemoveAll(dir)
+
+	writePlugin(t, dir, "foo", "plugin")
+
+	p, err := NewPluginWithOptions("foo", PluginOptions{Dirs: []string{dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	assert.Equal(t, "5e689e2b01672bf33996e75d5e372ff60c536ce1599a1458e867cd8f4bef5160", p.Checksum())
+
+	pinned, err := NewPluginWithOptions("foo", PluginOptions{Dirs: []string{dir}, Checksum: p.Checksum()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pinned.Close()
+
+	writePlugin(t, dir, "foo", "replaced")
+
+	_, err = NewPluginWithOptions("foo", PluginOptions{Dirs: []string{dir}, Checksum: p.Checksum()})
+	assert.IsType(t, ErrPluginChecksumMismatch{}, err)
+}
+
+func TestExecutorRunsCheckedCopy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+
+	dir, _ := ioutil.TempDir("", "machine-plugins-")
+	defer os.RemoveAll(dir)
+
+	writePlugin(t, dir, "foo", "#!/bin/sh\necho plugin\n")
+
+	copyDir := filepath.Join(dir, "checked")
+	p, err := NewPluginWithOptions("foo", PluginOptions{Dirs: []string{dir}, CopyDir: copyDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := p.Executor.(*Executor)
+
+	out, _, err := executor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(copyDir, p.Checksum(), filepath.Base(executor.binary.Path))
+
+	writePlugin(t, dir, "foo", "#!/bin/sh\necho replaced\n")
+
+	assert.True(t, out.Scan())
+	assert.Equal(t, "plugin", out.Text())
+	assert.NoError(t, executor.Close())
+
+	// The copy is kept for the next launches, which do not copy again.
+	info, err := os.Stat(copied)
+	assert.NoError(t, err)
+
+	out, _, err = executor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, out.Scan())
+	assert.Equal(t, "plugin", out.Text())
+	assert.NoError(t, executor.Close())
+
+	reused, err := os.Stat(copied)
+	assert.NoError(t, err)
+	assert.Equal(t, info.ModTime(), reused.ModTime())
+
+	// A replaced binary is not copied under the checksum it no longer has.
+	assert.NoError(t, os.RemoveAll(copyDir))
+	_, _, err = executor.Start()
+	assert.IsType(t, ErrPluginChecksumMismatch{}, err)
+
+	_, err = os.Stat(copied)
+	assert.True(t, os.IsNotExist(err))
+}
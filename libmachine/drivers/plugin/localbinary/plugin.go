@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net"
+	"net/rpc"
 	"os"
 	"os/exec"
 	"strings"
@@ -24,6 +26,8 @@ var (
 )
 
 const (
+	// pluginOut and pluginErr are how the stdout and stderr lines of plugin
+	// binaries are shown, see log.Record.String.
 	pluginOut           = "(%s) %s"
 	pluginErr           = "(%s) DBG | %s"
 	PluginEnvKey        = "MACHINE_PLUGIN_TOKEN"
@@ -65,6 +69,10 @@ type McnBinaryExecutor interface {
 type DriverPlugin interface {
 	PluginServer
 	PluginStreamer
+
+	// Exited returns a channel which is closed when the plugin binary
+	// exits without having been asked to.
+	Exited() <-chan struct{}
 }
 
 type Plugin struct {
@@ -73,14 +81,20 @@ type Plugin struct {
 	MachineName string
 	addrCh      chan string
 	stopCh      chan struct{}
+	exitCh      chan struct{}
 	timeout     time.Duration
+	transport   *pluginTransport
+	checksum    string
 }
 
 type Executor struct {
 	pluginStdout, pluginStderr io.ReadCloser
 	DriverName                 string
 	cmd                        *exec.Cmd
-	binaryPath                 string
+	binary                     PluginBinary
+	checksum                   string
+	copyDir                    string
+	transport                  *pluginTransport
 }
 
 type ErrPluginBinaryNotFound struct {
@@ -89,55 +103,73 @@ type ErrPluginBinaryNotFound struct {
 }
 
 func (e ErrPluginBinaryNotFound) Error() string {
-	return fmt.Sprintf("Driver %q not found. Do you have the plugin binary %q accessible in your PATH?", e.driverName, e.driverPath)
+	return fmt.Sprintf("Driver %q not found. Do you have the plugin binary %q accessible in your PATH or %s?", e.driverName, e.driverPath, PluginEnvPath)
 }
 
-// driverPath finds the path of a driver binary by its name.
-//   - If the driver is a core driver, there is no separate driver binary. We reuse current binary if it's `docker-machine`
-//
-// or we assume `docker-machine` is in the PATH.
-//   - If the driver is NOT a core driver, then the separate binary must be in the PATH and it's name must be
-//
-// `docker-machine-driver-driverName`
-func driverPath(driverName string) string {
-	for _, coreDriver := range CoreDrivers {
-		if coreDriver == driverName {
-			if CurrentBinaryIsDockerMachine {
-				return os.Args[0]
-			}
+func NewPlugin(driverName string) (*Plugin, error) {
+	return NewPluginWithOptions(driverName, PluginOptions{})
+}
 
-			return "docker-machine"
-		}
+// NewPluginWithOptions is like NewPlugin, but searches opts.Dirs for the
+// plugin binary and refuses to use it if it does not match opts.Checksum.
+func NewPluginWithOptions(driverName string, opts PluginOptions) (*Plugin, error) {
+	binary, err := FindPlugin(driverName, opts.Dirs...)
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Sprintf("docker-machine-driver-%s", driverName)
-}
+	log.Debugf("Found binary path at %s", binary.Path)
 
-func NewPlugin(driverName string) (*Plugin, error) {
-	driverPath := driverPath(driverName)
-	binaryPath, err := exec.LookPath(driverPath)
+	checksum, err := binary.Checksum()
 	if err != nil {
-		return nil, ErrPluginBinaryNotFound{driverName, driverPath}
+		return nil, fmt.Errorf("Error reading plugin binary: %s", err)
 	}
 
-	log.Debugf("Found binary path at %s", binaryPath)
+	if opts.Checksum != "" && opts.Checksum != checksum {
+		return nil, ErrPluginChecksumMismatch{
+			DriverName: driverName,
+			Path:       binary.Path,
+			Expected:   opts.Checksum,
+			Actual:     checksum,
+		}
+	}
+
+	transport, err := newPluginTransport()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Plugin{
-		stopCh: make(chan struct{}),
-		addrCh: make(chan string, 1),
+		stopCh:    make(chan struct{}),
+		addrCh:    make(chan string, 1),
+		exitCh:    make(chan struct{}),
+		transport: transport,
+		checksum:  checksum,
 		Executor: &Executor{
 			DriverName: driverName,
-			binaryPath: binaryPath,
+			binary:     binary,
+			checksum:   checksum,
+			copyDir:    opts.CopyDir,
+			transport:  transport,
 		},
 	}, nil
 }
 
+// Checksum returns the SHA-256 of the plugin binary, which is empty for core
+// drivers, see PluginBinary.Checksum.
+func (lbp *Plugin) Checksum() string {
+	return lbp.checksum
+}
+
 func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 	var err error
 
 	log.Debugf("Launching plugin server for driver %s", lbe.DriverName)
 
-	lbe.cmd = exec.Command(lbe.binaryPath)
+	lbe.cmd, err = lbe.command()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	lbe.pluginStdout, err = lbe.cmd.StdoutPipe()
 	if err != nil {
@@ -159,6 +191,11 @@ func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 		return nil, nil, fmt.Errorf("Error setting env variable: %s", err)
 	}
 
+	// The transport settings are only passed to this plugin binary.
+	if lbe.transport != nil {
+		lbe.cmd.Env = append(os.Environ(), lbe.transport.env()...)
+	}
+
 	if err := lbe.cmd.Start(); err != nil {
 		return nil, nil, fmt.Errorf("Error starting plugin binary: %s", err)
 	}
@@ -166,6 +203,26 @@ func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 	return outScanner, errScanner, nil
 }
 
+// command returns the command running the plugin binary, or its checked
+// copy when the executor has a copy directory, see PluginOptions.CopyDir.
+func (lbe *Executor) command() (*exec.Cmd, error) {
+	if lbe.binary.Core || lbe.copyDir == "" {
+		return exec.Command(lbe.binary.Path), nil
+	}
+
+	path, err := lbe.binary.checkedCopy(lbe.copyDir, lbe.checksum)
+	if err != nil {
+		if _, ok := err.(ErrPluginChecksumMismatch); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error copying plugin binary: %s", err)
+	}
+
+	cmd := exec.Command(path)
+	cmd.Args[0] = lbe.binary.Path
+	return cmd, nil
+}
+
 func (lbe *Executor) Close() error {
 	if err := lbe.cmd.Wait(); err != nil {
 		return fmt.Errorf("Error waiting for binary close: %s", err)
@@ -174,7 +231,11 @@ func (lbe *Executor) Close() error {
 	return nil
 }
 
+// stream sends the lines read by scanner to streamOutCh, which is closed once
+// the stream ends.
 func stream(scanner *bufio.Scanner, streamOutCh chan<- string, stopCh <-chan struct{}) {
+	defer close(streamOutCh)
+
 	for scanner.Scan() {
 		line := scanner.Text()
 		if err := scanner.Err(); err != nil {
@@ -215,10 +276,18 @@ func (lbp *Plugin) execServer() error {
 
 	for {
 		select {
-		case out := <-stdOutCh:
-			log.Infof(pluginOut, lbp.MachineName, out)
-		case err := <-stdErrCh:
-			log.Debugf(pluginErr, lbp.MachineName, err)
+		case out, ok := <-stdOutCh:
+			if !ok {
+				// The plugin binary closed its stdout, i.e. exited.
+				return lbp.exited()
+			}
+			log.LogRecord(log.Record{Level: log.InfoLevel, Time: time.Now(), Machine: lbp.MachineName, Message: out})
+		case err, ok := <-stdErrCh:
+			if !ok {
+				stdErrCh = nil
+				continue
+			}
+			log.LogRecord(log.Record{Level: log.DebugLevel, Time: time.Now(), Machine: lbp.MachineName, Message: err})
 		case <-lbp.stopCh:
 			if err := lbp.Executor.Close(); err != nil {
 				return fmt.Errorf("Error closing local plugin binary: %s", err)
@@ -228,6 +297,28 @@ func (lbp *Plugin) execServer() error {
 	}
 }
 
+// exited reaps the plugin binary once it exited, and notifies Exited unless
+// the plugin was closed on purpose.
+func (lbp *Plugin) exited() error {
+	if err := lbp.Executor.Close(); err != nil {
+		log.Debugf("(%s) Plugin binary exited: %s", lbp.MachineName, err)
+	}
+
+	select {
+	case <-lbp.stopCh:
+	default:
+		if lbp.exitCh != nil {
+			close(lbp.exitCh)
+		}
+	}
+
+	return nil
+}
+
+func (lbp *Plugin) Exited() <-chan struct{} {
+	return lbp.exitCh
+}
+
 func (lbp *Plugin) Serve() error {
 	return lbp.execServer()
 }
@@ -250,7 +341,43 @@ func (lbp *Plugin) Address() (string, error) {
 	return lbp.Addr, nil
 }
 
+// DialRPC connects to the RPC server of the plugin once it reported its
+// address, presenting the secret it was started with.
+func (lbp *Plugin) DialRPC() (*rpc.Client, error) {
+	addr, err := lbp.Address()
+	if err != nil {
+		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	}
+
+	return lbp.getTransport().dial(addr)
+}
+
+// DialConn opens a connection to the plugin server, asking for the RPC
+// protocol served on path. It returns ErrTransportNotSupported if the plugin
+// does not serve path.
+func (lbp *Plugin) DialConn(path string) (net.Conn, error) {
+	addr, err := lbp.Address()
+	if err != nil {
+		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	}
+
+	return lbp.getTransport().dialConn(addr, path)
+}
+
+func (lbp *Plugin) getTransport() *pluginTransport {
+	if lbp.transport == nil {
+		return &pluginTransport{}
+	}
+	return lbp.transport
+}
+
 func (lbp *Plugin) Close() error {
+	if lbp.transport != nil {
+		if err := lbp.transport.cleanup(); err != nil {
+			log.Debugf("Error removing plugin socket: %s", err)
+		}
+	}
+
 	close(lbp.stopCh)
 	return nil
 }
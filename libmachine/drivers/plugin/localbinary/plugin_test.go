@@ -157,3 +157,49 @@ func TestExecServer(t *testing.T) {
 		t.Fatalf("Error serving: %s", err)
 	}
 }
+
+func TestExecServerUnexpectedExit(t *testing.T) {
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+
+	fe := &FakeExecutor{
+		stdout: stdoutReader,
+		stderr: stderrReader,
+	}
+
+	lbp := &Plugin{
+		MachineName: "test",
+		Executor:    fe,
+		addrCh:      make(chan string, 1),
+		stopCh:      make(chan struct{}),
+		exitCh:      make(chan struct{}),
+	}
+
+	finalErr := make(chan error)
+	go func() {
+		finalErr <- lbp.execServer()
+	}()
+
+	if _, err := io.WriteString(stdoutWriter, "127.0.0.1:12345\n"); err != nil {
+		t.Fatalf("Error attempting to write plugin address: %s", err)
+	}
+	<-lbp.addrCh
+
+	// The plugin binary dies
+	_ = stdoutWriter.Close()
+	_ = stderrWriter.Close()
+
+	if err := <-finalErr; err != nil {
+		t.Fatalf("Error serving: %s", err)
+	}
+
+	select {
+	case <-lbp.Exited():
+	default:
+		t.Fatal("Exited should be closed once the plugin binary exited")
+	}
+
+	if !fe.closed {
+		t.Fatal("The plugin binary should have been waited for")
+	}
+}
@@ -0,0 +1,178 @@
+package localbinary
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	// PluginEnvSocket tells the plugin binary the path of the Unix socket
+	// to listen on. Plugins which ignore it listen on TCP instead.
+	PluginEnvSocket = "MACHINE_PLUGIN_SOCKET"
+	// PluginEnvSecret holds the secret which clients of the plugin server
+	// have to present.
+	PluginEnvSecret = "MACHINE_PLUGIN_SECRET"
+	// PluginSecretHeader carries the secret in the request opening the RPC
+	// connection.
+	PluginSecretHeader = "X-Machine-Plugin-Secret"
+
+	unixAddrPrefix = "unix://"
+)
+
+// pluginTransport is how a plugin server is reached: a per-invocation Unix
+// socket where supported, and a secret the client has to present either way.
+type pluginTransport struct {
+	secret     string
+	socketDir  string
+	socketPath string
+}
+
+func newPluginTransport() (*pluginTransport, error) {
+	t := &pluginTransport{
+		secret: mcnutils.GenerateRandomID(),
+	}
+
+	if runtime.GOOS == "windows" {
+		return t, nil
+	}
+
+	dir, err := ioutil.TempDir("", "machine-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("Error creating plugin socket directory: %s", err)
+	}
+	t.socketDir = dir
+	t.socketPath = filepath.Join(dir, "plugin.sock")
+
+	return t, nil
+}
+
+// env returns the environment variables telling the plugin binary how to
+// listen.
+func (t *pluginTransport) env() []string {
+	env := []string{fmt.Sprintf("%s=%s", PluginEnvSecret, t.secret)}
+	if t.socketPath != "" {
+		env = append(env, fmt.Sprintf("%s=%s", PluginEnvSocket, t.socketPath))
+	}
+	return env
+}
+
+func (t *pluginTransport) cleanup() error {
+	if t.socketDir == "" {
+		return nil
+	}
+	return os.RemoveAll(t.socketDir)
+}
+
+// ErrTransportNotSupported is returned when the plugin server does not serve
+// RPC connections on the requested path.
+type ErrTransportNotSupported struct {
+	Path string
+}
+
+func (e ErrTransportNotSupported) Error() string {
+	return fmt.Sprintf("Plugin server does not serve RPC connections on %s", e.Path)
+}
+
+// dial connects to the Go RPC server listening at addr, which is either a TCP
+// address or a Unix socket path prefixed with "unix://".
+func (t *pluginTransport) dial(addr string) (*rpc.Client, error) {
+	conn, err := t.dialConn(addr, rpc.DefaultRPCPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewClient(conn), nil
+}
+
+// dialConn opens a connection to the RPC server listening at addr on path,
+// which is left to the caller to speak the protocol of path over.
+func (t *pluginTransport) dialConn(addr, path string) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, unixAddrPrefix) {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, unixAddrPrefix)
+	}
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same as rpc.DialHTTP, with the secret added to the request.
+	if _, err := io.WriteString(conn, fmt.Sprintf("CONNECT %s HTTP/1.0\n%s: %s\n\n", path, PluginSecretHeader, t.secret)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.StatusCode == http.StatusNotFound {
+		_ = conn.Close()
+		return nil, ErrTransportNotSupported{path}
+	}
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("Error connecting to the plugin server at %s: %s", addr, err)
+	}
+
+	return conn, nil
+}
+
+// Listen opens the listener of a plugin server, on the Unix socket requested
+// by the client if any, and on a TCP port of the loopback interface
+// otherwise. It returns the address to report to the client.
+func Listen() (net.Listener, string, error) {
+	socketPath := os.Getenv(PluginEnvSocket)
+	if socketPath == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, "", err
+		}
+		return listener, listener.Addr().String(), nil
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		_ = listener.Close()
+		return nil, "", err
+	}
+
+	return listener, unixAddrPrefix + socketPath, nil
+}
+
+// RequireSecret wraps the handler of a plugin server so that it rejects
+// requests without the secret the client passed in the environment. Without
+// a secret, i.e. when started by an older client, h is returned unchanged.
+func RequireSecret(h http.Handler) http.Handler {
+	secret := os.Getenv(PluginEnvSecret)
+	if secret == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(PluginSecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
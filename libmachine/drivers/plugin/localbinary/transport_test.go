@@ -0,0 +1,88 @@
+package localbinary
+
+import (
+	"net/http"
+	"net/rpc"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Echo struct{}
+
+func (e *Echo) Echo(in string, out *string) error {
+	*out = in
+	return nil
+}
+
+// serveEcho serves an RPC server the way a plugin does, with the transport
+// settings found in the environment.
+func serveEcho(t *testing.T) string {
+	server := rpc.NewServer()
+	if err := server.Register(&Echo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, addr, err := Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = http.Serve(listener, RequireSecret(server)) }()
+
+	return addr
+}
+
+func echo(client *rpc.Client) (string, error) {
+	var out string
+	err := client.Call("Echo.Echo", "hello", &out)
+	return out, err
+}
+
+func TestPluginTransportUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Plugins listen on TCP on Windows")
+	}
+
+	transport, err := newPluginTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.cleanup()
+
+	for _, env := range transport.env() {
+		kv := strings.SplitN(env, "=", 2)
+		os.Setenv(kv[0], kv[1])
+		defer os.Unsetenv(kv[0])
+	}
+
+	addr := serveEcho(t)
+	assert.Equal(t, unixAddrPrefix+transport.socketPath, addr)
+
+	fi, err := os.Stat(transport.socketPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	client, err := transport.dial(addr)
+	assert.NoError(t, err)
+	out, err := echo(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", out)
+
+	_, err = (&pluginTransport{secret: "wrong"}).dial(addr)
+	assert.Error(t, err)
+}
+
+func TestPluginTransportTCPFallback(t *testing.T) {
+	addr := serveEcho(t)
+	assert.False(t, strings.HasPrefix(addr, unixAddrPrefix))
+
+	transport := &pluginTransport{secret: "unused by older plugins"}
+	client, err := transport.dial(addr)
+	assert.NoError(t, err)
+	out, err := echo(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", out)
+}
@@ -2,7 +2,6 @@ package plugin
 
 import (
 	"fmt"
-	"net"
 	"net/http"
 	"net/rpc"
 	"os"
@@ -32,7 +31,12 @@ Please use this plugin through the main 'docker-machine' binary.
 	log.SetDebug(true)
 	_ = os.Setenv("MACHINE_DEBUG", "1")
 
+	// Logs go to stdout and stderr until the client starts streaming them.
+	logs := rpcdriver.NewLogStreamer(log.GetLogger())
+	log.SetLogger(logs)
+
 	rpcd := rpcdriver.NewRPCServerDriver(d)
+	rpcd.Logs = logs
 	if err := rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd); err != nil {
 		fmt.Fprintf(os.Stderr, "Error registering RPC name: %s\n", err)
 	}
@@ -40,15 +44,16 @@ Please use this plugin through the main 'docker-machine' binary.
 		fmt.Fprintf(os.Stderr, "Error registering RPC name: %s\n", err)
 	}
 	rpc.HandleHTTP()
+	http.Handle(rpcdriver.JSONRPCPath, rpcdriver.NewJSONRPCHandler(rpc.DefaultServer))
 
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	listener, addr, err := localbinary.Listen()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading RPC server: %s\n", err)
 		os.Exit(1)
 	}
 	defer listener.Close()
 
-	fmt.Println(listener.Addr())
+	fmt.Println(addr)
 
 	go func() {
 		for {
@@ -66,5 +71,5 @@ Please use this plugin through the main 'docker-machine' binary.
 	}()
 
 	// http.Serve never returns
-	_ = http.Serve(listener, nil)
+	_ = http.Serve(listener, localbinary.RequireSecret(http.DefaultServeMux))
 }
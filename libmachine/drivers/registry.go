@@ -0,0 +1,59 @@
+package drivers
+
+import (
+	"sort"
+	"sync"
+)
+
+// Factory returns a new, unconfigured instance of a driver. The config of a
+// machine is then loaded into it by unmarshalling its raw JSON, the same way
+// a plugin binary receives it through SetConfigRaw.
+type Factory func() Driver
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]Factory)
+)
+
+// Register makes a driver compiled into the current binary available under
+// name. Hosts using a registered driver are run in-process instead of through
+// a docker-machine-driver-<name> plugin binary. Registering the same name
+// twice replaces the previous factory.
+func Register(name string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry[name] = factory
+}
+
+// Unregister removes the factory registered under name, if any, so that the
+// plugin binary is used again.
+func Unregister(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	delete(registry, name)
+}
+
+// Lookup returns the factory registered under name.
+func Lookup(name string) (Factory, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	factory, ok := registry[name]
+	return factory, ok
+}
+
+// Registered returns the sorted names of the in-process drivers.
+func Registered() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
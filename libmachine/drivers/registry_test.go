@@ -0,0 +1,27 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	defer Unregister("mock")
+
+	_, ok := Lookup("mock")
+	assert.False(t, ok)
+
+	Register("mock", func() Driver { return &MockDriver{calls: &CallRecorder{}, driverName: "mock"} })
+
+	factory, ok := Lookup("mock")
+	assert.True(t, ok)
+	assert.Equal(t, "mock", factory().DriverName())
+	assert.Contains(t, Registered(), "mock")
+
+	Unregister("mock")
+
+	_, ok = Lookup("mock")
+	assert.False(t, ok)
+	assert.NotContains(t, Registered(), "mock")
+}
@@ -1,8 +1,10 @@
 package rpc
 
 import (
+	"context"
 	"fmt"
 	"net/rpc"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/version"
 )
@@ -22,18 +25,35 @@ var (
 
 type RPCClientDriverFactory interface { //nolint:revive
 	NewRPCClientDriver(driverName string, rawDriver []byte) (*RPCClientDriver, error)
+
+	// NewPinnedRPCClientDriver is like NewRPCClientDriver, but refuses to
+	// start a plugin binary whose SHA-256 is not checksum.
+	NewPinnedRPCClientDriver(driverName string, rawDriver []byte, checksum string) (*RPCClientDriver, error)
+
 	io.Closer
 }
 
 type DefaultRPCClientDriverFactory struct { //nolint:revive
 	openedDrivers     []*RPCClientDriver
 	openedDriversLock sync.Locker
+	pluginOpts        localbinary.PluginOptions
 }
 
-func NewRPCClientDriverFactory() RPCClientDriverFactory { //nolint:revive
+// NewRPCClientDriverFactory returns a factory which looks up plugin binaries
+// in pluginDirs before $PATH, see localbinary.SearchPath.
+func NewRPCClientDriverFactory(pluginDirs ...string) RPCClientDriverFactory { //nolint:revive
+	return NewRPCClientDriverFactoryWithOptions(localbinary.PluginOptions{Dirs: pluginDirs})
+}
+
+// NewRPCClientDriverFactoryWithOptions returns a factory which finds and runs
+// plugin binaries as told by opts, whose Checksum is ignored.
+func NewRPCClientDriverFactoryWithOptions(opts localbinary.PluginOptions) RPCClientDriverFactory { //nolint:revive
+	opts.Checksum = ""
+
 	return &DefaultRPCClientDriverFactory{
 		openedDrivers:     []*RPCClientDriver{},
 		openedDriversLock: &sync.Mutex{},
+		pluginOpts:        opts,
 	}
 }
 
@@ -41,6 +61,16 @@ type RPCClientDriver struct { //nolint:revive
 	plugin          localbinary.DriverPlugin
 	heartbeatDoneCh chan bool
 	Client          *InternalClient
+	capabilities    []drivers.Capability
+	checksum        string
+
+	// launch starts a new plugin server when the current one crashed, see
+	// restart. config is the last known driver config, replayed then.
+	launch      func() (localbinary.DriverPlugin, *rpc.Client, error)
+	config      []byte
+	restartLock sync.Mutex
+	generation  int
+	closed      bool
 }
 
 type RPCCall struct { //nolint:revive
@@ -53,49 +83,85 @@ type InternalClient struct {
 	MachineName    string
 	RPCClient      *rpc.Client
 	rpcServiceName string
+	lock           sync.RWMutex
 }
 
 const (
 	RPCServiceNameV0 = `RpcServerDriver`
 	RPCServiceNameV1 = `RPCServerDriver`
 
-	HeartbeatMethod          = `.Heartbeat`
-	GetVersionMethod         = `.GetVersion`
-	CloseMethod              = `.Close`
-	GetCreateFlagsMethod     = `.GetCreateFlags`
-	SetConfigRawMethod       = `.SetConfigRaw`
-	GetConfigRawMethod       = `.GetConfigRaw`
-	DriverNameMethod         = `.DriverName`
-	SetConfigFromFlagsMethod = `.SetConfigFromFlags`
-	GetURLMethod             = `.GetURL`
-	GetMachineNameMethod     = `.GetMachineName`
-	GetIPMethod              = `.GetIP`
-	GetSSHHostnameMethod     = `.GetSSHHostname`
-	GetSSHKeyPathMethod      = `.GetSSHKeyPath`
-	GetSSHPortMethod         = `.GetSSHPort`
-	GetSSHUsernameMethod     = `.GetSSHUsername`
-	GetStateMethod           = `.GetState`
-	PreCreateCheckMethod     = `.PreCreateCheck`
-	CreateMethod             = `.Create`
-	RemoveMethod             = `.Remove`
-	StartMethod              = `.Start`
-	StopMethod               = `.Stop`
-	RestartMethod            = `.Restart`
-	KillMethod               = `.Kill`
-	UpgradeMethod            = `.Upgrade`
+	HeartbeatMethod            = `.Heartbeat`
+	GetVersionMethod           = `.GetVersion`
+	GetCapabilitiesMethod      = `.GetCapabilities`
+	ReadLogsMethod             = `.ReadLogs`
+	CloseMethod                = `.Close`
+	GetCreateFlagsMethod       = `.GetCreateFlags`
+	SetConfigRawMethod         = `.SetConfigRaw`
+	GetConfigRawMethod         = `.GetConfigRaw`
+	DriverNameMethod           = `.DriverName`
+	SetConfigFromFlagsMethod   = `.SetConfigFromFlags`
+	GetURLMethod               = `.GetURL`
+	GetMachineNameMethod       = `.GetMachineName`
+	GetIPMethod                = `.GetIP`
+	GetSSHHostnameMethod       = `.GetSSHHostname`
+	GetSSHKeyPathMethod        = `.GetSSHKeyPath`
+	GetSSHKnownHostsPathMethod = `.GetSSHKnownHostsPath`
+	GetSSHPortMethod           = `.GetSSHPort`
+	GetSSHUsernameMethod       = `.GetSSHUsername`
+	GetStateMethod             = `.GetState`
+	PreCreateCheckMethod       = `.PreCreateCheck`
+	CreateMethod               = `.Create`
+	RemoveMethod               = `.Remove`
+	StartMethod                = `.Start`
+	StopMethod                 = `.Stop`
+	RestartMethod              = `.Restart`
+	KillMethod                 = `.Kill`
+	UpgradeMethod              = `.Upgrade`
+	CancelCallMethod           = `.CancelCall`
+	CreateContextMethod        = `.CreateContext`
+	GetStateContextMethod      = `.GetStateContext`
+	KillContextMethod          = `.KillContext`
+	RemoveContextMethod        = `.RemoveContext`
+	RestartContextMethod       = `.RestartContext`
+	StartContextMethod         = `.StartContext`
+	StopContextMethod          = `.StopContext`
+	TakeSnapshotMethod         = `.TakeSnapshot`
+	ListSnapshotsMethod        = `.ListSnapshots`
+	RestoreSnapshotMethod      = `.RestoreSnapshot`
+	DeleteSnapshotMethod       = `.DeleteSnapshot`
 )
 
 func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
-	if serviceMethod != HeartbeatMethod {
+	if serviceMethod != HeartbeatMethod && serviceMethod != ReadLogsMethod {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
 	}
+	ic.lock.RLock()
+	defer ic.lock.RUnlock()
 	return ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply)
 }
 
+// Go invokes the method asynchronously, see rpc.Client.Go.
+func (ic *InternalClient) Go(serviceMethod string, args interface{}, reply interface{}) *rpc.Call {
+	log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
+	ic.lock.RLock()
+	defer ic.lock.RUnlock()
+	return ic.RPCClient.Go(ic.rpcServiceName+serviceMethod, args, reply, make(chan *rpc.Call, 1))
+}
+
 func (ic *InternalClient) switchToV0() {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
 	ic.rpcServiceName = RPCServiceNameV0
 }
 
+// setRPCClient replaces the connection to a plugin server which crashed.
+func (ic *InternalClient) setRPCClient(rpcclient *rpc.Client) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	_ = ic.RPCClient.Close()
+	ic.RPCClient = rpcclient
+}
+
 func NewInternalClient(rpcclient *rpc.Client) *InternalClient {
 	return &InternalClient{
 		RPCClient:      rpcclient,
@@ -118,27 +184,15 @@ func (f *DefaultRPCClientDriverFactory) Close() error {
 }
 
 func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, rawDriver []byte) (*RPCClientDriver, error) {
-	mcnName := ""
-
-	p, err := localbinary.NewPlugin(driverName)
-	if err != nil {
-		return nil, err
-	}
-
-	go func() {
-		if err := p.Serve(); err != nil {
-			// TODO: Is this best approach?
-			log.Warn(err)
-			return
-		}
-	}()
+	return f.NewPinnedRPCClientDriver(driverName, rawDriver, "")
+}
 
-	addr, err := p.Address()
-	if err != nil {
-		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
-	}
+func (f *DefaultRPCClientDriverFactory) NewPinnedRPCClientDriver(driverName string, rawDriver []byte, checksum string) (*RPCClientDriver, error) {
+	mcnName := ""
+	opts := f.pluginOpts
+	opts.Checksum = checksum
 
-	rpcclient, err := rpc.DialHTTP("tcp", addr)
+	p, rpcclient, err := launchPlugin(driverName, mcnName, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -146,12 +200,42 @@ func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, ra
 	c := &RPCClientDriver{
 		Client:          NewInternalClient(rpcclient),
 		heartbeatDoneCh: make(chan bool),
+		plugin:          p,
+		checksum:        p.Checksum(),
+	}
+	// A restarted plugin must be the very binary which was first started.
+	opts.Checksum = c.checksum
+	c.launch = func() (localbinary.DriverPlugin, *rpc.Client, error) {
+		return launchPlugin(driverName, c.Client.MachineName, opts)
 	}
 
 	f.openedDriversLock.Lock()
 	f.openedDrivers = append(f.openedDrivers, c)
 	f.openedDriversLock.Unlock()
 
+	if err := c.handshake(); err != nil {
+		return nil, err
+	}
+
+	go c.heartbeat()
+
+	if err := c.SetConfigRaw(rawDriver); err != nil {
+		return nil, err
+	}
+
+	mcnName = c.GetMachineName()
+	p.MachineName = mcnName
+	c.Client.MachineName = mcnName
+
+	if drivers.Supports(c, drivers.CapabilityLogStream) {
+		go c.streamLogs()
+	}
+
+	return c, nil
+}
+
+// getVersion returns the API version of a newly started plugin server.
+func (c *RPCClientDriver) getVersion() (int, error) {
 	var serverVersion int
 	if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
 		// this is the first call we make to the server. We try to play nice with old pre 0.5.1 client,
@@ -160,41 +244,26 @@ func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, ra
 		log.Debugf("Client (%s) with %s does not work, re-attempting with %s", c.Client.MachineName, RPCServiceNameV1, RPCServiceNameV0)
 		c.Client.switchToV0()
 		if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
-			return nil, err
+			return 0, err
 		}
 	}
 
-	if serverVersion != version.APIVersion {
-		return nil, fmt.Errorf("Driver binary uses an incompatible API version (%d)", serverVersion)
-	}
-	log.Debug("Using API Version ", serverVersion)
-
-	go func(c *RPCClientDriver) {
-		for {
-			select {
-			case <-c.heartbeatDoneCh:
-				return
-			case <-time.After(heartbeatInterval):
-				if err := c.Client.Call(HeartbeatMethod, struct{}{}, nil); err != nil {
-					log.Warnf("Wrapper Docker Machine process exiting due to closed plugin server (%s)", err)
-					if err := c.close(); err != nil {
-						log.Warn(err)
-					}
-				}
-			}
-		}
-	}(c)
+	return serverVersion, nil
+}
 
-	if err := c.SetConfigRaw(rawDriver); err != nil {
-		return nil, err
+// handshake agrees with a newly started plugin server on the protocol.
+func (c *RPCClientDriver) handshake() error {
+	serverVersion, err := c.getVersion()
+	if err != nil {
+		return err
 	}
 
-	mcnName = c.GetMachineName()
-	p.MachineName = mcnName
-	c.Client.MachineName = mcnName
-	c.plugin = p
+	if serverVersion != version.APIVersion {
+		return fmt.Errorf("Driver binary uses an incompatible API version (%d)", serverVersion)
+	}
+	log.Debug("Using API Version ", serverVersion)
 
-	return c, nil
+	return c.negotiateCapabilities()
 }
 
 func (c *RPCClientDriver) MarshalJSON() ([]byte, error) {
@@ -206,7 +275,10 @@ func (c *RPCClientDriver) UnmarshalJSON(data []byte) error {
 }
 
 func (c *RPCClientDriver) close() error {
-	c.heartbeatDoneCh <- true
+	c.restartLock.Lock()
+	c.closed = true
+	c.restartLock.Unlock()
+
 	close(c.heartbeatDoneCh)
 
 	log.Debug("Making call to close driver server")
@@ -219,7 +291,7 @@ func (c *RPCClientDriver) close() error {
 
 	log.Debug("Making call to close connection to plugin binary")
 
-	return c.plugin.Close()
+	return c.currentPlugin().Close()
 }
 
 // Helper method to make requests which take no arguments and return simply a
@@ -227,7 +299,7 @@ func (c *RPCClientDriver) close() error {
 func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 	var info string
 
-	if err := c.Client.Call(method, struct{}{}, &info); err != nil {
+	if err := c.call(method, struct{}{}, &info); err != nil {
 		return "", err
 	}
 
@@ -237,7 +309,7 @@ func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 	var flags []mcnflag.Flag
 
-	if err := c.Client.Call(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
+	if err := c.call(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
 		log.Warnf("Error attempting call to get create flags: %s", err)
 	}
 
@@ -245,13 +317,21 @@ func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 }
 
 func (c *RPCClientDriver) SetConfigRaw(data []byte) error {
-	return c.Client.Call(SetConfigRawMethod, data, nil)
+	if err := c.call(SetConfigRawMethod, data, nil); err != nil {
+		return err
+	}
+
+	c.restartLock.Lock()
+	c.config = data
+	c.restartLock.Unlock()
+
+	return nil
 }
 
 func (c *RPCClientDriver) GetConfigRaw() ([]byte, error) {
 	var data []byte
 
-	if err := c.Client.Call(GetConfigRawMethod, struct{}{}, &data); err != nil {
+	if err := c.call(GetConfigRawMethod, struct{}{}, &data); err != nil {
 		return nil, err
 	}
 
@@ -268,8 +348,16 @@ func (c *RPCClientDriver) DriverName() string {
 	return driverName
 }
 
+// SetConfigFromFlags validates the values of the create flags before sending
+// them, so that invalid values are reported in an ErrInvalidFlags.
 func (c *RPCClientDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
-	return c.Client.Call(SetConfigFromFlagsMethod, &flags, nil)
+	if rpcFlags, ok := flags.(*RPCFlags); ok {
+		if err := drivers.ValidateFlagValues(c.GetCreateFlags(), rpcFlags.Values); err != nil {
+			return err
+		}
+	}
+
+	return c.call(SetConfigFromFlagsMethod, &flags, nil)
 }
 
 func (c *RPCClientDriver) GetURL() (string, error) {
@@ -304,10 +392,21 @@ func (c *RPCClientDriver) GetSSHKeyPath() string {
 	return path
 }
 
+// GetSSHKnownHostsPath returns the known_hosts file of the machine. Host keys
+// are not verified for plugins built before host key verification.
+func (c *RPCClientDriver) GetSSHKnownHostsPath() string {
+	path, err := c.rpcStringCall(GetSSHKnownHostsPathMethod)
+	if err != nil && !isMethodNotFound(err) {
+		log.Warnf("Error attempting call to get SSH known hosts path: %s", err)
+	}
+
+	return path
+}
+
 func (c *RPCClientDriver) GetSSHPort() (int, error) {
 	var port int
 
-	if err := c.Client.Call(GetSSHPortMethod, struct{}{}, &port); err != nil {
+	if err := c.call(GetSSHPortMethod, struct{}{}, &port); err != nil {
 		return 0, err
 	}
 
@@ -326,7 +425,7 @@ func (c *RPCClientDriver) GetSSHUsername() string {
 func (c *RPCClientDriver) GetState() (state.State, error) {
 	var s state.State
 
-	if err := c.Client.Call(GetStateMethod, struct{}{}, &s); err != nil {
+	if err := c.call(GetStateMethod, struct{}{}, &s); err != nil {
 		return state.Error, err
 	}
 
@@ -334,33 +433,225 @@ func (c *RPCClientDriver) GetState() (state.State, error) {
 }
 
 func (c *RPCClientDriver) PreCreateCheck() error {
-	return c.Client.Call(PreCreateCheckMethod, struct{}{}, nil)
+	return c.call(PreCreateCheckMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Create() error {
-	return c.Client.Call(CreateMethod, struct{}{}, nil)
+	return c.call(CreateMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Remove() error {
-	return c.Client.Call(RemoveMethod, struct{}{}, nil)
+	return c.call(RemoveMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Start() error {
-	return c.Client.Call(StartMethod, struct{}{}, nil)
+	return c.call(StartMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Stop() error {
-	return c.Client.Call(StopMethod, struct{}{}, nil)
+	return c.call(StopMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Restart() error {
-	return c.Client.Call(RestartMethod, struct{}{}, nil)
+	return c.call(RestartMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Kill() error {
-	return c.Client.Call(KillMethod, struct{}{}, nil)
+	return c.call(KillMethod, struct{}{}, nil)
 }
 
 func (c *RPCClientDriver) Upgrade() error {
-	return c.Client.Call(UpgradeMethod, struct{}{}, nil)
+	return c.call(UpgradeMethod, struct{}{}, nil)
+}
+
+// isMethodNotFound tells whether err was returned by a plugin server which
+// does not know the called method, e.g. because it predates it.
+func isMethodNotFound(err error) bool {
+	serverErr, ok := err.(rpc.ServerError)
+	return ok && strings.HasPrefix(string(serverErr), "rpc: can't find method ")
+}
+
+// rpcContextCall makes a call which the server aborts when ctx is cancelled
+// or its deadline expires, returning once the server confirmed it. Plugins
+// built before context support are called through fallbackMethod instead, and
+// are only waited on until ctx is done.
+func (c *RPCClientDriver) rpcContextCall(ctx context.Context, method, fallbackMethod string, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	args := &ContextArgs{
+		CallID: mcnutils.GenerateRandomID(),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		args.Deadline = deadline
+	}
+
+	generation := c.currentGeneration()
+	call := c.Client.Go(method, args, reply)
+
+	select {
+	case <-call.Done:
+		if isMethodNotFound(call.Error) {
+			log.Debugf("Plugin server does not support %s, falling back to %s", method, fallbackMethod)
+			return drivers.RunContext(ctx, func() error {
+				return c.call(fallbackMethod, struct{}{}, reply)
+			})
+		}
+		if isConnectionError(call.Error) {
+			return c.recover(generation, method, call.Error, nil)
+		}
+		if call.Error == nil && method != GetStateContextMethod {
+			c.refreshConfig()
+		}
+		return call.Error
+	case <-ctx.Done():
+		if err := c.Client.Call(CancelCallMethod, &args.CallID, nil); err != nil {
+			log.Debugf("Failed to cancel call to %s: %s", method, err)
+			return ctx.Err()
+		}
+		// Wait for the server to confirm the driver gave up, so that a
+		// drivers.SerialDriver does not start its next call alongside.
+		<-call.Done
+		return ctx.Err()
+	}
+}
+
+func (c *RPCClientDriver) CreateContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, CreateContextMethod, CreateMethod, nil)
+}
+
+func (c *RPCClientDriver) GetStateContext(ctx context.Context) (state.State, error) {
+	var s state.State
+
+	if err := c.rpcContextCall(ctx, GetStateContextMethod, GetStateMethod, &s); err != nil {
+		return state.Error, err
+	}
+
+	return s, nil
+}
+
+func (c *RPCClientDriver) KillContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, KillContextMethod, KillMethod, nil)
+}
+
+func (c *RPCClientDriver) RemoveContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, RemoveContextMethod, RemoveMethod, nil)
+}
+
+func (c *RPCClientDriver) RestartContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, RestartContextMethod, RestartMethod, nil)
+}
+
+func (c *RPCClientDriver) StartContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, StartContextMethod, StartMethod, nil)
+}
+
+func (c *RPCClientDriver) StopContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, StopContextMethod, StopMethod, nil)
+}
+
+// negotiateCapabilities asks the plugin server for the optional features of
+// its driver. Plugins built before capabilities were introduced are assumed to
+// support none. It runs before the driver is shared or with restartLock held.
+func (c *RPCClientDriver) negotiateCapabilities() error {
+	var capabilities []drivers.Capability
+
+	if err := c.Client.Call(GetCapabilitiesMethod, struct{}{}, &capabilities); err != nil {
+		if !isMethodNotFound(err) {
+			return err
+		}
+		log.Debug("Plugin server does not report capabilities, assuming none")
+	}
+
+	log.Debugf("Plugin server capabilities: %v", capabilities)
+	c.capabilities = capabilities
+	return nil
+}
+
+// streamLogs re-emits the structured logs of the plugin until the connection
+// to it is closed. Plugins without log streaming have their output scraped by
+// localbinary instead.
+func (c *RPCClientDriver) streamLogs() {
+	for {
+		var records []log.Record
+		if err := c.call(ReadLogsMethod, struct{}{}, &records); err != nil {
+			log.Debugf("Stopped reading the logs of the plugin server: %s", err)
+			return
+		}
+
+		for _, r := range records {
+			if r.Machine == "" {
+				r.Machine = c.Client.MachineName
+			}
+			log.LogRecord(r)
+		}
+	}
+}
+
+// Capabilities returns the optional features of the driver in the plugin.
+func (c *RPCClientDriver) Capabilities() []drivers.Capability {
+	return c.currentCapabilities()
+}
+
+// PluginChecksum returns the SHA-256 of the plugin binary, to be pinned in the
+// host config. It is empty for core drivers.
+func (c *RPCClientDriver) PluginChecksum() string {
+	return c.checksum
+}
+
+// checkCapability returns ErrNotSupported, without calling the plugin, if its
+// driver lacks capability.
+func (c *RPCClientDriver) checkCapability(capability drivers.Capability) error {
+	for _, supported := range c.currentCapabilities() {
+		if supported == capability {
+			return nil
+		}
+	}
+	return drivers.ErrNotSupported{
+		DriverName: c.DriverName(),
+		Capability: capability,
+	}
+}
+
+func (c *RPCClientDriver) TakeSnapshot(name, description string) error {
+	if err := c.checkCapability(drivers.CapabilitySnapshots); err != nil {
+		return err
+	}
+
+	args := &SnapshotArgs{
+		Name:        name,
+		Description: description,
+	}
+	return c.call(TakeSnapshotMethod, args, nil)
+}
+
+func (c *RPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	if err := c.checkCapability(drivers.CapabilitySnapshots); err != nil {
+		return nil, err
+	}
+
+	var snapshots []drivers.Snapshot
+
+	if err := c.call(ListSnapshotsMethod, struct{}{}, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+func (c *RPCClientDriver) RestoreSnapshot(name string) error {
+	if err := c.checkCapability(drivers.CapabilitySnapshots); err != nil {
+		return err
+	}
+
+	return c.call(RestoreSnapshotMethod, &name, nil)
+}
+
+func (c *RPCClientDriver) DeleteSnapshot(name string) error {
+	if err := c.checkCapability(drivers.CapabilitySnapshots); err != nil {
+		return err
+	}
+
+	return c.call(DeleteSnapshotMethod, &name, nil)
 }
@@ -0,0 +1,315 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceDriver implements everything a driver can, so that every
+// method of the plugin server can be exercised.
+type conformanceDriver struct {
+	*fakedriver.Driver
+	Options   map[string]interface{}
+	Snapshots []drivers.Snapshot
+}
+
+func newConformanceDriver() *conformanceDriver {
+	return &conformanceDriver{
+		Driver: &fakedriver.Driver{
+			BaseDriver: &drivers.BaseDriver{},
+			MockState:  state.Running,
+			MockIP:     "1.2.3.4",
+			MockName:   "conformance",
+		},
+	}
+}
+
+func (d *conformanceDriver) DriverName() string {
+	return "conformance"
+}
+
+func (d *conformanceDriver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "conformance-string", Usage: "a string", EnvVar: "CONFORMANCE_STRING", Value: "default"},
+		mcnflag.StringSliceFlag{Name: "conformance-string-slice", Value: []string{"a", "b"}},
+		mcnflag.IntFlag{Name: "conformance-int", Value: 42},
+		mcnflag.BoolFlag{Name: "conformance-bool"},
+		mcnflag.SizeFlag{Name: "conformance-size", Value: 1024},
+	}
+}
+
+func (d *conformanceDriver) SetConfigFromFlags(opts drivers.DriverOptions) error {
+	d.Options = map[string]interface{}{
+		"conformance-string":       opts.String("conformance-string"),
+		"conformance-string-slice": opts.StringSlice("conformance-string-slice"),
+		"conformance-int":          opts.Int("conformance-int"),
+		"conformance-bool":         opts.Bool("conformance-bool"),
+		"conformance-size":         opts.String("conformance-size"),
+	}
+	return nil
+}
+
+func (d *conformanceDriver) Kill() error {
+	return errors.New("kill failed")
+}
+
+func (d *conformanceDriver) TakeSnapshot(name, description string) error {
+	d.Snapshots = append(d.Snapshots, drivers.Snapshot{Name: name, Description: description, Current: true})
+	return nil
+}
+
+func (d *conformanceDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.Snapshots, nil
+}
+
+func (d *conformanceDriver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *conformanceDriver) DeleteSnapshot(name string) error {
+	d.Snapshots = nil
+	return nil
+}
+
+func newConformanceClient(t *testing.T, transport string, d drivers.Driver) *RPCClientDriver {
+	c, err := NewInProcessRPCClientDriver(d, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// TestConformance runs the same calls over every transport, which have to
+// give the same results as calling the driver directly.
+func TestConformance(t *testing.T) {
+	for _, transport := range []string{TransportGob, TransportJSONRPC} {
+		t.Run(transport, func(t *testing.T) {
+			d := newConformanceDriver()
+			c := newConformanceClient(t, transport, d)
+			expected := newConformanceDriver()
+
+			assert.Equal(t, expected.DriverName(), c.DriverName())
+			assert.Equal(t, expected.GetMachineName(), c.GetMachineName())
+			assert.Equal(t, expected.GetSSHKeyPath(), c.GetSSHKeyPath())
+			assert.Equal(t, expected.GetSSHKnownHostsPath(), c.GetSSHKnownHostsPath())
+			assert.Equal(t, expected.GetSSHUsername(), c.GetSSHUsername())
+			assert.Equal(t, derefFlags(expected.GetCreateFlags()), derefFlags(c.GetCreateFlags()))
+			assert.ElementsMatch(t, drivers.Capabilities(expected), c.Capabilities())
+
+			for _, call := range []struct {
+				method string
+				f      func(drivers.Driver) (interface{}, error)
+			}{
+				{"GetIP", func(d drivers.Driver) (interface{}, error) { return d.GetIP() }},
+				{"GetSSHHostname", func(d drivers.Driver) (interface{}, error) { return d.GetSSHHostname() }},
+				{"GetSSHPort", func(d drivers.Driver) (interface{}, error) { return d.GetSSHPort() }},
+				{"GetURL", func(d drivers.Driver) (interface{}, error) { return d.GetURL() }},
+				{"GetState", func(d drivers.Driver) (interface{}, error) { return d.GetState() }},
+				{"PreCreateCheck", func(d drivers.Driver) (interface{}, error) { return nil, d.PreCreateCheck() }},
+				{"Create", func(d drivers.Driver) (interface{}, error) { return nil, d.Create() }},
+				{"Start", func(d drivers.Driver) (interface{}, error) { return nil, d.Start() }},
+				{"Stop", func(d drivers.Driver) (interface{}, error) { return nil, d.Stop() }},
+				{"Restart", func(d drivers.Driver) (interface{}, error) { return nil, d.Restart() }},
+				{"Remove", func(d drivers.Driver) (interface{}, error) { return nil, d.Remove() }},
+			} {
+				expectedValue, expectedErr := call.f(expected)
+				value, err := call.f(c)
+				assert.Equal(t, expectedValue, value, call.method)
+				assert.Equal(t, expectedErr, err, call.method)
+			}
+
+			err := c.Kill()
+			assert.Equal(t, rpc.ServerError("kill failed"), err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			s, err := c.GetStateContext(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, state.Running, s)
+			assert.NoError(t, c.StartContext(ctx))
+			assert.Equal(t, rpc.ServerError("kill failed"), c.KillContext(ctx))
+
+			opts := &RPCFlags{Values: map[string]interface{}{
+				"conformance-string":       "value",
+				"conformance-string-slice": []string{"c"},
+				"conformance-int":          7,
+				"conformance-bool":         true,
+				"conformance-size":         "2GB",
+			}}
+			assert.NoError(t, c.SetConfigFromFlags(opts))
+			assert.Equal(t, opts.Values, d.Options)
+
+			invalid := &RPCFlags{Values: map[string]interface{}{
+				"conformance-int":  "7",
+				"conformance-size": "2PB",
+			}}
+			err = c.SetConfigFromFlags(invalid)
+			assert.IsType(t, drivers.ErrInvalidFlags{}, err)
+			assert.Len(t, err.(drivers.ErrInvalidFlags).Flags, 2)
+			assert.Equal(t, opts.Values, d.Options)
+
+			config, err := c.GetConfigRaw()
+			assert.NoError(t, err)
+			expectedConfig, _ := json.Marshal(d)
+			assert.JSONEq(t, string(expectedConfig), string(config))
+			assert.NoError(t, c.SetConfigRaw(config))
+
+			assert.NoError(t, c.TakeSnapshot("first", "before upgrade"))
+			snapshots, err := c.ListSnapshots()
+			assert.NoError(t, err)
+			assert.Equal(t, []drivers.Snapshot{{Name: "first", Description: "before upgrade", Current: true}}, snapshots)
+			assert.NoError(t, c.RestoreSnapshot("first"))
+			assert.NoError(t, c.DeleteSnapshot("first"))
+		})
+	}
+}
+
+// derefFlags lets flags be compared whether they are sent as pointers or not.
+func derefFlags(flags []mcnflag.Flag) []interface{} {
+	values := []interface{}{}
+	for _, f := range flags {
+		values = append(values, reflect.Indirect(reflect.ValueOf(f)).Interface())
+	}
+	return values
+}
+
+// serverMethods returns the methods net/rpc serves for the plugin server.
+func serverMethods() []string {
+	methods := []string{}
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+
+	t := reflect.TypeOf(&RPCServerDriver{})
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.Type.NumIn() == 3 && m.Type.NumOut() == 1 && m.Type.Out(0) == errorType && m.Type.In(2).Kind() == reflect.Ptr {
+			methods = append(methods, RPCServiceNameV1+"."+m.Name)
+		}
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+func TestJSONRPCSchemaMatchesServer(t *testing.T) {
+	var schema struct {
+		Methods []struct {
+			Name string
+		}
+	}
+	if err := json.Unmarshal(JSONRPCSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	methods := []string{}
+	for _, m := range schema.Methods {
+		methods = append(methods, m.Name)
+	}
+	sort.Strings(methods)
+
+	assert.Equal(t, serverMethods(), methods)
+}
+
+// TestJSONRPCWire talks to the plugin server the way a client written in
+// another language would.
+func TestJSONRPCWire(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(newConformanceDriver())); err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewJSONRPCServerCodec(serverConn))
+	defer clientConn.Close()
+
+	responses := bufio.NewScanner(clientConn)
+	call := func(request string) string {
+		go clientConn.Write([]byte(request + "\n"))
+		if !responses.Scan() {
+			t.Fatal(responses.Err())
+		}
+		return responses.Text()
+	}
+
+	testCases := []struct {
+		description string
+		request     string
+		response    string
+	}{
+		{
+			"without params",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.GetIP","id":1}`,
+			`{"jsonrpc":"2.0","id":1,"result":"1.2.3.4"}`,
+		},
+		{
+			"with positional params",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.GetState","params":[{}],"id":"two"}`,
+			`{"jsonrpc":"2.0","id":"two","result":1}`,
+		},
+		{
+			"with named params",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.TakeSnapshot","params":{"Name":"first"},"id":3}`,
+			`{"jsonrpc":"2.0","id":3,"result":{}}`,
+		},
+		{
+			"with flags",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.GetCreateFlags","id":4}`,
+			`{"jsonrpc":"2.0","id":4,"result":[` +
+				`{"Type":"string","Flag":{"Name":"conformance-string","Usage":"a string","EnvVar":"CONFORMANCE_STRING","Value":"default"}},` +
+				`{"Type":"stringSlice","Flag":{"Name":"conformance-string-slice","Usage":"","EnvVar":"","Value":["a","b"]}},` +
+				`{"Type":"int","Flag":{"Name":"conformance-int","Usage":"","EnvVar":"","Value":42}},` +
+				`{"Type":"bool","Flag":{"Name":"conformance-bool","Usage":"","EnvVar":""}},` +
+				`{"Type":"size","Flag":{"Name":"conformance-size","Usage":"","EnvVar":"","Value":1024}}]}`,
+		},
+		{
+			"driver error",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.Kill","id":5}`,
+			`{"jsonrpc":"2.0","id":5,"error":{"code":-32000,"message":"kill failed"}}`,
+		},
+		{
+			"unknown method",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.Explode","id":6}`,
+			`{"jsonrpc":"2.0","id":6,"error":{"code":-32601,"message":"rpc: can't find method RPCServerDriver.Explode"}}`,
+		},
+		{
+			"invalid params",
+			`{"jsonrpc":"2.0","method":"RPCServerDriver.RestoreSnapshot","params":[1],"id":7}`,
+			`{"jsonrpc":"2.0","id":7,"error":{"code":-32602,"message":"json: cannot unmarshal number into Go value of type string"}}`,
+		},
+		{
+			"invalid request",
+			`{"jsonrpc":"1.0","method":"RPCServerDriver.GetIP","id":8}`,
+			`{"jsonrpc":"2.0","id":8,"error":{"code":-32600,"message":"Invalid Request"}}`,
+		},
+		{
+			"batch",
+			`[{"jsonrpc":"2.0","method":"RPCServerDriver.GetIP","id":9}]`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.JSONEq(t, tc.response, call(tc.request), tc.description)
+	}
+
+	// Notifications get no response, so the next one read is for the
+	// request which follows.
+	go clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"RPCServerDriver.Heartbeat"}` + "\n"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":10,"result":"conformance"}`, call(`{"jsonrpc":"2.0","method":"RPCServerDriver.DriverName","id":10}`))
+
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid character '}' looking for beginning of value"}}`, call(`{"jsonrpc":}`))
+}
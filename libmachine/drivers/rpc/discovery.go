@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/version"
+)
+
+// DriverInfo describes an installed driver plugin.
+type DriverInfo struct {
+	Name string
+	Path string
+
+	// Checksum is the SHA-256 of the plugin binary, empty for core
+	// drivers.
+	Checksum string
+
+	// Version is the API version spoken by the plugin, and Flags its
+	// create flags. Flags are only queried from plugins with a compatible
+	// version.
+	Version int
+	Flags   []mcnflag.Flag
+
+	// Err is set when the plugin could not be queried.
+	Err error
+}
+
+// ListAvailableDrivers starts every plugin binary found in pluginDirs, in the
+// directories of MACHINE_PLUGIN_PATH or in $PATH, and asks it for its version
+// and create flags.
+func ListAvailableDrivers(pluginDirs ...string) []DriverInfo {
+	infos := []DriverInfo{}
+	for _, binary := range localbinary.FindPlugins(pluginDirs...) {
+		infos = append(infos, describeDriver(binary, pluginDirs))
+	}
+
+	return infos
+}
+
+func describeDriver(binary localbinary.PluginBinary, pluginDirs []string) DriverInfo {
+	info := DriverInfo{
+		Name: binary.DriverName,
+		Path: binary.Path,
+	}
+
+	p, rpcclient, err := launchPlugin(binary.DriverName, "", localbinary.PluginOptions{Dirs: pluginDirs})
+	if err != nil {
+		info.Err = err
+		return info
+	}
+
+	c := &RPCClientDriver{
+		Client:          NewInternalClient(rpcclient),
+		heartbeatDoneCh: make(chan bool),
+		plugin:          p,
+	}
+	defer c.close()
+
+	info.Checksum = p.Checksum()
+
+	info.Version, info.Err = c.getVersion()
+	if info.Err != nil {
+		return info
+	}
+
+	if info.Version != version.APIVersion {
+		info.Err = fmt.Errorf("Driver binary uses an incompatible API version (%d)", info.Version)
+		return info
+	}
+
+	info.Flags = c.GetCreateFlags()
+
+	return info
+}
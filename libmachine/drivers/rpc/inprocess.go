@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// NewInProcessRPCClientDriver serves d from a plugin server running in the
+// current process, and returns a client driver talking to it over transport,
+// TransportGob or TransportJSONRPC. Calls cross the same RPC boundary as with
+// a plugin binary, which makes it handy to test drivers. The server stops
+// once the client's RPCClient is closed.
+func NewInProcessRPCClientDriver(d drivers.Driver, transport string) (*RPCClientDriver, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(d)); err != nil {
+		return nil, err
+	}
+
+	clientConn, serverConn := net.Pipe()
+
+	var rpcclient *rpc.Client
+	switch transport {
+	case TransportGob:
+		go server.ServeConn(serverConn)
+		rpcclient = rpc.NewClient(clientConn)
+	case TransportJSONRPC:
+		go server.ServeCodec(NewJSONRPCServerCodec(serverConn))
+		rpcclient = rpc.NewClientWithCodec(NewJSONRPCClientCodec(clientConn))
+	default:
+		return nil, fmt.Errorf("Unknown plugin transport %q", transport)
+	}
+
+	c := &RPCClientDriver{
+		Client:          NewInternalClient(rpcclient),
+		heartbeatDoneCh: make(chan bool),
+	}
+	if err := c.handshake(); err != nil {
+		_ = rpcclient.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
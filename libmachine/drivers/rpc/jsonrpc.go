@@ -0,0 +1,485 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+// The JSON-RPC 2.0 transport lets driver plugins be written in languages
+// without a gob implementation. Plugin servers accept it on JSONRPCPath, next
+// to Go RPC on rpc.DefaultRPCPath, and the methods are the same as with Go
+// RPC, e.g. "RPCServerDriver.GetIP". The methods, their params and results
+// are described by the OpenRPC document in JSONRPCSchema.
+const (
+	// JSONRPCPath is the path of the HTTP CONNECT request opening a JSON-RPC
+	// 2.0 connection to a plugin server.
+	JSONRPCPath = "/_jsonrpc2_"
+
+	// TransportEnv selects the transport clients try first, TransportGob
+	// by default. The other one is used if the plugin does not serve it.
+	TransportEnv     = "MACHINE_PLUGIN_TRANSPORT"
+	TransportGob     = "gob"
+	TransportJSONRPC = "jsonrpc"
+
+	jsonrpcVersion   = "2.0"
+	jsonrpcConnected = "200 Connected to JSON-RPC"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification. Errors returned by
+// the driver use jsonrpcServerError.
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcServerError    = -32000
+)
+
+var transportPaths = map[string]string{
+	TransportGob:     rpc.DefaultRPCPath,
+	TransportJSONRPC: JSONRPCPath,
+}
+
+// jsonFlagTypes names the mcnflag types which can be sent over JSON-RPC.
+var jsonFlagTypes = map[string]mcnflag.Flag{
+	"string":      mcnflag.StringFlag{},
+	"stringSlice": mcnflag.StringSliceFlag{},
+	"int":         mcnflag.IntFlag{},
+	"bool":        mcnflag.BoolFlag{},
+	"duration":    mcnflag.DurationFlag{},
+	"float":       mcnflag.FloatFlag{},
+	"enum":        mcnflag.EnumFlag{},
+	"size":        mcnflag.SizeFlag{},
+	"cidr":        mcnflag.CIDRFlag{},
+	"url":         mcnflag.URLFlag{},
+	"path":        mcnflag.PathFlag{},
+}
+
+type jsonrpcRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonrpcResult struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type jsonrpcErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   jsonrpcError     `json:"error"`
+}
+
+// jsonFlag is an mcnflag.Flag sent over JSON-RPC, along with its type.
+type jsonFlag struct {
+	Type string
+	Flag json.RawMessage
+}
+
+// NewJSONRPCHandler returns the handler accepting JSON-RPC 2.0 connections to
+// server on JSONRPCPath. Like Go RPC over HTTP, a connection is opened by an
+// HTTP CONNECT request.
+func NewJSONRPCHandler(server *rpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "CONNECT" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = io.WriteString(w, "405 must CONNECT\n")
+			return
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			log.Debugf("Error hijacking JSON-RPC connection: %s", err)
+			return
+		}
+
+		_, _ = io.WriteString(conn, "HTTP/1.0 "+jsonrpcConnected+"\n\n")
+		server.ServeCodec(NewJSONRPCServerCodec(conn))
+	})
+}
+
+type pendingRequest struct {
+	// id is nil for notifications, which get no response.
+	id            *json.RawMessage
+	invalidParams bool
+}
+
+type jsonrpcServerCodec struct {
+	dec     *json.Decoder
+	enc     *json.Encoder
+	c       io.Closer
+	encLock sync.Mutex
+
+	// params of the request being read.
+	params json.RawMessage
+
+	lock    sync.Mutex
+	seq     uint64
+	pending map[uint64]*pendingRequest
+}
+
+// NewJSONRPCServerCodec returns a codec serving JSON-RPC 2.0 requests read
+// from conn, one JSON value after the other. Batches are not supported.
+func NewJSONRPCServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &jsonrpcServerCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: map[uint64]*pendingRequest{},
+	}
+}
+
+func (c *jsonrpcServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	for {
+		var raw json.RawMessage
+		if err := c.dec.Decode(&raw); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// The stream cannot be read any further.
+				c.writeError(nil, jsonrpcParseError, err.Error())
+			}
+			return err
+		}
+
+		var req jsonrpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != jsonrpcVersion || req.Method == "" {
+			c.writeError(req.ID, jsonrpcInvalidRequest, "Invalid Request")
+			continue
+		}
+
+		c.lock.Lock()
+		c.seq++
+		c.pending[c.seq] = &pendingRequest{id: req.ID}
+		r.Seq = c.seq
+		c.lock.Unlock()
+
+		r.ServiceMethod = req.Method
+		c.params = req.Params
+
+		return nil
+	}
+}
+
+func (c *jsonrpcServerCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+
+	if err := readParams(c.params, x); err != nil {
+		c.lock.Lock()
+		if p, ok := c.pending[c.seq]; ok {
+			p.invalidParams = true
+		}
+		c.lock.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (c *jsonrpcServerCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	c.lock.Lock()
+	p, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.lock.Unlock()
+
+	if !ok {
+		return errors.New("invalid sequence number in response")
+	}
+	if p.id == nil {
+		return nil
+	}
+
+	if r.Error == "" {
+		result, err := toJSON(x)
+		if err != nil {
+			return c.writeError(p.id, jsonrpcServerError, err.Error())
+		}
+
+		c.encLock.Lock()
+		defer c.encLock.Unlock()
+		return c.enc.Encode(jsonrpcResult{
+			JSONRPC: jsonrpcVersion,
+			ID:      p.id,
+			Result:  result,
+		})
+	}
+
+	code := jsonrpcServerError
+	switch {
+	case p.invalidParams:
+		code = jsonrpcInvalidParams
+	case strings.HasPrefix(r.Error, "rpc: can't find"), strings.HasPrefix(r.Error, "rpc: service/method request ill-formed"):
+		code = jsonrpcMethodNotFound
+	}
+
+	return c.writeError(p.id, code, r.Error)
+}
+
+func (c *jsonrpcServerCodec) writeError(id *json.RawMessage, code int, message string) error {
+	c.encLock.Lock()
+	defer c.encLock.Unlock()
+
+	return c.enc.Encode(jsonrpcErrorResponse{
+		JSONRPC: jsonrpcVersion,
+		ID:      id,
+		Error: jsonrpcError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+func (c *jsonrpcServerCodec) Close() error {
+	return c.c.Close()
+}
+
+type jsonrpcClientRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      uint64        `json:"id"`
+}
+
+type jsonrpcClientResponse struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpcError   `json:"error"`
+}
+
+type jsonrpcClientCodec struct {
+	dec  *json.Decoder
+	enc  *json.Encoder
+	c    io.Closer
+	resp jsonrpcClientResponse
+}
+
+// NewJSONRPCClientCodec returns a codec making JSON-RPC 2.0 calls over conn.
+// The argument of a call is passed as the only element of its params.
+func NewJSONRPCClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &jsonrpcClientCodec{
+		dec: json.NewDecoder(conn),
+		enc: json.NewEncoder(conn),
+		c:   conn,
+	}
+}
+
+func (c *jsonrpcClientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
+	params, err := toJSON(param)
+	if err != nil {
+		return err
+	}
+
+	return c.enc.Encode(jsonrpcClientRequest{
+		JSONRPC: jsonrpcVersion,
+		Method:  r.ServiceMethod,
+		Params:  []interface{}{params},
+		ID:      r.Seq,
+	})
+}
+
+func (c *jsonrpcClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.resp = jsonrpcClientResponse{}
+	if err := c.dec.Decode(&c.resp); err != nil {
+		return err
+	}
+
+	if c.resp.ID == nil {
+		if c.resp.Error != nil {
+			return fmt.Errorf("JSON-RPC error %d: %s", c.resp.Error.Code, c.resp.Error.Message)
+		}
+		return errors.New("JSON-RPC response without id")
+	}
+
+	r.Seq = *c.resp.ID
+	r.Error = ""
+	if c.resp.Error != nil {
+		r.Error = c.resp.Error.Message
+		if r.Error == "" {
+			r.Error = fmt.Sprintf("JSON-RPC error %d", c.resp.Error.Code)
+		}
+	}
+
+	return nil
+}
+
+func (c *jsonrpcClientCodec) ReadResponseBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+
+	return fromJSON(c.resp.Result, x)
+}
+
+func (c *jsonrpcClientCodec) Close() error {
+	return c.c.Close()
+}
+
+// readParams decodes the params of a request into the argument x of a
+// method. They are either an array holding the argument, or the argument
+// itself when it is an object. Without params, x is left zero.
+func readParams(params json.RawMessage, x interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+
+	switch params[0] {
+	case '[':
+		var args []json.RawMessage
+		if err := json.Unmarshal(params, &args); err != nil {
+			return err
+		}
+		switch len(args) {
+		case 0:
+			return nil
+		case 1:
+			return fromJSON(args[0], x)
+		}
+		return fmt.Errorf("expected a single param, got %d", len(args))
+	case '{':
+		return fromJSON(params, x)
+	}
+
+	return errors.New("params must be an array or an object")
+}
+
+// toJSON converts the values gob handles through registered types, i.e.
+// flags and driver options, to their JSON-RPC representation.
+func toJSON(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case *drivers.DriverOptions:
+		if v == nil {
+			return nil, nil
+		}
+		return toJSON(*v)
+	case *RPCFlags:
+		return v, nil
+	case RPCFlags:
+		return v, nil
+	case drivers.DriverOptions:
+		return nil, fmt.Errorf("Driver options of type %T cannot be sent over JSON-RPC", v)
+	case *[]mcnflag.Flag:
+		return toJSON(*v)
+	case []mcnflag.Flag:
+		return toJSONFlags(v)
+	}
+
+	return v, nil
+}
+
+func toJSONFlags(flags []mcnflag.Flag) ([]jsonFlag, error) {
+	jsonFlags := []jsonFlag{}
+	for _, f := range flags {
+		flagType := reflect.Indirect(reflect.ValueOf(f)).Type()
+
+		name := ""
+		for n, t := range jsonFlagTypes {
+			if reflect.TypeOf(t) == flagType {
+				name = n
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("Flag %s of type %T cannot be sent over JSON-RPC", f, f)
+		}
+
+		data, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+
+		jsonFlags = append(jsonFlags, jsonFlag{Type: name, Flag: data})
+	}
+
+	return jsonFlags, nil
+}
+
+// fromJSON decodes data into x, the reverse of toJSON.
+func fromJSON(data json.RawMessage, x interface{}) error {
+	switch x := x.(type) {
+	case *drivers.DriverOptions:
+		flags, err := fromJSONOptions(data)
+		if err != nil {
+			return err
+		}
+		*x = flags
+		return nil
+	case *[]mcnflag.Flag:
+		flags, err := fromJSONFlags(data)
+		if err != nil {
+			return err
+		}
+		*x = flags
+		return nil
+	}
+
+	return json.Unmarshal(data, x)
+}
+
+// fromJSONOptions decodes driver options, restoring the int and []string
+// values JSON does not tell apart from other numbers and arrays.
+func fromJSONOptions(data json.RawMessage) (*RPCFlags, error) {
+	var options struct {
+		Values map[string]interface{}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	flags := &RPCFlags{Values: map[string]interface{}{}}
+	for key, value := range options.Values {
+		flags.Values[key] = mcnflag.FromJSONValue("", value)
+	}
+
+	return flags, nil
+}
+
+func fromJSONFlags(data json.RawMessage) ([]mcnflag.Flag, error) {
+	var jsonFlags []jsonFlag
+	if err := json.Unmarshal(data, &jsonFlags); err != nil {
+		return nil, err
+	}
+
+	flags := []mcnflag.Flag{}
+	for _, jf := range jsonFlags {
+		t, ok := jsonFlagTypes[jf.Type]
+		if !ok {
+			return nil, fmt.Errorf("Unknown flag type %q", jf.Type)
+		}
+
+		f := reflect.New(reflect.TypeOf(t))
+		if err := json.Unmarshal(jf.Flag, f.Interface()); err != nil {
+			return nil, err
+		}
+
+		// Like over gob, the flags are decoded as pointers.
+		flags = append(flags, f.Interface().(mcnflag.Flag))
+	}
+
+	return flags, nil
+}
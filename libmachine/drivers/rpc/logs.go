@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const maxPendingLogs = 1000
+
+var (
+	// How long ReadLogs waits for records before returning none.
+	logPollTimeout = time.Second
+)
+
+// LogStreamer is the MachineLogger of a plugin server. It writes through the
+// wrapped logger until a client starts reading the logs with ReadLogs, and
+// from then on queues them as records for that client.
+type LogStreamer struct {
+	log.MachineLogger
+	lock      sync.Mutex
+	machine   string
+	streaming bool
+	records   []log.Record
+	notifyCh  chan struct{}
+}
+
+func NewLogStreamer(l log.MachineLogger) *LogStreamer {
+	return &LogStreamer{
+		MachineLogger: l,
+		notifyCh:      make(chan struct{}, 1),
+	}
+}
+
+// SetMachineName sets the machine of the records which do not name one.
+func (s *LogStreamer) SetMachineName(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.machine = name
+}
+
+// queue adds a record unless streaming has not started, in which case it
+// returns false. The oldest records are dropped if the client falls behind.
+func (s *LogStreamer) queue(level log.Level, message string) bool {
+	return s.queueRecord(log.Record{
+		Level:   level,
+		Time:    time.Now(),
+		Message: message,
+	})
+}
+
+func (s *LogStreamer) queueRecord(r log.Record) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.streaming {
+		return false
+	}
+
+	if r.Machine == "" {
+		r.Machine = s.machine
+	}
+	if len(s.records) >= maxPendingLogs {
+		s.records = s.records[1:]
+	}
+	s.records = append(s.records, r)
+
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// Read starts streaming if needed, and returns the queued records, waiting
+// up to timeout for some to be logged.
+func (s *LogStreamer) Read(timeout time.Duration) []log.Record {
+	s.lock.Lock()
+	s.streaming = true
+	pending := len(s.records)
+	s.lock.Unlock()
+
+	if pending == 0 {
+		select {
+		case <-s.notifyCh:
+		case <-time.After(timeout):
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := s.records
+	s.records = nil
+	return records
+}
+
+// LogRecord queues r with its fields, or writes it through the wrapped
+// logger until streaming starts. The client prefixes the plugin output with
+// the machine name itself, so the wrapped logger does not repeat it.
+func (s *LogStreamer) LogRecord(r log.Record) {
+	if s.queueRecord(r) {
+		return
+	}
+
+	s.lock.Lock()
+	if r.Machine == s.machine {
+		r.Machine = ""
+	}
+	s.lock.Unlock()
+
+	log.LogRecordTo(s.MachineLogger, r)
+}
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func (s *LogStreamer) Debug(args ...interface{}) {
+	if !s.queue(log.DebugLevel, sprintln(args...)) {
+		s.MachineLogger.Debug(args...)
+	}
+}
+
+func (s *LogStreamer) Debugf(fmtString string, args ...interface{}) {
+	if !s.queue(log.DebugLevel, fmt.Sprintf(fmtString, args...)) {
+		s.MachineLogger.Debugf(fmtString, args...)
+	}
+}
+
+func (s *LogStreamer) Error(args ...interface{}) {
+	if !s.queue(log.ErrorLevel, sprintln(args...)) {
+		s.MachineLogger.Error(args...)
+	}
+}
+
+func (s *LogStreamer) Errorf(fmtString string, args ...interface{}) {
+	if !s.queue(log.ErrorLevel, fmt.Sprintf(fmtString, args...)) {
+		s.MachineLogger.Errorf(fmtString, args...)
+	}
+}
+
+func (s *LogStreamer) Info(args ...interface{}) {
+	if !s.queue(log.InfoLevel, sprintln(args...)) {
+		s.MachineLogger.Info(args...)
+	}
+}
+
+func (s *LogStreamer) Infof(fmtString string, args ...interface{}) {
+	if !s.queue(log.InfoLevel, fmt.Sprintf(fmtString, args...)) {
+		s.MachineLogger.Infof(fmtString, args...)
+	}
+}
+
+func (s *LogStreamer) Warn(args ...interface{}) {
+	if !s.queue(log.WarnLevel, sprintln(args...)) {
+		s.MachineLogger.Warn(args...)
+	}
+}
+
+func (s *LogStreamer) Warnf(fmtString string, args ...interface{}) {
+	if !s.queue(log.WarnLevel, fmt.Sprintf(fmtString, args...)) {
+		s.MachineLogger.Warnf(fmtString, args...)
+	}
+}
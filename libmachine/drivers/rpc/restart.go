@@ -0,0 +1,270 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// idempotentMethods are retried once the plugin was restarted after crashing
+// during the call.
+var idempotentMethods = map[string]bool{
+	HeartbeatMethod:            true,
+	GetVersionMethod:           true,
+	GetCapabilitiesMethod:      true,
+	ReadLogsMethod:             true,
+	GetCreateFlagsMethod:       true,
+	SetConfigRawMethod:         true,
+	GetConfigRawMethod:         true,
+	DriverNameMethod:           true,
+	GetURLMethod:               true,
+	GetMachineNameMethod:       true,
+	GetIPMethod:                true,
+	GetSSHHostnameMethod:       true,
+	GetSSHKeyPathMethod:        true,
+	GetSSHPortMethod:           true,
+	GetSSHUsernameMethod:       true,
+	GetStateMethod:             true,
+	PreCreateCheckMethod:       true,
+	ListSnapshotsMethod:        true,
+	GetSSHKnownHostsPathMethod: true,
+}
+
+// ErrPluginCrashed is returned by calls during which the plugin server died.
+type ErrPluginCrashed struct {
+	MachineName string
+	Method      string
+	// Restarted tells whether the plugin was restarted. Calls which are not
+	// idempotent are not retried then, as they may have taken effect.
+	Restarted bool
+	Err       error
+}
+
+func (e ErrPluginCrashed) Error() string {
+	if e.Restarted {
+		return fmt.Sprintf("(%s) Plugin server crashed during %s and was restarted, but the call may have partially taken effect so it was not retried: %s", e.MachineName, e.Method, e.Err)
+	}
+	return fmt.Sprintf("(%s) Plugin server crashed during %s and could not be restarted: %s", e.MachineName, e.Method, e.Err)
+}
+
+// isConnectionError tells whether err means the connection to the plugin
+// server was lost, as opposed to an error returned by the driver.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(rpc.ServerError); ok {
+		return false
+	}
+
+	var netErr net.Error
+	return err == rpc.ErrShutdown || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) || errors.As(err, &netErr)
+}
+
+// launchPlugin starts the plugin binary of a driver and connects to it.
+func launchPlugin(driverName, machineName string, opts localbinary.PluginOptions) (*localbinary.Plugin, *rpc.Client, error) {
+	p, err := localbinary.NewPluginWithOptions(driverName, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.MachineName = machineName
+
+	go func() {
+		if err := p.Serve(); err != nil {
+			// TODO: Is this best approach?
+			log.Warn(err)
+			return
+		}
+	}()
+
+	rpcclient, err := dialPlugin(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p, rpcclient, nil
+}
+
+// dialPlugin connects to the plugin server over the transport selected by
+// TransportEnv, or over the other one if the plugin does not serve it.
+func dialPlugin(p *localbinary.Plugin) (*rpc.Client, error) {
+	transports := []string{TransportGob, TransportJSONRPC}
+	if os.Getenv(TransportEnv) == TransportJSONRPC {
+		transports = []string{TransportJSONRPC, TransportGob}
+	}
+
+	var err error
+	for _, transport := range transports {
+		var conn net.Conn
+		conn, err = p.DialConn(transportPaths[transport])
+		if _, ok := err.(localbinary.ErrTransportNotSupported); ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debugf("Connected to the plugin server using %s", transport)
+
+		if transport == TransportJSONRPC {
+			return rpc.NewClientWithCodec(NewJSONRPCClientCodec(conn)), nil
+		}
+		return rpc.NewClient(conn), nil
+	}
+
+	return nil, err
+}
+
+// call makes an RPC call to the plugin server. If the plugin crashed, it is
+// restarted with the last known driver config, and the call is retried if it
+// is idempotent.
+func (c *RPCClientDriver) call(method string, args interface{}, reply interface{}) error {
+	generation := c.currentGeneration()
+
+	err := c.Client.Call(method, args, reply)
+	if !isConnectionError(err) {
+		if err == nil && !idempotentMethods[method] {
+			c.refreshConfig()
+		}
+		return err
+	}
+
+	return c.recover(generation, method, err, func() error {
+		return c.Client.Call(method, args, reply)
+	})
+}
+
+// recover restarts the plugin which crashed during a call to method with err,
+// and retries the call with retry if method is idempotent.
+func (c *RPCClientDriver) recover(generation int, method string, err error, retry func() error) error {
+	if restartErr := c.restart(generation); restartErr != nil {
+		return ErrPluginCrashed{
+			MachineName: c.Client.MachineName,
+			Method:      method,
+			Err:         restartErr,
+		}
+	}
+
+	if !idempotentMethods[method] {
+		return ErrPluginCrashed{
+			MachineName: c.Client.MachineName,
+			Method:      method,
+			Restarted:   true,
+			Err:         err,
+		}
+	}
+
+	return retry()
+}
+
+func (c *RPCClientDriver) currentGeneration() int {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+	return c.generation
+}
+
+func (c *RPCClientDriver) currentPlugin() localbinary.DriverPlugin {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+	return c.plugin
+}
+
+func (c *RPCClientDriver) currentCapabilities() []drivers.Capability {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+	return c.capabilities
+}
+
+// refreshConfig records the driver config after a call which may have
+// changed it, to be replayed if the plugin has to be restarted.
+func (c *RPCClientDriver) refreshConfig() {
+	var data []byte
+	if err := c.Client.Call(GetConfigRawMethod, struct{}{}, &data); err != nil {
+		log.Debugf("(%s) Error refreshing the driver config: %s", c.Client.MachineName, err)
+		return
+	}
+
+	c.restartLock.Lock()
+	c.config = data
+	c.restartLock.Unlock()
+}
+
+// restart replaces the plugin server which crashed, unless it was already
+// replaced since generation, and replays the last known driver config.
+func (c *RPCClientDriver) restart(generation int) error {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+
+	if c.closed {
+		return errors.New("plugin server was closed")
+	}
+	if c.generation != generation {
+		return nil
+	}
+	if c.launch == nil {
+		return errors.New("plugin server cannot be restarted")
+	}
+
+	log.Warnf("(%s) Plugin server exited unexpectedly, restarting it...", c.Client.MachineName)
+
+	if c.plugin != nil {
+		_ = c.plugin.Close()
+	}
+
+	p, rpcclient, err := c.launch()
+	if err != nil {
+		return err
+	}
+
+	c.plugin = p
+	c.Client.setRPCClient(rpcclient)
+	c.generation++
+
+	if err := c.handshake(); err != nil {
+		return err
+	}
+
+	if c.config != nil {
+		if err := c.Client.Call(SetConfigRawMethod, c.config, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// heartbeat keeps the plugin server alive, and restarts it as soon as it
+// exits unexpectedly, until the driver is closed.
+func (c *RPCClientDriver) heartbeat() {
+	for {
+		generation := c.currentGeneration()
+
+		var exitCh <-chan struct{}
+		if p := c.currentPlugin(); p != nil {
+			exitCh = p.Exited()
+		}
+
+		select {
+		case <-c.heartbeatDoneCh:
+			return
+		case <-exitCh:
+			if err := c.restart(generation); err != nil {
+				log.Warnf("(%s) Error restarting the plugin server: %s", c.Client.MachineName, err)
+				return
+			}
+		case <-time.After(heartbeatInterval):
+			if err := c.call(HeartbeatMethod, struct{}{}, nil); err != nil {
+				log.Warnf("Wrapper Docker Machine process exiting due to closed plugin server (%s)", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePlugin struct {
+	exitCh chan struct{}
+}
+
+func (p *fakePlugin) Address() (string, error)                  { return "", nil }
+func (p *fakePlugin) Serve() error                              { return nil }
+func (p *fakePlugin) Close() error                              { return nil }
+func (p *fakePlugin) AttachStream(*bufio.Scanner) <-chan string { return nil }
+func (p *fakePlugin) Exited() <-chan struct{}                   { return p.exitCh }
+
+// newCrashingClientDriver returns a client driver talking to in-process
+// plugin servers, and a func making the current one crash.
+func newCrashingClientDriver(t *testing.T) (*RPCClientDriver, *int, func()) {
+	launches := 0
+	var serverConn net.Conn
+
+	launch := func() (localbinary.DriverPlugin, *rpc.Client, error) {
+		launches++
+
+		server := rpc.NewServer()
+		if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(&fakedriver.Driver{})); err != nil {
+			return nil, nil, err
+		}
+
+		var clientConn net.Conn
+		clientConn, serverConn = net.Pipe()
+		go server.ServeConn(serverConn)
+
+		return &fakePlugin{exitCh: make(chan struct{})}, rpc.NewClient(clientConn), nil
+	}
+
+	p, rpcclient, err := launch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &RPCClientDriver{
+		Client:          NewInternalClient(rpcclient),
+		heartbeatDoneCh: make(chan bool),
+		plugin:          p,
+		launch:          launch,
+	}
+	if err := c.handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, &launches, func() { serverConn.Close() }
+}
+
+func TestRPCClientDriverRetriesIdempotentCallsAfterCrash(t *testing.T) {
+	c, launches, crash := newCrashingClientDriver(t)
+
+	config, _ := json.Marshal(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"})
+	assert.NoError(t, c.SetConfigRaw(config))
+
+	crash()
+
+	ip, err := c.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip)
+	assert.Equal(t, 2, *launches)
+}
+
+func TestRPCClientDriverFailsNonIdempotentCallsAfterCrash(t *testing.T) {
+	c, launches, crash := newCrashingClientDriver(t)
+
+	config, _ := json.Marshal(&fakedriver.Driver{MockState: state.Stopped})
+	assert.NoError(t, c.SetConfigRaw(config))
+
+	crash()
+
+	err := c.Start()
+	crashErr, ok := err.(ErrPluginCrashed)
+	assert.True(t, ok, "expected ErrPluginCrashed, got %v", err)
+	assert.True(t, crashErr.Restarted)
+	assert.Equal(t, StartMethod, crashErr.Method)
+	assert.Equal(t, 2, *launches)
+
+	s, err := c.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestRPCClientDriverDoesNotRestartClosedPlugin(t *testing.T) {
+	c, launches, crash := newCrashingClientDriver(t)
+
+	c.closed = true
+	crash()
+
+	_, err := c.GetIP()
+	assert.IsType(t, ErrPluginCrashed{}, err)
+	assert.Equal(t, 1, *launches)
+}
+
+func TestRPCClientDriverReadsCapabilitiesDuringRestart(t *testing.T) {
+	c, launches, crash := newCrashingClientDriver(t)
+	capabilities := c.Capabilities()
+
+	config, _ := json.Marshal(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"})
+	assert.NoError(t, c.SetConfigRaw(config))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			c.Capabilities()
+		}
+	}()
+
+	crash()
+	_, err := c.GetIP()
+	<-done
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *launches)
+	assert.Equal(t, capabilities, c.Capabilities())
+}
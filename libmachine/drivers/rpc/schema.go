@@ -0,0 +1,13 @@
+package rpc
+
+import (
+	// Needed for the JSON-RPC schema.
+	_ "embed"
+)
+
+// JSONRPCSchema is the OpenRPC document describing the methods of plugin
+// servers over the JSON-RPC 2.0 transport, for plugins written in other
+// languages than Go.
+//
+//go:embed openrpc.json
+var JSONRPCSchema []byte
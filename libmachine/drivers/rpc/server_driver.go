@@ -1,10 +1,13 @@
 package rpc
 
 import (
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
@@ -33,6 +36,13 @@ func init() {
 	gob.Register(new(mcnflag.StringFlag))
 	gob.Register(new(mcnflag.StringSliceFlag))
 	gob.Register(new(mcnflag.BoolFlag))
+	gob.Register(new(mcnflag.DurationFlag))
+	gob.Register(new(mcnflag.FloatFlag))
+	gob.Register(new(mcnflag.EnumFlag))
+	gob.Register(new(mcnflag.SizeFlag))
+	gob.Register(new(mcnflag.CIDRFlag))
+	gob.Register(new(mcnflag.URLFlag))
+	gob.Register(new(mcnflag.PathFlag))
 }
 
 type RPCFlags struct { //nolint:revive
@@ -80,10 +90,21 @@ func (r RPCFlags) Bool(key string) bool {
 	return val
 }
 
+// ContextArgs carries the caller's context over RPC: the deadline, if any,
+// and an ID the caller can pass to CancelCall to abort the call.
+type ContextArgs struct {
+	CallID   string
+	Deadline time.Time
+}
+
 type RPCServerDriver struct { //nolint:revive
 	ActualDriver drivers.Driver
 	CloseCh      chan bool
 	HeartbeatCh  chan bool
+	cancelFuncs  map[string]context.CancelFunc
+	cancelLock   sync.Mutex
+	// Logs, when set, lets clients stream the logs of the plugin.
+	Logs *LogStreamer
 }
 
 func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
@@ -91,7 +112,50 @@ func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
 		ActualDriver: d,
 		CloseCh:      make(chan bool),
 		HeartbeatCh:  make(chan bool),
+		cancelFuncs:  map[string]context.CancelFunc{},
+	}
+}
+
+// callContext builds the context for a call made with args, registering it
+// so that it can be cancelled by CancelCall. The returned func must be called
+// once the call is over.
+func (r *RPCServerDriver) callContext(args *ContextArgs) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if args == nil {
+		return ctx, cancel
+	}
+
+	if !args.Deadline.IsZero() {
+		cancel()
+		ctx, cancel = context.WithDeadline(context.Background(), args.Deadline)
+	}
+
+	r.cancelLock.Lock()
+	if r.cancelFuncs == nil {
+		r.cancelFuncs = map[string]context.CancelFunc{}
+	}
+	r.cancelFuncs[args.CallID] = cancel
+	r.cancelLock.Unlock()
+
+	return ctx, func() {
+		r.cancelLock.Lock()
+		delete(r.cancelFuncs, args.CallID)
+		r.cancelLock.Unlock()
+		cancel()
+	}
+}
+
+// CancelCall cancels the context of the in-flight call with the given ID.
+// Cancelling a call which already returned is not an error.
+func (r *RPCServerDriver) CancelCall(callID *string, _ *struct{}) error {
+	r.cancelLock.Lock()
+	defer r.cancelLock.Unlock()
+
+	if cancel, ok := r.cancelFuncs[*callID]; ok {
+		cancel()
+		delete(r.cancelFuncs, *callID)
 	}
+	return nil
 }
 
 func (r *RPCServerDriver) Close(_, _ *struct{}) error {
@@ -104,6 +168,30 @@ func (r *RPCServerDriver) GetVersion(_ *struct{}, reply *int) error {
 	return nil
 }
 
+// GetCapabilities returns the optional features of the driver served.
+func (r *RPCServerDriver) GetCapabilities(_ *struct{}, reply *[]drivers.Capability) error {
+	capabilities := drivers.Capabilities(r.ActualDriver)
+	if r.Logs != nil {
+		capabilities = append(capabilities, drivers.CapabilityLogStream)
+	}
+	*reply = capabilities
+	return nil
+}
+
+// ReadLogs switches the plugin logs to streaming, and returns the records
+// logged since the previous call, waiting a while for some if there are none.
+func (r *RPCServerDriver) ReadLogs(_ *struct{}, reply *[]log.Record) error {
+	if r.Logs == nil {
+		return drivers.ErrNotSupported{
+			DriverName: r.ActualDriver.DriverName(),
+			Capability: drivers.CapabilityLogStream,
+		}
+	}
+
+	*reply = r.Logs.Read(logPollTimeout)
+	return nil
+}
+
 func (r *RPCServerDriver) GetConfigRaw(_ *struct{}, reply *[]byte) error {
 	driverData, err := json.Marshal(r.ActualDriver)
 	if err != nil {
@@ -121,7 +209,14 @@ func (r *RPCServerDriver) GetCreateFlags(_ *struct{}, reply *[]mcnflag.Flag) err
 }
 
 func (r *RPCServerDriver) SetConfigRaw(data []byte, _ *struct{}) error {
-	return json.Unmarshal(data, &r.ActualDriver)
+	if err := json.Unmarshal(data, &r.ActualDriver); err != nil {
+		return err
+	}
+
+	if r.Logs != nil {
+		r.Logs.SetMachineName(r.ActualDriver.GetMachineName())
+	}
+	return nil
 }
 
 func trapPanic(err *error) {
@@ -169,6 +264,11 @@ func (r *RPCServerDriver) GetSSHKeyPath(_ *struct{}, reply *string) error {
 	return nil
 }
 
+func (r *RPCServerDriver) GetSSHKnownHostsPath(_ *struct{}, reply *string) error {
+	*reply = drivers.GetSSHKnownHostsPath(r.ActualDriver)
+	return nil
+}
+
 // GetSSHPort returns port for use with ssh
 func (r *RPCServerDriver) GetSSHPort(_ *struct{}, reply *int) error {
 	port, err := r.ActualDriver.GetSSHPort()
@@ -210,6 +310,12 @@ func (r *RPCServerDriver) Restart(_ *struct{}, _ *struct{}) error {
 }
 
 func (r *RPCServerDriver) SetConfigFromFlags(flags *drivers.DriverOptions, _ *struct{}) error {
+	if rpcFlags, ok := (*flags).(*RPCFlags); ok {
+		if err := drivers.ValidateFlagValues(r.ActualDriver.GetCreateFlags(), rpcFlags.Values); err != nil {
+			return err
+		}
+	}
+
 	return r.ActualDriver.SetConfigFromFlags(*flags)
 }
 
@@ -225,3 +331,102 @@ func (r *RPCServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
 	r.HeartbeatCh <- true
 	return nil
 }
+
+func (r *RPCServerDriver) contextDriver() drivers.ContextDriver {
+	return drivers.NewContextDriver(r.ActualDriver)
+}
+
+func (r *RPCServerDriver) CreateContext(args *ContextArgs, _ *struct{}) (err error) {
+	// See Create for why panics are trapped here.
+	defer trapPanic(&err)
+
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().CreateContext(ctx)
+}
+
+func (r *RPCServerDriver) GetStateContext(args *ContextArgs, reply *state.State) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	s, err := r.contextDriver().GetStateContext(ctx)
+	*reply = s
+	return err
+}
+
+func (r *RPCServerDriver) KillContext(args *ContextArgs, _ *struct{}) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().KillContext(ctx)
+}
+
+func (r *RPCServerDriver) RemoveContext(args *ContextArgs, _ *struct{}) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().RemoveContext(ctx)
+}
+
+func (r *RPCServerDriver) RestartContext(args *ContextArgs, _ *struct{}) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().RestartContext(ctx)
+}
+
+func (r *RPCServerDriver) StartContext(args *ContextArgs, _ *struct{}) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().StartContext(ctx)
+}
+
+func (r *RPCServerDriver) StopContext(args *ContextArgs, _ *struct{}) error {
+	ctx, done := r.callContext(args)
+	defer done()
+
+	return r.contextDriver().StopContext(ctx)
+}
+
+// SnapshotArgs are the arguments of TakeSnapshot.
+type SnapshotArgs struct {
+	Name        string
+	Description string
+}
+
+func (r *RPCServerDriver) TakeSnapshot(args *SnapshotArgs, _ *struct{}) error {
+	s, err := drivers.NewSnapshotter(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return s.TakeSnapshot(args.Name, args.Description)
+}
+
+func (r *RPCServerDriver) ListSnapshots(_ *struct{}, reply *[]drivers.Snapshot) error {
+	s, err := drivers.NewSnapshotter(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := s.ListSnapshots()
+	*reply = snapshots
+	return err
+}
+
+func (r *RPCServerDriver) RestoreSnapshot(name *string, _ *struct{}) error {
+	s, err := drivers.NewSnapshotter(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return s.RestoreSnapshot(*name)
+}
+
+func (r *RPCServerDriver) DeleteSnapshot(name *string, _ *struct{}) error {
+	s, err := drivers.NewSnapshotter(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	return s.DeleteSnapshot(*name)
+}
@@ -1,10 +1,17 @@
 package rpc
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,3 +80,160 @@ func TestRPCServerDriverCreate(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, tc.serverDriver.Create(nil, nil))
 	}
 }
+
+func TestRPCServerDriverCancelCall(t *testing.T) {
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+
+	ctx, done := serverDriver.callContext(&ContextArgs{CallID: "call-1"})
+	defer done()
+
+	callID := "call-1"
+	assert.NoError(t, serverDriver.CancelCall(&callID, nil))
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// Cancelling an unknown or finished call is a no-op
+	assert.NoError(t, serverDriver.CancelCall(&callID, nil))
+}
+
+// slowCancelDriver takes a while to give up its operations once cancelled.
+type slowCancelDriver struct {
+	drivers.ContextDriver
+	returned chan struct{}
+}
+
+func (d *slowCancelDriver) CreateContext(ctx context.Context) error {
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	close(d.returned)
+	return ctx.Err()
+}
+
+func TestRPCClientDriverWaitsForCancelledCall(t *testing.T) {
+	d := &slowCancelDriver{
+		ContextDriver: drivers.NewContextDriver(&fakedriver.Driver{}),
+		returned:      make(chan struct{}),
+	}
+	c, err := NewInProcessRPCClientDriver(d, TransportGob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Client.RPCClient.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	assert.Equal(t, context.Canceled, c.CreateContext(ctx))
+
+	select {
+	case <-d.returned:
+	default:
+		t.Fatal("CreateContext returned before the driver gave up")
+	}
+}
+
+func TestRPCServerDriverCallContextDeadline(t *testing.T) {
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+	deadline := time.Now().Add(time.Hour)
+
+	ctx, done := serverDriver.callContext(&ContextArgs{CallID: "call-1", Deadline: deadline})
+	defer done()
+
+	actual, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, deadline.Equal(actual))
+}
+
+func TestRPCServerDriverStartContext(t *testing.T) {
+	d := &fakedriver.Driver{MockState: state.Stopped}
+	serverDriver := NewRPCServerDriver(d)
+
+	assert.NoError(t, serverDriver.StartContext(&ContextArgs{CallID: "call-1"}, nil))
+	assert.Equal(t, state.Running, d.MockState)
+	assert.Empty(t, serverDriver.cancelFuncs)
+}
+
+func TestRPCServerDriverSnapshotsNotSupported(t *testing.T) {
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+
+	var snapshots []drivers.Snapshot
+	err := serverDriver.ListSnapshots(nil, &snapshots)
+
+	assert.Equal(t, drivers.ErrNotSupported{DriverName: "Driver", Capability: drivers.CapabilitySnapshots}, err)
+}
+
+type snapshotDriver struct {
+	*fakedriver.Driver
+	drivers.Snapshotter
+}
+
+func TestRPCServerDriverGetCapabilities(t *testing.T) {
+	var capabilities []drivers.Capability
+
+	assert.NoError(t, NewRPCServerDriver(&fakedriver.Driver{}).GetCapabilities(nil, &capabilities))
+	assert.Empty(t, capabilities)
+
+	assert.NoError(t, NewRPCServerDriver(&snapshotDriver{Driver: &fakedriver.Driver{}}).GetCapabilities(nil, &capabilities))
+	assert.Equal(t, []drivers.Capability{drivers.CapabilitySnapshots}, capabilities)
+}
+
+func TestRPCServerDriverReadLogs(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := log.NewFmtMachineLogger()
+	logger.SetOutWriter(out)
+
+	logs := NewLogStreamer(logger)
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+	serverDriver.Logs = logs
+
+	var capabilities []drivers.Capability
+	assert.NoError(t, serverDriver.GetCapabilities(nil, &capabilities))
+	assert.Equal(t, []drivers.Capability{drivers.CapabilityLogStream}, capabilities)
+
+	logs.Infof("before %s", "streaming")
+	assert.Equal(t, "before streaming\n", out.String())
+
+	logPollTimeout = 10 * time.Millisecond
+	defer func() { logPollTimeout = time.Second }()
+
+	var records []log.Record
+	assert.NoError(t, serverDriver.ReadLogs(nil, &records))
+	assert.Empty(t, records)
+
+	logs.Debug("now", "streaming")
+	assert.NoError(t, serverDriver.ReadLogs(nil, &records))
+	assert.Len(t, records, 1)
+	assert.Equal(t, log.DebugLevel, records[0].Level)
+	assert.Equal(t, "now streaming", records[0].Message)
+	assert.Equal(t, "before streaming\n", out.String())
+}
+
+func TestRPCServerDriverReadLogsCarriesFieldsAndMachine(t *testing.T) {
+	logs := NewLogStreamer(log.NewFmtMachineLogger())
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+	serverDriver.Logs = logs
+
+	defer log.SetLogger(log.GetLogger())
+	log.SetLogger(logs)
+
+	config, _ := json.Marshal(&fakedriver.Driver{MockName: "test"})
+	assert.NoError(t, serverDriver.SetConfigRaw(config, nil))
+
+	logPollTimeout = 10 * time.Millisecond
+	defer func() { logPollTimeout = time.Second }()
+
+	var records []log.Record
+	assert.NoError(t, serverDriver.ReadLogs(nil, &records))
+
+	log.WithFields(map[string]string{"attempt": "2"}).Info("booted")
+	log.Warn("slow")
+	assert.NoError(t, serverDriver.ReadLogs(nil, &records))
+
+	assert.Len(t, records, 2)
+	assert.Equal(t, "test", records[0].Machine)
+	assert.Equal(t, map[string]string{"attempt": "2"}, records[0].Fields)
+	assert.Equal(t, "test", records[1].Machine)
+	assert.Equal(t, "slow", records[1].Message)
+}
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"sync"
 
 	"encoding/json"
@@ -167,6 +168,146 @@ func (d *SerialDriver) Stop() error {
 	return d.Driver.Stop()
 }
 
+// runContext runs an operation while holding the lock, returning early with
+// the context error when ctx is done. The lock is only released once the
+// operation returns, so that the next serialized operation never overlaps
+// with one which was given up on. Drivers which abort their operations when
+// ctx is cancelled, see CapabilityContext, run it through withContext. Other
+// drivers cannot abort f, which keeps running in the background. This is also
+// the case of plugins whose driver only supports contexts through the adapter
+// of NewContextDriver.
+func (d *SerialDriver) runContext(ctx context.Context, withContext func(ContextDriver) error, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	run := f
+	if cd, ok := d.Driver.(ContextDriver); ok && Supports(d.Driver, CapabilityContext) {
+		run = func() error { return withContext(cd) }
+	}
+
+	d.Lock()
+	errCh := make(chan error, 1)
+	go func() {
+		defer d.Unlock()
+		errCh <- run()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CreateContext creates a host, giving up when ctx is done
+func (d *SerialDriver) CreateContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.CreateContext(ctx) }, d.Driver.Create)
+}
+
+// GetStateContext returns the state that the host is in, giving up when ctx
+// is done
+func (d *SerialDriver) GetStateContext(ctx context.Context) (state.State, error) {
+	var s state.State
+	err := d.runContext(ctx, func(cd ContextDriver) error {
+		var err error
+		s, err = cd.GetStateContext(ctx)
+		return err
+	}, func() error {
+		var err error
+		s, err = d.Driver.GetState()
+		return err
+	})
+	if err != nil {
+		return state.Error, err
+	}
+	return s, nil
+}
+
+// KillContext stops a host forcefully, giving up when ctx is done
+func (d *SerialDriver) KillContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.KillContext(ctx) }, d.Driver.Kill)
+}
+
+// RemoveContext removes a host, giving up when ctx is done
+func (d *SerialDriver) RemoveContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.RemoveContext(ctx) }, d.Driver.Remove)
+}
+
+// RestartContext restarts a host, giving up when ctx is done
+func (d *SerialDriver) RestartContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.RestartContext(ctx) }, d.Driver.Restart)
+}
+
+// StartContext starts a host, giving up when ctx is done
+func (d *SerialDriver) StartContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.StartContext(ctx) }, d.Driver.Start)
+}
+
+// StopContext stops a host gracefully, giving up when ctx is done
+func (d *SerialDriver) StopContext(ctx context.Context) error {
+	return d.runContext(ctx, func(cd ContextDriver) error { return cd.StopContext(ctx) }, d.Driver.Stop)
+}
+
+// GetSSHKnownHostsPath returns the known_hosts file of the machine
+func (d *SerialDriver) GetSSHKnownHostsPath() string {
+	d.Lock()
+	defer d.Unlock()
+	return GetSSHKnownHostsPath(d.Driver)
+}
+
+// Capabilities returns the optional features supported by the driver
+func (d *SerialDriver) Capabilities() []Capability {
+	d.Lock()
+	defer d.Unlock()
+	return Capabilities(d.Driver)
+}
+
 func (d *SerialDriver) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Driver)
 }
+
+// TakeSnapshot saves the current state of a host
+func (d *SerialDriver) TakeSnapshot(name, description string) error {
+	d.Lock()
+	defer d.Unlock()
+	s, err := NewSnapshotter(d.Driver)
+	if err != nil {
+		return err
+	}
+	return s.TakeSnapshot(name, description)
+}
+
+// ListSnapshots returns the snapshots of a host
+func (d *SerialDriver) ListSnapshots() ([]Snapshot, error) {
+	d.Lock()
+	defer d.Unlock()
+	s, err := NewSnapshotter(d.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListSnapshots()
+}
+
+// RestoreSnapshot brings a host back to a saved state
+func (d *SerialDriver) RestoreSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	s, err := NewSnapshotter(d.Driver)
+	if err != nil {
+		return err
+	}
+	return s.RestoreSnapshot(name)
+}
+
+// DeleteSnapshot removes a saved state of a host
+func (d *SerialDriver) DeleteSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	s, err := NewSnapshotter(d.Driver)
+	if err != nil {
+		return err
+	}
+	return s.DeleteSnapshot(name)
+}
@@ -0,0 +1,47 @@
+package drivers
+
+import "errors"
+
+// ErrSnapshotsNotSupported matches, with errors.Is, the ErrNotSupported
+// returned when snapshots are requested from a driver which cannot take them.
+//
+// Deprecated: check for ErrNotSupported instead.
+var ErrSnapshotsNotSupported = errors.New("Driver does not support snapshots")
+
+// Snapshot describes a saved state of a machine.
+type Snapshot struct {
+	Name        string
+	ID          string
+	Description string
+	// Current is set for the snapshot the machine's state derives from.
+	Current bool
+}
+
+// Snapshotter is implemented by drivers which can save the state of a machine
+// and bring it back later.
+type Snapshotter interface {
+	// TakeSnapshot saves the current state of the machine under name
+	TakeSnapshot(name, description string) error
+
+	// ListSnapshots returns the snapshots of the machine
+	ListSnapshots() ([]Snapshot, error)
+
+	// RestoreSnapshot brings the machine back to the state saved under name
+	RestoreSnapshot(name string) error
+
+	// DeleteSnapshot removes the snapshot saved under name
+	DeleteSnapshot(name string) error
+}
+
+// NewSnapshotter returns d as a Snapshotter, or ErrNotSupported if it cannot
+// take snapshots.
+func NewSnapshotter(d Driver) (Snapshotter, error) {
+	s, ok := d.(Snapshotter)
+	if !ok || !Supports(d, CapabilitySnapshots) {
+		return nil, ErrNotSupported{
+			DriverName: d.DriverName(),
+			Capability: CapabilitySnapshots,
+		}
+	}
+	return s, nil
+}
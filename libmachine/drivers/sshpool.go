@@ -0,0 +1,24 @@
+package drivers
+
+import "github.com/docker/machine/libmachine/ssh"
+
+// pooledSSHDriver is a Driver whose SSH clients share the connections of a
+// pool, see WithSSHPool.
+type pooledSSHDriver struct {
+	Driver
+	pool *ssh.Pool
+}
+
+// WithSSHPool returns d, except that the SSH clients returned for it by
+// GetSSHClientFromDriver share the connections of pool, e.g. to run all the
+// commands provisioning a machine over a single connection.
+func WithSSHPool(d Driver, pool *ssh.Pool) Driver {
+	return &pooledSSHDriver{
+		Driver: d,
+		pool:   pool,
+	}
+}
+
+func (d *pooledSSHDriver) GetSSHKnownHostsPath() string {
+	return GetSSHKnownHostsPath(d.Driver)
+}
@@ -0,0 +1,29 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSSHClientFromDriverWithSSHPool(t *testing.T) {
+	pool := ssh.NewPool()
+	defer pool.Close()
+
+	d := &MockDriver{
+		calls:       &CallRecorder{},
+		sshHostname: "localhost",
+		sshPort:     22,
+		sshUsername: "docker",
+	}
+
+	client, err := GetSSHClientFromDriver(WithSSHPool(d, pool))
+	assert.NoError(t, err)
+	assert.IsType(t, &ssh.PooledClient{}, client)
+
+	client, err = GetSSHClientFromDriver(d)
+	assert.NoError(t, err)
+	_, pooled := client.(*ssh.PooledClient)
+	assert.False(t, pooled)
+}
@@ -2,6 +2,7 @@ package drivers
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -27,6 +28,11 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 			Keys: []string{d.GetSSHKeyPath()},
 		}
 	}
+	auth.KnownHosts = GetSSHKnownHostsPath(d)
+
+	if pooled, ok := d.(*pooledSSHDriver); ok {
+		return pooled.pool.NewClient(d.GetSSHUsername(), address, port, auth)
+	}
 
 	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, auth)
 	return client, err
@@ -53,6 +59,19 @@ output  : %s`, command, err, output)
 	return output, nil
 }
 
+// CopyToHostFromDriver writes the content of src to the file dst on the host
+// of the driver, see ssh.CopyOptions.
+func CopyToHostFromDriver(d Driver, src io.Reader, dst string, opts ssh.CopyOptions) error {
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("About to copy a file to the host: %s", dst)
+
+	return client.CopyTo(src, dst, opts)
+}
+
 func sshAvailableFunc(d Driver) func() bool {
 	return func() bool {
 		log.Debug("Getting to WaitForSSH function...")
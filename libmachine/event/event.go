@@ -0,0 +1,173 @@
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// Phase names a step of the lifecycle of a machine.
+type Phase string
+
+const (
+	Create          Phase = "create"
+	BootstrapCerts  Phase = "bootstrap-certs"
+	PreCreateCheck  Phase = "pre-create-check"
+	DriverCreate    Phase = "driver-create"
+	WaitRunning     Phase = "wait-running"
+	DetectOS        Phase = "detect-os"
+	Provision       Phase = "provision"
+	ConnCheck       Phase = "conn-check"
+	Start           Phase = "start"
+	Stop            Phase = "stop"
+	Kill            Phase = "kill"
+	Restart         Phase = "restart"
+	Upgrade         Phase = "upgrade"
+	ConfigureAuth   Phase = "configure-auth"
+	RegenerateCerts Phase = "regenerate-certs"
+	TakeSnapshot    Phase = "take-snapshot"
+	RestoreSnapshot Phase = "restore-snapshot"
+	DeleteSnapshot  Phase = "delete-snapshot"
+)
+
+// Kind tells whether an event marks the beginning or the end of a phase.
+type Kind string
+
+const (
+	Started  Kind = "started"
+	Finished Kind = "finished"
+)
+
+// Event reports the progress of a machine through a lifecycle phase.
+type Event struct {
+	Kind    Kind
+	Phase   Phase
+	Machine string
+	Driver  string
+	Time    time.Time
+
+	// Duration and Err are only set on Finished events.
+	Duration time.Duration
+	Err      error
+}
+
+// Subscriber receives events. HandleEvent is called synchronously from the
+// goroutine running the phase, so it should return quickly.
+type Subscriber interface {
+	HandleEvent(e Event)
+}
+
+// SubscriberFunc adapts a func to the Subscriber interface.
+type SubscriberFunc func(e Event)
+
+func (f SubscriberFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// Bus delivers the events published through it to its subscribers, e.g.
+// those of the hosts of one libmachine.Client. The zero value is ready to use,
+// and a nil *Bus publishes nothing.
+type Bus struct {
+	subscribers map[int]Subscriber
+	lock        sync.RWMutex
+	nextID      int
+}
+
+// Subscribe registers s to receive every event of b. The returned func
+// unregisters it.
+func (b *Bus) Subscribe(s Subscriber) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = map[int]Subscriber{}
+	}
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = s
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Channel returns a channel receiving every event of b, and a func which
+// unsubscribes and closes it. Events are dropped rather than blocking a
+// phase when the channel buffer is full.
+func (b *Bus) Channel(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	lock := &sync.Mutex{}
+	closed := false
+
+	unsubscribe := b.Subscribe(SubscriberFunc(func(e Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}))
+
+	return ch, func() {
+		unsubscribe()
+		lock.Lock()
+		defer lock.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+func (b *Bus) publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, s := range b.subscribers {
+		s.HandleEvent(e)
+	}
+}
+
+// Begin publishes the start of a phase, and returns a func publishing its end
+// with the error it finished with, if any.
+func (b *Bus) Begin(machine, driver string, phase Phase) func(err error) {
+	start := time.Now()
+
+	b.publish(Event{
+		Kind:    Started,
+		Phase:   phase,
+		Machine: machine,
+		Driver:  driver,
+		Time:    start,
+	})
+
+	return func(err error) {
+		now := time.Now()
+		b.publish(Event{
+			Kind:     Finished,
+			Phase:    phase,
+			Machine:  machine,
+			Driver:   driver,
+			Time:     now,
+			Duration: now.Sub(start),
+			Err:      err,
+		})
+	}
+}
+
+// Run runs f as the given phase, publishing its start and end.
+func (b *Bus) Run(machine, driver string, phase Phase, f func() error) error {
+	finish := b.Begin(machine, driver, phase)
+	err := f()
+	finish(err)
+	return err
+}
@@ -0,0 +1,78 @@
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPublishesStartAndFinish(t *testing.T) {
+	bus := &Bus{}
+	events := []Event{}
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(e Event) {
+		events = append(events, e)
+	}))
+	defer unsubscribe()
+
+	err := bus.Run("test", "virtualbox", Provision, func() error {
+		return errors.New("boom")
+	})
+
+	assert.EqualError(t, err, "boom")
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, Started, events[0].Kind)
+	assert.Equal(t, Provision, events[0].Phase)
+	assert.Equal(t, "test", events[0].Machine)
+	assert.Equal(t, "virtualbox", events[0].Driver)
+	assert.NoError(t, events[0].Err)
+
+	assert.Equal(t, Finished, events[1].Kind)
+	assert.Equal(t, Provision, events[1].Phase)
+	assert.EqualError(t, events[1].Err, "boom")
+	assert.Equal(t, events[1].Time.Sub(events[0].Time), events[1].Duration)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := &Bus{}
+	count := 0
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(e Event) {
+		count++
+	}))
+
+	bus.Begin("test", "none", Start)(nil)
+	unsubscribe()
+	bus.Begin("test", "none", Start)(nil)
+
+	assert.Equal(t, 2, count)
+}
+
+func TestChannelDropsWhenFull(t *testing.T) {
+	bus := &Bus{}
+	ch, unsubscribe := bus.Channel(1)
+
+	bus.Begin("test", "none", Stop)(nil)
+	unsubscribe()
+
+	e, ok := <-ch
+	assert.True(t, ok)
+	assert.Equal(t, Started, e.Kind)
+
+	_, ok = <-ch
+	assert.False(t, ok)
+}
+
+func TestBusesAreSeparate(t *testing.T) {
+	bus, other := &Bus{}, &Bus{}
+	count := 0
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(e Event) {
+		count++
+	}))
+	defer unsubscribe()
+
+	other.Begin("test", "none", Start)(nil)
+	(*Bus)(nil).Begin("test", "none", Start)(nil)
+
+	assert.Equal(t, 0, count)
+}
@@ -9,11 +9,12 @@ import (
 	"github.com/docker/machine/drivers/virtualbox"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
 )
 
 func usage() {
 	fmt.Println("Usage: go run main.go <example>\n" +
-		"Available examples: create streaming.")
+		"Available examples: create streaming store-import store-export.")
 	os.Exit(1)
 }
 
@@ -129,6 +130,41 @@ func streaming() {
 	}
 }
 
+// Import the machines/*/config.json files of a store into a bbolt database,
+// and use it from a client.
+func storeImport() {
+	storePath := "/tmp/automatic"
+	fileStore := persist.NewFilestore(storePath, "/tmp/automatic/certs", "/tmp/automatic/certs")
+	boltStore := persist.NewBoltStore(storePath)
+
+	if err := boltStore.Import(fileStore); err != nil {
+		log.Error(err)
+		return
+	}
+
+	client := libmachine.NewClientWithStore(storePath, "/tmp/automatic/certs", boltStore)
+	defer client.Close()
+
+	hostNames, err := client.List()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	fmt.Printf("Imported machines: %v\n", hostNames)
+}
+
+// Export the machines of a bbolt database back to config.json files.
+func storeExport() {
+	storePath := "/tmp/automatic"
+	fileStore := persist.NewFilestore(storePath, "/tmp/automatic/certs", "/tmp/automatic/certs")
+	boltStore := persist.NewBoltStore(storePath)
+
+	if err := boltStore.Export(fileStore); err != nil {
+		log.Error(err)
+	}
+}
+
 func main() {
 	if len(os.Args) != 2 {
 		usage()
@@ -139,6 +175,10 @@ func main() {
 		create()
 	case "streaming":
 		streaming()
+	case "store-import":
+		storeImport()
+	case "store-export":
+		storeExport()
 	default:
 		usage()
 	}
@@ -1,12 +1,15 @@
 package host
 
 import (
+	"context"
+	"encoding/json"
 	"regexp"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/event"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcndockerclient"
 	"github.com/docker/machine/libmachine/mcnerror"
@@ -37,6 +40,12 @@ func SetSSHClientCreator(creator SSHClientCreator) {
 	stdSSHClientCreator = creator
 }
 
+// Locker guards a host against concurrent operations from other processes.
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
 type Host struct {
 	ConfigVersion int
 	Driver        drivers.Driver
@@ -44,6 +53,45 @@ type Host struct {
 	HostOptions   *Options
 	Name          string
 	RawDriver     []byte `json:"-"`
+	Locker        Locker `json:"-"`
+	// Events publishes the progress of the operations of the host, e.g. to
+	// the subscribers of the libmachine.Client which loaded it. Nothing is
+	// published when it is nil.
+	Events *event.Bus `json:"-"`
+	// SSHPool, when set, is shared by the SSH commands provisioning the
+	// host, e.g. during ConfigureAuth, see SSHDriver.
+	SSHPool *ssh.Pool `json:"-"`
+	// Revision is incremented by the store each time the host is saved.
+	Revision int `json:",omitempty"`
+	// CreateStatus is set when the creation of the host did not complete.
+	CreateStatus *CreateStatus `json:",omitempty"`
+	// DriverChecksum pins the SHA-256 of the driver plugin binary. The
+	// host is not loaded with a plugin binary having another checksum.
+	DriverChecksum string `json:",omitempty"`
+	// UnknownFields are the fields of the stored config this version does
+	// not know, which are saved back as they are.
+	UnknownFields map[string]json.RawMessage `json:"-"`
+}
+
+// CreateState tells where the creation of a host stands.
+type CreateState string
+
+const (
+	// CreateInProgress marks a host which is being created, or whose
+	// creation was interrupted.
+	CreateInProgress CreateState = "Creating"
+	// CreateFailed marks a host which was kept after its creation failed.
+	CreateFailed CreateState = "CreateFailed"
+)
+
+// CreateStatus records how the creation of a host went.
+type CreateStatus struct {
+	State CreateState
+	// CompletedPhase is the last phase of the creation which succeeded.
+	CompletedPhase event.Phase `json:",omitempty"`
+	// Phase is the phase of the creation which failed.
+	Phase event.Phase `json:",omitempty"`
+	Error string      `json:",omitempty"`
 }
 
 type Options struct {
@@ -53,6 +101,15 @@ type Options struct {
 	EngineOptions *engine.Options
 	SwarmOptions  *swarm.Options
 	AuthOptions   *auth.Options
+	// Labels are user defined key/value pairs describing the machine, used
+	// to select machines with a LabelSelector.
+	Labels map[string]string `json:",omitempty"`
+	// DriverFlags are the values of the create flags the driver was
+	// configured with, recorded when the host is created from a profile.
+	DriverFlags map[string]interface{} `json:",omitempty"`
+	// UnknownFields are the fields of the stored options this version does
+	// not know, which are saved back as they are.
+	UnknownFields map[string]json.RawMessage `json:"-"`
 }
 
 type Metadata struct {
@@ -88,11 +145,45 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 	if d.GetSSHKeyPath() != "" {
 		sshauth.Keys = []string{d.GetSSHKeyPath()}
 	}
+	sshauth.KnownHosts = drivers.GetSSHKnownHostsPath(d)
 
 	return ssh.NewClient(d.GetSSHUsername(), addr, port, sshauth)
 }
 
-func (h *Host) runActionForState(action func() error, desiredState state.State) error {
+// lock acquires the host's Locker, if any, for the duration of a lifecycle
+// operation. The returned func releases it.
+func (h *Host) lock() (func(), error) {
+	if h.Locker == nil {
+		return func() {}, nil
+	}
+
+	if err := h.Locker.Lock(); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := h.Locker.Unlock(); err != nil {
+			log.Debugf("Error releasing lock on %q: %s", h.Name, err)
+		}
+	}, nil
+}
+
+// runOperation runs f as a lifecycle operation of the host, which is done
+// while holding the host's lock and reported to the subscribers of h.Events as
+// phase.
+func (h *Host) runOperation(phase event.Phase, f func() error) error {
+	return h.Events.Run(h.Name, h.DriverName, phase, func() error {
+		unlock, err := h.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		return f()
+	})
+}
+
+func (h *Host) runActionForState(ctx context.Context, action func(context.Context) error, desiredState state.State) error {
 	if drivers.MachineInState(h.Driver, desiredState)() {
 		return mcnerror.ErrHostAlreadyInState{
 			Name:  h.Name,
@@ -100,15 +191,29 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 		}
 	}
 
-	if err := action(); err != nil {
+	if err := action(ctx); err != nil {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
+	return mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, desiredState))
+}
+
+// SSHDriver returns the driver through which the host is provisioned, whose
+// SSH clients share the connections of h.SSHPool if it is set.
+func (h *Host) SSHDriver() drivers.Driver {
+	if h.SSHPool == nil {
+		return h.Driver
+	}
+
+	return drivers.WithSSHPool(h.Driver, h.SSHPool)
+}
+
+func (h *Host) contextDriver() drivers.ContextDriver {
+	return drivers.NewContextDriver(h.Driver)
 }
 
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := provision.DetectProvisioner(h.SSHDriver())
 	if err != nil {
 		return err
 	}
@@ -117,51 +222,97 @@ func (h *Host) WaitForDocker() error {
 }
 
 func (h *Host) Start() error {
+	return h.StartContext(context.Background())
+}
+
+// StartContext starts the machine and waits for Docker to be available. It
+// gives up as soon as ctx is cancelled or its deadline expires.
+func (h *Host) StartContext(ctx context.Context) error {
+	return h.runOperation(event.Start, func() error {
+		return h.start(ctx)
+	})
+}
+
+func (h *Host) start(ctx context.Context) error {
 	log.Infof("Starting %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Start, state.Running); err != nil {
+	if err := h.runActionForState(ctx, h.contextDriver().StartContext, state.Running); err != nil {
 		return err
 	}
 
 	log.Infof("Machine %q was started.", h.Name)
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	return h.WaitForDocker()
 }
 
 func (h *Host) Stop() error {
-	log.Infof("Stopping %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Stop, state.Stopped); err != nil {
-		return err
-	}
+	return h.StopContext(context.Background())
+}
+
+// StopContext stops the machine, giving up as soon as ctx is done.
+func (h *Host) StopContext(ctx context.Context) error {
+	return h.runOperation(event.Stop, func() error {
+		log.Infof("Stopping %q...", h.Name)
+		if err := h.runActionForState(ctx, h.contextDriver().StopContext, state.Stopped); err != nil {
+			return err
+		}
 
-	log.Infof("Machine %q was stopped.", h.Name)
-	return nil
+		log.Infof("Machine %q was stopped.", h.Name)
+		return nil
+	})
 }
 
 func (h *Host) Kill() error {
-	log.Infof("Killing %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Kill, state.Stopped); err != nil {
-		return err
-	}
+	return h.KillContext(context.Background())
+}
+
+// KillContext forcefully stops the machine, giving up as soon as ctx is done.
+func (h *Host) KillContext(ctx context.Context) error {
+	return h.runOperation(event.Kill, func() error {
+		log.Infof("Killing %q...", h.Name)
+		if err := h.runActionForState(ctx, h.contextDriver().KillContext, state.Stopped); err != nil {
+			return err
+		}
 
-	log.Infof("Machine %q was killed.", h.Name)
-	return nil
+		log.Infof("Machine %q was killed.", h.Name)
+		return nil
+	})
 }
 
 func (h *Host) Restart() error {
+	return h.RestartContext(context.Background())
+}
+
+// RestartContext restarts the machine, or starts it if it is stopped, and
+// waits for Docker to be available. It gives up as soon as ctx is done.
+func (h *Host) RestartContext(ctx context.Context) error {
+	return h.runOperation(event.Restart, func() error {
+		return h.restart(ctx)
+	})
+}
+
+func (h *Host) restart(ctx context.Context) error {
 	log.Infof("Restarting %q...", h.Name)
 	if drivers.MachineInState(h.Driver, state.Stopped)() {
-		if err := h.Start(); err != nil {
+		if err := h.start(ctx); err != nil {
 			return err
 		}
 	} else if drivers.MachineInState(h.Driver, state.Running)() {
-		if err := h.Driver.Restart(); err != nil {
+		if err := h.contextDriver().RestartContext(ctx); err != nil {
 			return err
 		}
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+		if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Running)); err != nil {
 			return err
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	return h.WaitForDocker()
 }
 
@@ -184,6 +335,10 @@ func (h *Host) DockerVersion() (string, error) {
 }
 
 func (h *Host) Upgrade() error {
+	return h.runOperation(event.Upgrade, h.upgrade)
+}
+
+func (h *Host) upgrade() error {
 	machineState, err := h.Driver.GetState()
 	if err != nil {
 		return err
@@ -191,12 +346,12 @@ func (h *Host) Upgrade() error {
 
 	if machineState != state.Running {
 		log.Info("Starting machine so machine can be upgraded...")
-		if err := h.Start(); err != nil {
+		if err := h.start(context.Background()); err != nil {
 			return err
 		}
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := provision.DetectProvisioner(h.SSHDriver())
 	if err != nil {
 		return err
 	}
@@ -231,7 +386,7 @@ func (h *Host) Upgrade() error {
 		// fine to install Docker from scratch after removing the old
 		// packages, and images/containers etc. should be preserved in
 		// /var/lib/docker)
-		return h.Provision()
+		return h.provision()
 	}
 
 	log.Info("Upgrading docker...")
@@ -255,7 +410,11 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) ConfigureAuth() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	return h.runOperation(event.ConfigureAuth, h.configureAuth)
+}
+
+func (h *Host) configureAuth() error {
+	provisioner, err := provision.DetectProvisioner(h.SSHDriver())
 	if err != nil {
 		return err
 	}
@@ -269,15 +428,21 @@ func (h *Host) ConfigureAuth() error {
 }
 
 func (h *Host) ConfigureAllAuth() error {
-	log.Info("Regenerating local certificates")
-	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
-		return err
-	}
-	return h.ConfigureAuth()
+	return h.runOperation(event.RegenerateCerts, func() error {
+		log.Info("Regenerating local certificates")
+		if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
+			return err
+		}
+		return h.configureAuth()
+	})
 }
 
 func (h *Host) Provision() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	return h.runOperation(event.Provision, h.provision)
+}
+
+func (h *Host) provision() error {
+	provisioner, err := provision.DetectProvisioner(h.SSHDriver())
 	if err != nil {
 		return err
 	}
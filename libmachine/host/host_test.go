@@ -1,10 +1,14 @@
 package host
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/event"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
 )
@@ -55,3 +59,99 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+type recordingLocker struct {
+	calls []string
+	err   error
+}
+
+func (l *recordingLocker) Lock() error {
+	l.calls = append(l.calls, "Lock")
+	return l.err
+}
+
+func (l *recordingLocker) Unlock() error {
+	l.calls = append(l.calls, "Unlock")
+	return nil
+}
+
+func TestStopHoldsLock(t *testing.T) {
+	locker := &recordingLocker{}
+	host := &Host{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+		Locker: locker,
+	}
+
+	if err := host.Stop(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	if !reflect.DeepEqual(locker.calls, []string{"Lock", "Unlock"}) {
+		t.Fatalf("Expected the lock to be taken and released, got %v", locker.calls)
+	}
+}
+
+func TestStopFailsWhenLocked(t *testing.T) {
+	lockErr := mcnerror.ErrHostLocked{Name: "test", PID: 42}
+	driver := &fakedriver.Driver{
+		MockState: state.Running,
+	}
+	host := &Host{
+		Driver: driver,
+		Locker: &recordingLocker{err: lockErr},
+	}
+
+	if err := host.Stop(); err != lockErr {
+		t.Fatalf("Expected %s, got %v", lockErr, err)
+	}
+
+	if driver.MockState != state.Running {
+		t.Fatal("Machine should not be stopped when its lock is held elsewhere")
+	}
+}
+
+func TestStopPublishesEvents(t *testing.T) {
+	bus := &event.Bus{}
+	events, unsubscribe := bus.Channel(10)
+
+	host := &Host{
+		Name:       "test",
+		DriverName: "fake",
+		Events:     bus,
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+
+	if err := host.Stop(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	unsubscribe()
+
+	started, finished := <-events, <-events
+	if started.Kind != event.Started || started.Phase != event.Stop || started.Machine != "test" || started.Driver != "fake" {
+		t.Fatalf("Unexpected start event: %+v", started)
+	}
+	if finished.Kind != event.Finished || finished.Phase != event.Stop || finished.Err != nil {
+		t.Fatalf("Unexpected finish event: %+v", finished)
+	}
+}
+
+func TestTakeSnapshotNotSupported(t *testing.T) {
+	locker := &recordingLocker{}
+	host := &Host{
+		Driver: &fakedriver.Driver{},
+		Locker: locker,
+	}
+
+	expectedErr := drivers.ErrNotSupported{DriverName: "Driver", Capability: drivers.CapabilitySnapshots}
+	if err := host.TakeSnapshot("base", ""); err != expectedErr {
+		t.Fatalf("Expected %s, got %v", expectedErr, err)
+	}
+
+	if !reflect.DeepEqual(locker.calls, []string{"Lock", "Unlock"}) {
+		t.Fatalf("Expected the lock to be taken and released, got %v", locker.calls)
+	}
+}
@@ -0,0 +1,37 @@
+package host
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// ForgetHostKey removes the SSH host key recorded for the machine, so that
+// the key it presents on the next connection is trusted and recorded again.
+func (h *Host) ForgetHostKey() error {
+	knownHosts := drivers.GetSSHKnownHostsPath(h.Driver)
+	if knownHosts == "" {
+		return nil
+	}
+
+	log.Infof("Forgetting the SSH host key of %q...", h.Name)
+	return ssh.ForgetKnownHosts(knownHosts)
+}
+
+// RotateHostKey replaces the SSH host key recorded for the machine with the
+// one it presents now, e.g. after it was rebuilt. The previous key is kept if
+// the machine cannot be reached.
+func (h *Host) RotateHostKey() error {
+	knownHosts := drivers.GetSSHKnownHostsPath(h.Driver)
+	if knownHosts == "" {
+		return fmt.Errorf("Driver %q does not verify SSH host keys", h.DriverName)
+	}
+
+	log.Infof("Recording the new SSH host key of %q...", h.Name)
+	return ssh.RotateKnownHosts(knownHosts, func() error {
+		_, err := h.RunSSHCommand("exit 0")
+		return err
+	})
+}
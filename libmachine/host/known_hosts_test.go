@@ -0,0 +1,128 @@
+package host
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/ssh/testserver"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostsDriver connects to a test SSH server and keeps its host key in
+// KnownHosts.
+type knownHostsDriver struct {
+	*fakedriver.Driver
+	Server     *testserver.Server
+	KnownHosts string
+}
+
+func (d *knownHostsDriver) GetSSHHostname() (string, error) {
+	return d.Server.Host, nil
+}
+
+func (d *knownHostsDriver) GetSSHPort() (int, error) {
+	return d.Server.Port, nil
+}
+
+func (d *knownHostsDriver) GetSSHKnownHostsPath() string {
+	return d.KnownHosts
+}
+
+func newKnownHostsHost(t *testing.T) (*Host, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	h := &Host{
+		Name: "dev",
+		Driver: &knownHostsDriver{
+			Driver:     &fakedriver.Driver{MockState: state.Running},
+			Server:     server,
+			KnownHosts: filepath.Join(dir, "known_hosts"),
+		},
+	}
+
+	return h, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// writeOtherHostKey records another key than the server's for its address,
+// as if the machine had been rebuilt.
+func writeOtherHostKey(t *testing.T, d *knownHostsDriver) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	publicKey, err := gossh.NewPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(d.Server.Addr())}, publicKey) + "\n"
+	assert.NoError(t, ioutil.WriteFile(d.KnownHosts, []byte(line), 0600))
+}
+
+func TestForgetHostKey(t *testing.T) {
+	h, cleanup := newKnownHostsHost(t)
+	defer cleanup()
+
+	d := h.Driver.(*knownHostsDriver)
+	writeOtherHostKey(t, d)
+
+	assert.NoError(t, h.ForgetHostKey())
+	_, err := os.Stat(d.KnownHosts)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, h.ForgetHostKey())
+}
+
+func TestRotateHostKey(t *testing.T) {
+	defer ssh.SetDefaultClient(ssh.External)
+	ssh.SetDefaultClient(ssh.Native)
+
+	h, cleanup := newKnownHostsHost(t)
+	defer cleanup()
+
+	d := h.Driver.(*knownHostsDriver)
+	writeOtherHostKey(t, d)
+
+	_, err := h.RunSSHCommand("exit 0")
+	assert.True(t, ssh.IsHostKeyMismatch(err), "unexpected error: %v", err)
+
+	assert.NoError(t, h.RotateHostKey())
+
+	content, err := ioutil.ReadFile(d.KnownHosts)
+	assert.NoError(t, err)
+	assert.Equal(t, knownhosts.Line([]string{knownhosts.Normalize(d.Server.Addr())}, d.Server.HostKey.PublicKey())+"\n", string(content))
+	_, err = os.Stat(d.KnownHosts + ".old")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = h.RunSSHCommand("exit 0")
+	assert.NoError(t, err)
+}
+
+func TestRotateHostKeyNotSupported(t *testing.T) {
+	h := &Host{
+		Name:       "dev",
+		DriverName: "fakedriver",
+		Driver:     &fakedriver.Driver{},
+	}
+
+	assert.Error(t, h.RotateHostKey())
+	assert.NoError(t, h.ForgetHostKey())
+}
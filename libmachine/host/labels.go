@@ -0,0 +1,126 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+)
+
+type labelOperator int
+
+const (
+	labelEquals labelOperator = iota
+	labelNotEquals
+	labelExists
+	labelNotExists
+)
+
+type labelRequirement struct {
+	key      string
+	operator labelOperator
+	value    string
+}
+
+// LabelSelector selects hosts by their labels. Every requirement of the
+// selector must hold for a host to match.
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseLabelSelector parses a comma separated list of requirements:
+//
+//	key=value, key==value  the label is set to value
+//	key!=value             the label is not set to value, or not set at all
+//	key                    the label is set
+//	!key                   the label is not set
+//
+// e.g. "team=ci,env!=prod". An empty selector matches every host.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	s := LabelSelector{}
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var r labelRequirement
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			r = labelRequirement{key: kv[0], operator: labelNotEquals, value: kv[1]}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			r = labelRequirement{key: kv[0], operator: labelEquals, value: kv[1]}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			r = labelRequirement{key: kv[0], operator: labelEquals, value: kv[1]}
+		case strings.HasPrefix(part, "!"):
+			r = labelRequirement{key: part[1:], operator: labelNotExists}
+		default:
+			r = labelRequirement{key: part, operator: labelExists}
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if err := ValidateLabelKey(r.key); err != nil {
+			return LabelSelector{}, fmt.Errorf("Invalid label selector %q: %s", part, err)
+		}
+
+		s.requirements = append(s.requirements, r)
+	}
+
+	return s, nil
+}
+
+// Matches tells whether labels satisfy every requirement of the selector.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		value, ok := labels[r.key]
+
+		switch r.operator {
+		case labelEquals:
+			if !ok || value != r.value {
+				return false
+			}
+		case labelNotEquals:
+			if ok && value == r.value {
+				return false
+			}
+		case labelExists:
+			if !ok {
+				return false
+			}
+		case labelNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Empty tells whether the selector has no requirement, and so matches every
+// host.
+func (s LabelSelector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+// ValidateLabelKey checks that key can be used in a label selector.
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("label key cannot be empty")
+	}
+	if strings.ContainsAny(key, ",=! ") {
+		return fmt.Errorf("label key %q cannot contain any of ',', '=', '!' or spaces", key)
+	}
+	return nil
+}
+
+// MatchesLabels tells whether the labels of the host satisfy selector.
+func (h *Host) MatchesLabels(selector LabelSelector) bool {
+	if h.HostOptions == nil {
+		return selector.Matches(nil)
+	}
+	return selector.Matches(h.HostOptions.Labels)
+}
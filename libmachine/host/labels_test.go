@@ -0,0 +1,52 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"team": "ci",
+		"env":  "staging",
+	}
+
+	testCases := []struct {
+		selector string
+		expected bool
+	}{
+		{"", true},
+		{"team=ci", true},
+		{"team==ci", true},
+		{"team=ci,env!=prod", true},
+		{"team=ci, env=prod", false},
+		{"env!=staging", false},
+		{"owner!=bob", true},
+		{"team", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!team", false},
+	}
+
+	for _, tc := range testCases {
+		selector, err := ParseLabelSelector(tc.selector)
+		assert.NoError(t, err, tc.selector)
+		assert.Equal(t, tc.expected, selector.Matches(labels), tc.selector)
+	}
+}
+
+func TestParseLabelSelectorInvalid(t *testing.T) {
+	for _, selector := range []string{"=ci", "!=prod", "!", "bad key=1"} {
+		_, err := ParseLabelSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestHostMatchesLabels(t *testing.T) {
+	selector, err := ParseLabelSelector("team=ci")
+	assert.NoError(t, err)
+
+	assert.False(t, (&Host{}).MatchesLabels(selector))
+	assert.True(t, (&Host{HostOptions: &Options{Labels: map[string]string{"team": "ci"}}}).MatchesLabels(selector))
+}
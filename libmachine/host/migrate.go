@@ -21,6 +21,9 @@ type RawDataDriver struct {
 }
 
 func (r *RawDataDriver) MarshalJSON() ([]byte, error) {
+	if r.Data == nil {
+		return []byte("null"), nil
+	}
 	return r.Data, nil
 }
 
@@ -45,16 +48,42 @@ func getMigratedHostMetadata(data []byte) (*Metadata, error) {
 }
 
 func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
+	h, report, err := MigrateHostWithReport(h, data)
+	if err != nil {
+		return nil, report != nil && report.Needed(), err
+	}
+
+	return h, report.Needed(), nil
+}
+
+// MigrateHostWithReport migrates a host like MigrateHost, and reports the
+// fields changed by each step of the migration. The report is also returned
+// along with errors happening during the migration.
+func MigrateHostWithReport(h *Host, data []byte) (*Host, *MigrationReport, error) {
 	var (
-		migrationNeeded    = false
-		migrationPerformed = false
-		hostV1             *V1
-		hostV2             *V2
+		migrationNeeded = false
+		hostV1          *V1
+		hostV2          *V2
 	)
 
 	migratedHostMetadata, err := getMigratedHostMetadata(data)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
+	}
+
+	report := &MigrationReport{
+		FromVersion: migratedHostMetadata.ConfigVersion,
+		ToVersion:   version.ConfigVersion,
+	}
+	before := flattenJSON(data)
+	addStep := func(from int, after interface{}) {
+		afterFields := flattenJSON(after)
+		report.Steps = append(report.Steps, MigrationStep{
+			FromVersion: from,
+			ToVersion:   from + 1,
+			Changes:     diffFields(before, afterFields),
+		})
+		before = afterFields
 	}
 
 	globalStorePath := filepath.Dir(filepath.Dir(migratedHostMetadata.HostOptions.AuthOptions.StorePath))
@@ -62,20 +91,19 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 	driver := &RawDataDriver{none.NewDriver(h.Name, globalStorePath), nil}
 
 	if migratedHostMetadata.ConfigVersion > version.ConfigVersion {
-		return nil, false, errConfigFromFuture
+		return nil, nil, errConfigFromFuture
 	}
 
 	if migratedHostMetadata.ConfigVersion == version.ConfigVersion {
 		h.Driver = driver
 		if err := json.Unmarshal(data, &h); err != nil {
-			return nil, migrationPerformed, fmt.Errorf("Error unmarshalling most recent host version: %s", err)
+			return nil, report, fmt.Errorf("Error unmarshalling most recent host version: %s", err)
 		}
 	} else {
 		migrationNeeded = true
 	}
 
 	if migrationNeeded {
-		migrationPerformed = true
 		for h.ConfigVersion = migratedHostMetadata.ConfigVersion; h.ConfigVersion < version.ConfigVersion; h.ConfigVersion++ {
 			log.Debugf("Migrating to config v%d", h.ConfigVersion)
 			switch h.ConfigVersion {
@@ -84,31 +112,34 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 					Driver: driver,
 				}
 				if err := json.Unmarshal(data, &hostV0); err != nil {
-					return nil, migrationPerformed, fmt.Errorf("Error unmarshalling host config version 0: %s", err)
+					return nil, report, fmt.Errorf("Error unmarshalling host config version 0: %s", err)
 				}
 				hostV1 = MigrateHostV0ToHostV1(hostV0)
+				addStep(0, hostV1)
 			case 1:
 				if hostV1 == nil {
 					hostV1 = &V1{
 						Driver: driver,
 					}
 					if err := json.Unmarshal(data, &hostV1); err != nil {
-						return nil, migrationPerformed, fmt.Errorf("Error unmarshalling host config version 1: %s", err)
+						return nil, report, fmt.Errorf("Error unmarshalling host config version 1: %s", err)
 					}
 				}
 				hostV2 = MigrateHostV1ToHostV2(hostV1)
+				addStep(1, hostV2)
 			case 2:
 				if hostV2 == nil {
 					hostV2 = &V2{
 						Driver: driver,
 					}
 					if err := json.Unmarshal(data, &hostV2); err != nil {
-						return nil, migrationPerformed, fmt.Errorf("Error unmarshalling host config version 2: %s", err)
+						return nil, report, fmt.Errorf("Error unmarshalling host config version 2: %s", err)
 					}
 				}
 				h = MigrateHostV2ToHostV3(hostV2, data, globalStorePath)
 				driver.Data = h.RawDriver
 				h.Driver = driver
+				addStep(2, h)
 			case 3:
 			}
 		}
@@ -116,5 +147,5 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 
 	h.RawDriver = driver.Data
 
-	return h, migrationPerformed, nil
+	return h, report, nil
 }
@@ -178,3 +178,40 @@ func TestMigrateHost(t *testing.T) {
 		assert.Equal(t, tc.expectedMigrationError, actualMigrationError)
 	}
 }
+
+func TestMigrateHostWithReport(t *testing.T) {
+	_, report, err := MigrateHostWithReport(&Host{}, v1conf)
+	assert.NoError(t, err)
+	assert.True(t, report.Needed())
+	assert.Equal(t, 1, report.FromVersion)
+	assert.Len(t, report.Steps, 2)
+
+	assert.Equal(t, 1, report.Steps[0].FromVersion)
+	assert.Contains(t, report.Steps[0].Changes, FieldChange{
+		Path: "HostOptions.AuthOptions.StorePath",
+		Kind: FieldChanged,
+		Old:  "",
+		New:  "/Users/catbug/.docker/machine",
+	})
+	assert.Contains(t, report.Steps[0].Changes, FieldChange{
+		Path: "StorePath",
+		Kind: FieldRemoved,
+		Old:  "/Users/catbug/.docker/machine/machines/foobar",
+	})
+	assert.Contains(t, report.Steps[0].Changes, FieldChange{
+		Path: "HostOptions.AuthOptions.PrivateKeyPath",
+		Kind: FieldRemoved,
+		Old:  "/Users/catbug/.docker/machine/certs/ca-key.pem",
+	})
+
+	assert.Equal(t, []FieldChange{{
+		Path: "Driver.StorePath",
+		Kind: FieldAdded,
+		New:  "/Users/catbug/.docker/machine",
+	}}, report.Steps[1].Changes)
+
+	_, report, err = MigrateHostWithReport(&Host{}, []byte(`{"ConfigVersion":3,"Driver":{}}`))
+	assert.NoError(t, err)
+	assert.False(t, report.Needed())
+	assert.Empty(t, report.Steps)
+}
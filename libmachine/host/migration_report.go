@@ -0,0 +1,116 @@
+package host
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// MigrationReport tells how the config of a host was migrated to the current
+// config version.
+type MigrationReport struct {
+	FromVersion int
+	ToVersion   int
+	Steps       []MigrationStep
+}
+
+// Needed tells whether the config had to be migrated.
+func (r *MigrationReport) Needed() bool {
+	return r.FromVersion < r.ToVersion
+}
+
+// MigrationStep lists the fields of the config changed when migrating it
+// from one version to the next.
+type MigrationStep struct {
+	FromVersion int
+	ToVersion   int
+	Changes     []FieldChange
+}
+
+type ChangeKind string
+
+const (
+	FieldAdded   ChangeKind = "added"
+	FieldRemoved ChangeKind = "removed"
+	FieldChanged ChangeKind = "changed"
+)
+
+// FieldChange is the change of a field of the config, identified by its
+// path in the JSON document, e.g. HostOptions.AuthOptions.StorePath.
+type FieldChange struct {
+	Path string
+	Kind ChangeKind
+	Old  interface{} `json:",omitempty"`
+	New  interface{} `json:",omitempty"`
+}
+
+// flattenJSON returns the fields of a config by path. Objects are flattened,
+// other values, arrays included, are kept whole. The config is either raw
+// JSON data, or a value to marshal.
+func flattenJSON(config interface{}) map[string]interface{} {
+	data, ok := config.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(config); err != nil {
+			return nil
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+	flattenValue(fields, "", v)
+
+	return fields
+}
+
+func flattenValue(fields map[string]interface{}, path string, v interface{}) {
+	object, ok := v.(map[string]interface{})
+	if !ok || (len(object) == 0 && path != "") {
+		fields[path] = v
+		return
+	}
+
+	for key, value := range object {
+		if path != "" {
+			key = path + "." + key
+		}
+		flattenValue(fields, key, value)
+	}
+}
+
+// diffFields returns the changes between two flattened configs, sorted by
+// path. The config version is left out, as it is the one of the step.
+func diffFields(before, after map[string]interface{}) []FieldChange {
+	changes := []FieldChange{}
+
+	for path, oldValue := range before {
+		if newValue, ok := after[path]; !ok {
+			changes = append(changes, FieldChange{Path: path, Kind: FieldRemoved, Old: oldValue})
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, FieldChange{Path: path, Kind: FieldChanged, Old: oldValue, New: newValue})
+		}
+	}
+
+	for path, newValue := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, FieldChange{Path: path, Kind: FieldAdded, New: newValue})
+		}
+	}
+
+	filtered := changes[:0]
+	for _, c := range changes {
+		if c.Path != "ConfigVersion" {
+			filtered = append(filtered, c)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Path < filtered[j].Path
+	})
+
+	return filtered
+}
@@ -0,0 +1,232 @@
+package host
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The JSON Schemas of the config versions, schema/v<version>.json. They only
+// use the subset of JSON Schema understood by ValidateConfig: type, enum,
+// required, properties, additionalProperties, items and local $ref.
+//
+//go:embed schema/*.json
+var configSchemas embed.FS
+
+type ErrInvalidConfig struct {
+	ConfigVersion int
+	Errors        []string
+}
+
+func (e ErrInvalidConfig) Error() string {
+	return fmt.Sprintf("Invalid config version %d: %s", e.ConfigVersion, strings.Join(e.Errors, ", "))
+}
+
+// ConfigSchema returns the JSON Schema of a config version.
+func ConfigSchema(configVersion int) ([]byte, error) {
+	data, err := configSchemas.ReadFile(fmt.Sprintf("schema/v%d.json", configVersion))
+	if err != nil {
+		return nil, fmt.Errorf("No schema for config version %d", configVersion)
+	}
+
+	return data, nil
+}
+
+// ValidateConfig checks a stored host config against the JSON Schema of its
+// config version. It returns an ErrInvalidConfig listing all the errors
+// found, or nil if the config is valid.
+func ValidateConfig(data []byte) error {
+	var config interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	var metadata struct {
+		ConfigVersion int
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return err
+	}
+
+	schemaData, err := ConfigSchema(metadata.ConfigVersion)
+	if err != nil {
+		return err
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		return err
+	}
+
+	v := &schemaValidator{root: schema}
+	v.validate("", schema, config)
+
+	if len(v.errors) > 0 {
+		return ErrInvalidConfig{
+			ConfigVersion: metadata.ConfigVersion,
+			Errors:        v.errors,
+		}
+	}
+
+	return nil
+}
+
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors []string
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "config"
+	}
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(path string, schema map[string]interface{}, value interface{}) {
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = v.resolve(ref)
+		if schema == nil {
+			v.fail(path, "unknown schema reference %s", ref)
+			return
+		}
+	}
+
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		v.fail(path, "expected %s, got %s", typeNames(types), jsonType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || reflect.DeepEqual(allowed, value)
+		}
+		if !found {
+			v.fail(path, "%v is not one of %v", value, enum)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(path, schema, value)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(path string, schema map[string]interface{}, object map[string]interface{}) {
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, present := object[name.(string)]; !present {
+				v.fail(path, "missing required field %s", name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	names := []string{}
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			v.validate(prefix+name, property, object[name])
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(prefix+name, "unknown field")
+			}
+		case map[string]interface{}:
+			v.validate(prefix+name, additional, object[name])
+		}
+	}
+}
+
+// resolve returns the schema referenced by a local $ref, e.g.
+// #/definitions/HostOptions.
+func (v *schemaValidator) resolve(ref string) map[string]interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+
+	var node interface{} = v.root
+	for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = object[name]
+	}
+
+	schema, _ := node.(map[string]interface{})
+	return schema
+}
+
+func matchesType(types interface{}, value interface{}) bool {
+	switch types := types.(type) {
+	case string:
+		actual := jsonType(value)
+		return actual == types || (types == "number" && actual == "integer")
+	case []interface{}:
+		for _, t := range types {
+			if matchesType(t, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func typeNames(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		names := []string{}
+		for _, t := range list {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+
+	return fmt.Sprint(types)
+}
+
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
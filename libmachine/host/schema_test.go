@@ -0,0 +1,74 @@
+package host
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSchemas(t *testing.T) {
+	for configVersion := 0; configVersion <= version.ConfigVersion; configVersion++ {
+		data, err := ConfigSchema(configVersion)
+		assert.NoError(t, err)
+		assert.True(t, json.Valid(data), "schema of config version %d", configVersion)
+	}
+
+	_, err := ConfigSchema(version.ConfigVersion + 1)
+	assert.Error(t, err)
+}
+
+func TestValidateConfig(t *testing.T) {
+	h := &Host{
+		ConfigVersion: version.ConfigVersion,
+		Name:          "test",
+		DriverName:    "none",
+		Driver:        none.NewDriver("test", "/store"),
+		HostOptions: &Options{
+			EngineOptions: &engine.Options{StorageDriver: "overlay2"},
+			SwarmOptions:  &swarm.Options{},
+			AuthOptions:   &auth.Options{},
+			Labels:        map[string]string{"env": "test"},
+		},
+		CreateStatus: &CreateStatus{State: CreateFailed},
+	}
+	current, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range [][]byte{v0conf, v1conf, current} {
+		assert.NoError(t, ValidateConfig(config))
+	}
+
+	err = ValidateConfig([]byte(`{
+		"ConfigVersion": 3,
+		"Driver": {},
+		"DriverName": 1,
+		"HostOptions": {
+			"Memory": "1GB",
+			"EngineOptions": {"Dns": ["8.8.8.8", 8]},
+			"Labels": {"env": true}
+		},
+		"CreateStatus": {"State": "Done"},
+		"NewField": "is allowed"
+	}`))
+	assert.Equal(t, ErrInvalidConfig{
+		ConfigVersion: 3,
+		Errors: []string{
+			"config: missing required field Name",
+			"CreateStatus.State: Done is not one of [Creating CreateFailed]",
+			"DriverName: expected string, got integer",
+			"HostOptions.EngineOptions.Dns[1]: expected string, got integer",
+			"HostOptions.Labels.env: expected string, got boolean",
+			"HostOptions.Memory: expected integer, got string",
+		},
+	}, err)
+
+	assert.EqualError(t, ValidateConfig([]byte(`{"ConfigVersion": 9}`)), "No schema for config version 9")
+}
@@ -0,0 +1,58 @@
+package host
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/event"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// TakeSnapshot saves the current state of the machine under name. It returns
+// drivers.ErrNotSupported if the driver cannot take snapshots.
+func (h *Host) TakeSnapshot(name, description string) error {
+	return h.runOperation(event.TakeSnapshot, func() error {
+		s, err := drivers.NewSnapshotter(h.Driver)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Taking snapshot %q of %q...", name, h.Name)
+		return s.TakeSnapshot(name, description)
+	})
+}
+
+// ListSnapshots returns the snapshots of the machine.
+func (h *Host) ListSnapshots() ([]drivers.Snapshot, error) {
+	s, err := drivers.NewSnapshotter(h.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ListSnapshots()
+}
+
+// RestoreSnapshot brings the machine back to the state saved under name.
+// Some drivers, e.g. virtualbox, require the machine to be stopped.
+func (h *Host) RestoreSnapshot(name string) error {
+	return h.runOperation(event.RestoreSnapshot, func() error {
+		s, err := drivers.NewSnapshotter(h.Driver)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Restoring snapshot %q of %q...", name, h.Name)
+		return s.RestoreSnapshot(name)
+	})
+}
+
+// DeleteSnapshot removes the snapshot of the machine saved under name.
+func (h *Host) DeleteSnapshot(name string) error {
+	return h.runOperation(event.DeleteSnapshot, func() error {
+		s, err := drivers.NewSnapshotter(h.Driver)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Deleting snapshot %q of %q...", name, h.Name)
+		return s.DeleteSnapshot(name)
+	})
+}
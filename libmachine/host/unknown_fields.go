@@ -0,0 +1,123 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Configs written by newer versions of Docker Machine can have fields this
+// version does not know. They are kept in UnknownFields when a host is
+// loaded and written back verbatim when it is saved, so that using an older
+// client does not lose them.
+
+type hostFields Host
+
+func (h Host) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(hostFields(h))
+	if err != nil {
+		return nil, err
+	}
+
+	return appendUnknownFields(data, h.UnknownFields)
+}
+
+func (h *Host) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*hostFields)(h)); err != nil {
+		return err
+	}
+
+	unknownFields, err := getUnknownFields(data, reflect.TypeOf(*h))
+	h.UnknownFields = unknownFields
+	return err
+}
+
+type optionsFields Options
+
+func (o Options) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(optionsFields(o))
+	if err != nil {
+		return nil, err
+	}
+
+	return appendUnknownFields(data, o.UnknownFields)
+}
+
+func (o *Options) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*optionsFields)(o)); err != nil {
+		return err
+	}
+
+	unknownFields, err := getUnknownFields(data, reflect.TypeOf(*o))
+	o.UnknownFields = unknownFields
+	return err
+}
+
+// getUnknownFields returns the fields of a JSON object which do not match a
+// field of the struct type t. Like encoding/json, names are matched case
+// insensitively.
+func getUnknownFields(data []byte, t reflect.Type) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// Fields which are not saved, like RawDriver, are still known, as
+		// older versions saved some of them.
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = f.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+
+	var unknownFields map[string]json.RawMessage
+	for name, value := range fields {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		if unknownFields == nil {
+			unknownFields = map[string]json.RawMessage{}
+		}
+		unknownFields[name] = value
+	}
+
+	return unknownFields, nil
+}
+
+// appendUnknownFields adds fields, sorted by name, at the end of the JSON
+// object data.
+func appendUnknownFields(data []byte, fields map[string]json.RawMessage) ([]byte, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(bytes.TrimSuffix(bytes.TrimSpace(data), []byte("}")))
+	for i, name := range names {
+		if i > 0 || !bytes.HasSuffix(buf.Bytes(), []byte("{")) {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(fields[name])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+package host
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownFieldsRoundTrip(t *testing.T) {
+	config := []byte(`{
+		"ConfigVersion": 3,
+		"Driver": {"MachineName": "test"},
+		"DriverName": "none",
+		"HostOptions": {
+			"Driver": "",
+			"Memory": 0,
+			"Disk": 0,
+			"EngineOptions": null,
+			"SwarmOptions": null,
+			"AuthOptions": null,
+			"FutureOptions": {"Enabled": true}
+		},
+		"Name": "test",
+		"RawDriver": "ignored",
+		"FutureField": [1, 2]
+	}`)
+
+	h, _, err := MigrateHost(&Host{Name: "test"}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"FutureField": json.RawMessage(`[1, 2]`)}, h.UnknownFields)
+	assert.Equal(t, map[string]json.RawMessage{"FutureOptions": json.RawMessage(`{"Enabled": true}`)}, h.HostOptions.UnknownFields)
+
+	saved, err := json.Marshal(h)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"ConfigVersion": 3,
+		"Driver": {"MachineName": "test"},
+		"DriverName": "none",
+		"HostOptions": {
+			"Driver": "",
+			"Memory": 0,
+			"Disk": 0,
+			"EngineOptions": null,
+			"SwarmOptions": null,
+			"AuthOptions": null,
+			"FutureOptions": {"Enabled": true}
+		},
+		"Name": "test",
+		"FutureField": [1, 2]
+	}`, string(saved))
+
+	indented, err := json.MarshalIndent(h, "", "    ")
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(saved), string(indented))
+}
+
+func TestAppendUnknownFields(t *testing.T) {
+	data, err := appendUnknownFields([]byte(`{}`), map[string]json.RawMessage{"b": json.RawMessage(`2`), "a": json.RawMessage(`1`)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1,"b":2}`, string(data))
+
+	data, err = appendUnknownFields([]byte(`{"c":3}`), map[string]json.RawMessage{"a": json.RawMessage(`1`)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"c":3,"a":1}`, string(data))
+}
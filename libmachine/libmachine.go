@@ -1,8 +1,12 @@
 package libmachine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
 
 	"io"
 
@@ -14,11 +18,13 @@ import (
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/event"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/profile"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
@@ -30,40 +36,159 @@ type API interface {
 	io.Closer
 	NewHost(driverName string, rawDriver []byte) (*host.Host, error)
 	Create(h *host.Host) error
+	CreateContext(ctx context.Context, h *host.Host) error
 	persist.Store
 	GetMachinesDir() string
 }
 
+// CreateFailurePolicy tells Create what to do with a machine whose creation
+// failed once it was saved to the store.
+type CreateFailurePolicy int
+
+const (
+	// KeepOnCreateFailure leaves the machine and its store entry as they
+	// are. This is the default.
+	KeepOnCreateFailure CreateFailurePolicy = iota
+
+	// RollbackOnCreateFailure removes the machine through its driver, and
+	// removes it from the store.
+	RollbackOnCreateFailure
+
+	// MarkOnCreateFailure keeps the machine, but records in the store that
+	// its creation failed, and in which phase.
+	MarkOnCreateFailure
+)
+
+// Client creates and loads the machines of a store. Hosts are persisted
+// through Store, any persist.Store.
 type Client struct {
-	certsDir       string
-	IsDebug        bool
-	SSHClientType  ssh.ClientType
-	GithubAPIToken string
-	*persist.Filestore
+	certsDir      string
+	IsDebug       bool
+	SSHClientType ssh.ClientType
+	// SSHConnectionReuse makes the provisioning of the machines created or
+	// loaded by the Client, e.g. by Create or Host.ConfigureAuth, run all
+	// its SSH commands over one native connection per machine, see
+	// ssh.Pool, whatever SSHClientType. It is off by default, and must be
+	// set before the hosts are created or loaded.
+	SSHConnectionReuse  bool
+	GithubAPIToken      string
+	CreateFailurePolicy CreateFailurePolicy
+	persist.Store
+	// Filestore is Store when it is a *persist.Filestore, as returned by
+	// NewClient, and nil otherwise. It is kept for the code which used the
+	// Filestore embedded in Client before any Store was accepted, e.g. to
+	// read its Path.
+	Filestore           *persist.Filestore
+	storePath           string
 	clientDriverFactory rpc.RPCClientDriverFactory
+	sshPool             *ssh.Pool
+	sshPoolLock         sync.Mutex
+	events              event.Bus
 }
 
+// NewClient returns a Client which keeps one config.json per machine
+// directory under storePath.
 func NewClient(storePath, certsDir string) *Client {
+	return NewClientWithStore(storePath, certsDir, persist.NewFilestore(storePath, certsDir, certsDir))
+}
+
+// NewClientWithStore returns a Client which persists hosts into store. The
+// machine directories holding certificates and driver files are still
+// created under storePath.
+func NewClientWithStore(storePath, certsDir string, store persist.Store) *Client {
+	filestore, _ := store.(*persist.Filestore)
+
+	// The checked copies of the plugin binaries are kept in the store, and
+	// reused by every machine using the same binary.
+	pluginOpts := localbinary.PluginOptions{
+		Dirs:    []string{filepath.Join(storePath, "plugins")},
+		CopyDir: filepath.Join(storePath, "plugins", "checked"),
+	}
+
 	return &Client{
 		certsDir:            certsDir,
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
-		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
-		clientDriverFactory: rpc.NewRPCClientDriverFactory(),
+		Store:               store,
+		Filestore:           filestore,
+		storePath:           storePath,
+		clientDriverFactory: rpc.NewRPCClientDriverFactoryWithOptions(pluginOpts),
 	}
 }
 
+func (api *Client) GetMachinesDir() string {
+	return filepath.Join(api.storePath, "machines")
+}
+
+// GetPluginsDir returns the directory where driver plugin binaries are looked
+// up, after the MACHINE_PLUGIN_PATH directories and before $PATH.
+func (api *Client) GetPluginsDir() string {
+	return filepath.Join(api.storePath, "plugins")
+}
+
+// ListAvailableDrivers returns the drivers which can be used by NewHost. The
+// drivers registered in-process come first, followed by the plugins found on
+// the plugin search path, which are started to query their version and flags.
+func (api *Client) ListAvailableDrivers() []rpc.DriverInfo {
+	infos := []rpc.DriverInfo{}
+	for _, name := range drivers.Registered() {
+		factory, _ := drivers.Lookup(name)
+		infos = append(infos, rpc.DriverInfo{
+			Name:    name,
+			Version: version.APIVersion,
+			Flags:   factory().GetCreateFlags(),
+		})
+	}
+
+	for _, info := range rpc.ListAvailableDrivers(api.GetPluginsDir()) {
+		if _, ok := drivers.Lookup(info.Name); !ok {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// newDriver returns the driver of a machine, configured from rawDriver. Drivers
+// registered with drivers.Register run in-process, all the others are run as
+// plugin binaries, which must match checksum unless it is empty.
+func (api *Client) newDriver(driverName string, rawDriver []byte, checksum string) (drivers.Driver, error) {
+	factory, ok := drivers.Lookup(driverName)
+	if !ok {
+		return api.clientDriverFactory.NewPinnedRPCClientDriver(driverName, rawDriver, checksum)
+	}
+
+	d := factory()
+	if err := json.Unmarshal(rawDriver, d); err != nil {
+		return nil, fmt.Errorf("Error loading config of driver %s: %s", driverName, err)
+	}
+
+	return d, nil
+}
+
+// pluginChecksum returns the checksum of the plugin binary running d, which
+// is empty for core and in-process drivers.
+func pluginChecksum(d drivers.Driver) string {
+	if c, ok := d.(*rpc.RPCClientDriver); ok {
+		return c.PluginChecksum()
+	}
+	return ""
+}
+
 func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
-	driver, err := api.clientDriverFactory.NewRPCClientDriver(driverName, rawDriver)
+	driver, err := api.newDriver(driverName, rawDriver, "")
 	if err != nil {
 		return nil, err
 	}
 
 	return &host.Host{
-		ConfigVersion: version.ConfigVersion,
-		Name:          driver.GetMachineName(),
-		Driver:        driver,
-		DriverName:    driver.DriverName(),
+		ConfigVersion:  version.ConfigVersion,
+		Name:           driver.GetMachineName(),
+		Driver:         driver,
+		DriverName:     driver.DriverName(),
+		DriverChecksum: pluginChecksum(driver),
+		Events:         &api.events,
+		SSHPool:        api.hostSSHPool(),
 		HostOptions: &host.Options{
 			AuthOptions: &auth.Options{
 				CertDir:          api.certsDir,
@@ -88,20 +213,181 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 	}, nil
 }
 
+// GetProfilesDir returns the directory where the profiles used by
+// NewHostFromProfile are stored.
+func (api *Client) GetProfilesDir() string {
+	return filepath.Join(api.storePath, "profiles")
+}
+
+// Profiles returns the store of the profiles used by NewHostFromProfile.
+func (api *Client) Profiles() *profile.Store {
+	return profile.NewStore(api.GetProfilesDir())
+}
+
+// NewHostFromProfile returns a new host named machineName, created with the
+// driver and options of the named profile. The driver is configured from the
+// flag values of the profile, overridden by flags.
+func (api *Client) NewHostFromProfile(profileName, machineName string, flags map[string]interface{}) (*host.Host, error) {
+	p, err := api.Profiles().Resolve(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.DriverName == "" {
+		return nil, fmt.Errorf("Profile %s does not set a driver", profileName)
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: machineName,
+		StorePath:   api.storePath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := api.NewHost(p.DriverName, rawDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.ApplyOptions(h.HostOptions); err != nil {
+		return nil, err
+	}
+
+	createFlags := h.Driver.GetCreateFlags()
+	values, err := p.FlagValues(createFlags, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := drivers.ValidateFlagValues(createFlags, values); err != nil {
+		return nil, err
+	}
+	h.HostOptions.DriverFlags = values
+
+	// The global flags are passed to drivers along with their own.
+	driverOptions := &rpc.RPCFlags{Values: map[string]interface{}{
+		"swarm-master":       h.HostOptions.SwarmOptions.Master,
+		"swarm-host":         h.HostOptions.SwarmOptions.Host,
+		"swarm-discovery":    h.HostOptions.SwarmOptions.Discovery,
+		"engine-install-url": h.HostOptions.EngineOptions.InstallURL,
+	}}
+	for name, value := range values {
+		driverOptions.Values[name] = value
+	}
+
+	if err := h.Driver.SetConfigFromFlags(driverOptions); err != nil {
+		return nil, fmt.Errorf("Error setting machine configuration from profile %s: %s", profileName, err)
+	}
+
+	return h, nil
+}
+
+// ExportProfile saves the configuration of the named host as a profile. An
+// existing profile is only replaced with overwrite, otherwise
+// profile.ErrProfileExists is returned. The driver flags are only exported for
+// hosts created from a profile, see profile.FromHost.
+func (api *Client) ExportProfile(hostName, profileName string, overwrite bool) (*profile.Profile, error) {
+	h, err := api.Load(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := profile.FromHost(profileName, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.DriverFlags) == 0 {
+		log.Warnf("Machine %s was not created from a profile, profile %s does not set the flags of driver %s", hostName, profileName, h.DriverName)
+	}
+
+	if overwrite {
+		err = api.Profiles().Save(p)
+	} else {
+		err = api.Profiles().Create(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SaveIfUnchanged persists h unless the stored host was changed since h was
+// loaded, see persist.ConditionalStore.
+func (api *Client) SaveIfUnchanged(h *host.Host) error {
+	store, ok := api.Store.(persist.ConditionalStore)
+	if !ok {
+		return fmt.Errorf("Store %T does not support conditional saves", api.Store)
+	}
+
+	return store.SaveIfUnchanged(h)
+}
+
+// saveProgress saves h while it is being created, or once an operation such as
+// provisioning changed it. If the store supports it, h is only saved if it
+// was not changed by someone else in the meantime, e.g. by a concurrent
+// regenerate-certs, and mcnerror.ErrHostConflict is returned otherwise.
+func (api *Client) saveProgress(h *host.Host) error {
+	if store, ok := api.Store.(persist.ConditionalStore); ok {
+		return store.SaveIfUnchanged(h)
+	}
+
+	return api.Save(h)
+}
+
+// MigrateAll migrates the configs of all the machines of the store which are
+// not at the current config version, see persist.Migrator. With dryRun, it
+// only reports the machines needing a migration and those failing to load.
+func (api *Client) MigrateAll(dryRun bool) ([]persist.MigrationResult, error) {
+	store, ok := api.Store.(persist.Migrator)
+	if !ok {
+		return nil, fmt.Errorf("Store %T does not support migrating all machines", api.Store)
+	}
+
+	return store.MigrateAll(dryRun)
+}
+
+// ListByLabels returns the names of the hosts whose labels match selector,
+// e.g. "team=ci,env!=prod". Hosts are read from the store without starting
+// their driver plugins. Those which cannot be read are left out, and reported
+// in a mcnutils.MultiError returned along with the other names.
+func (api *Client) ListByLabels(selector string) ([]string, error) {
+	return persist.ListByLabels(api.Store, selector)
+}
+
 func (api *Client) Load(name string) (*host.Host, error) {
-	h, err := api.Filestore.Load(name)
+	h, err := api.Store.Load(name)
 	if err != nil {
 		return nil, err
 	}
 
-	d, err := api.clientDriverFactory.NewRPCClientDriver(h.DriverName, h.RawDriver)
+	if err := api.loadDriver(h); err != nil {
+		return nil, err
+	}
+	h.Events = &api.events
+	h.SSHPool = api.hostSSHPool()
+
+	return h, nil
+}
+
+// loadDriver sets the driver of h, read from the store, starting its plugin.
+func (api *Client) loadDriver(h *host.Host) error {
+	d, err := api.newDriver(h.DriverName, h.RawDriver, h.DriverChecksum)
 	if err != nil {
 		// Not being able to find a driver binary is a "known error"
 		if _, ok := err.(localbinary.ErrPluginBinaryNotFound); ok {
 			h.Driver = errdriver.NewDriver(h.DriverName)
-			return h, nil
+			return nil
 		}
-		return nil, err
+		return err
+	}
+
+	// Hosts saved before checksums were recorded get pinned to the plugin
+	// binary they are loaded with the next time they are saved.
+	if h.DriverChecksum == "" {
+		h.DriverChecksum = pluginChecksum(d)
 	}
 
 	if h.DriverName == "virtualbox" {
@@ -110,79 +396,372 @@ func (api *Client) Load(name string) (*host.Host, error) {
 		h.Driver = d
 	}
 
-	return h, nil
+	return nil
+}
+
+// Subscribe registers s to receive the lifecycle events of the hosts created
+// or loaded by api, and only those. The returned func unregisters it.
+func (api *Client) Subscribe(s event.Subscriber) func() {
+	return api.events.Subscribe(s)
+}
+
+// Events returns a channel receiving the lifecycle events of the hosts created
+// or loaded by api, see event.Bus.Channel.
+func (api *Client) Events(buffer int) (<-chan event.Event, func()) {
+	return api.events.Channel(buffer)
+}
+
+// RepinDriver pins the named machine to the plugin binary now serving its
+// driver, e.g. after the plugin was upgraded and the machine fails to load
+// with a localbinary.ErrPluginChecksumMismatch.
+func (api *Client) RepinDriver(name string) error {
+	h, err := api.Store.Load(name)
+	if err != nil {
+		return err
+	}
+
+	d, err := api.newDriver(h.DriverName, h.RawDriver, "")
+	if err != nil {
+		return err
+	}
+
+	h.Driver = d
+	h.DriverChecksum = pluginChecksum(d)
+
+	return api.saveProgress(h)
 }
 
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func (api *Client) Create(h *host.Host) error {
-	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
-		return fmt.Errorf("Error generating certificates: %s", err)
+	return api.CreateContext(context.Background(), h)
+}
+
+// CreateContext is like Create, but gives up as soon as ctx is cancelled or
+// its deadline expires. Cancellation is checked between each step of the
+// creation, and passed on to the driver while it creates the machine.
+func (api *Client) CreateContext(ctx context.Context, h *host.Host) error {
+	if h.Events == nil {
+		h.Events = &api.events
+	}
+	if h.SSHPool == nil {
+		h.SSHPool = api.hostSSHPool()
+	}
+
+	// The whole creation is published as a phase, but its errors are
+	// returned as they are, e.g. mcnerror.ErrDuringPreCreate.
+	return h.Events.Run(h.Name, h.DriverName, event.Create, func() error {
+		return api.create(ctx, h)
+	})
+}
+
+// phaseError is an error which happened during a phase of the creation.
+type phaseError struct {
+	phase event.Phase
+	err   error
+}
+
+func (e phaseError) Error() string {
+	return e.err.Error()
+}
+
+func (e phaseError) Unwrap() error {
+	return e.err
+}
+
+// runPhase runs f as a phase of the lifecycle of h, publishing its progress
+// to the subscribers of h.Events. Errors are tagged with the phase they
+// happened in.
+func runPhase(h *host.Host, phase event.Phase, f func() error) error {
+	if err := h.Events.Run(h.Name, h.DriverName, phase, f); err != nil {
+		return phaseError{phase: phase, err: err}
+	}
+	return nil
+}
+
+func (api *Client) create(ctx context.Context, h *host.Host) error {
+	if err := runPhase(h, event.BootstrapCerts, func() error {
+		return cert.BootstrapCertificates(h.AuthOptions())
+	}); err != nil {
+		return fmt.Errorf("Error generating certificates: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	log.Info("Running pre-create checks...")
 
-	if err := h.Driver.PreCreateCheck(); err != nil {
+	if err := runPhase(h, event.PreCreateCheck, h.Driver.PreCreateCheck); err != nil {
 		return mcnerror.ErrDuringPreCreate{
 			Cause: err,
 		}
 	}
 
-	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store before attempting creation: %s", err)
+	h.CreateStatus = &host.CreateStatus{State: host.CreateInProgress}
+	if err := api.saveProgress(h); err != nil {
+		return fmt.Errorf("Error saving host to store before attempting creation: %w", err)
 	}
 
 	log.Info("Creating machine...")
 
-	if err := api.performCreate(h); err != nil {
-		return fmt.Errorf("Error creating machine: %s", err)
+	return api.performCreate(ctx, h, 0)
+}
+
+// ResumeCreate continues the creation of a machine which was interrupted or
+// failed, starting with the phase following the last one which completed.
+// The machine created by the driver is kept, so e.g. a provisioning failure
+// caused by an unreachable package mirror can be retried.
+func (api *Client) ResumeCreate(name string) error {
+	return api.ResumeCreateContext(context.Background(), name)
+}
+
+// ResumeCreateContext is like ResumeCreate, but gives up as soon as ctx is
+// cancelled or its deadline expires.
+func (api *Client) ResumeCreateContext(ctx context.Context, name string) error {
+	h, err := api.Load(name)
+	if err != nil {
+		return err
 	}
 
-	log.Debug("Reticulating splines...")
+	if h.CreateStatus == nil {
+		return mcnerror.ErrHostNotResumable{Name: name}
+	}
 
-	return nil
+	return h.Events.Run(h.Name, h.DriverName, event.Create, func() error {
+		return api.resumeCreate(ctx, h)
+	})
 }
 
-func (api *Client) performCreate(h *host.Host) error {
-	if err := h.Driver.Create(); err != nil {
-		return fmt.Errorf("Error in driver during machine creation: %s", err)
+func (api *Client) resumeCreate(ctx context.Context, h *host.Host) error {
+	next := 0
+	for i, phase := range createPhases {
+		if phase == h.CreateStatus.CompletedPhase {
+			next = i + 1
+		}
 	}
 
-	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store after attempting creation: %s", err)
+	h.CreateStatus = &host.CreateStatus{
+		State:          host.CreateInProgress,
+		CompletedPhase: h.CreateStatus.CompletedPhase,
 	}
 
-	// TODO: Not really a fan of just checking "none" or "ci-test" here.
-	if h.Driver.DriverName() == "none" || h.Driver.DriverName() == "ci-test" {
+	log.Infof("Resuming the creation of %q...", h.Name)
+
+	// Every phase completed, but the host was not saved as created.
+	if next == len(createPhases) {
+		h.CreateStatus = nil
+		if err := api.saveProgress(h); err != nil {
+			return fmt.Errorf("Error saving host to store after creation: %w", err)
+		}
 		return nil
 	}
 
-	log.Info("Waiting for machine to be running, this may take a few minutes...")
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
-		return fmt.Errorf("Error waiting for machine to be running: %s", err)
+	// The driver may have created the machine before the process creating
+	// it died, in which case creating it again would fail or build another
+	// one.
+	if createPhases[next] == event.DriverCreate {
+		created, err := machineCreated(ctx, h)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Infof("%q was already created by the driver, skipping to the next phase", h.Name)
+			h.CreateStatus.CompletedPhase = event.DriverCreate
+			next++
+		}
 	}
 
-	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
-	if err != nil {
-		return fmt.Errorf("Error detecting OS: %s", err)
+	return api.performCreate(ctx, h, next)
+}
+
+// machineCreated tells whether the driver of h already created its machine,
+// starting it if it is stopped so that the creation can go on.
+func machineCreated(ctx context.Context, h *host.Host) (bool, error) {
+	d := drivers.NewContextDriver(h.Driver)
+
+	s, err := d.GetStateContext(ctx)
+	if err != nil || s == state.None || s == state.Error {
+		log.Debugf("Assuming %q was not created: state %s, error %v", h.Name, s, err)
+		return false, nil
 	}
 
-	log.Infof("Provisioning with %s...", provisioner.String())
-	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
-		return fmt.Errorf("Error running provisioning: %s", err)
+	if s == state.Stopped {
+		if err := d.StartContext(ctx); err != nil {
+			return false, fmt.Errorf("Error starting the machine created before: %s", err)
+		}
+	}
+
+	return true, nil
+}
+
+// createPhases are the phases of the creation run once the host was saved to
+// the store, in order. The last one completed is recorded in the host's
+// CreateStatus, which lets ResumeCreate skip them.
+var createPhases = []event.Phase{
+	event.DriverCreate,
+	event.WaitRunning,
+	event.DetectOS,
+	event.Provision,
+	event.ConnCheck,
+}
+
+// creation holds what the phases of the creation of a host pass on to the
+// following ones.
+type creation struct {
+	h           *host.Host
+	provisioner provision.Provisioner
+}
+
+// hostSSHPool returns the SSH pool shared by the hosts of api, which is
+// created on first use and closed by Close, or nil without SSHConnectionReuse.
+func (api *Client) hostSSHPool() *ssh.Pool {
+	if !api.SSHConnectionReuse {
+		return nil
+	}
+
+	api.sshPoolLock.Lock()
+	defer api.sshPoolLock.Unlock()
+
+	if api.sshPool == nil {
+		api.sshPool = ssh.NewPool()
+	}
+
+	return api.sshPool
+}
+
+// performCreate runs the creation phases of h starting with createPhases[from],
+// saving h after each one to record its progress. The save following the last
+// phase also clears its CreateStatus, so that a host is never stored as being
+// created with no phase left to run.
+func (api *Client) performCreate(ctx context.Context, h *host.Host, from int) error {
+	c := &creation{h: h}
+
+	for i, phase := range createPhases[from:] {
+		if err := ctx.Err(); err != nil {
+			// The phase did not start, but the machine may be half
+			// created all the same.
+			err = phaseError{phase: phase, err: err}
+			api.handleCreateFailure(h, err)
+			return fmt.Errorf("Error creating machine: %w", err)
+		}
+
+		if err := runPhase(h, phase, func() error {
+			return c.run(ctx, phase)
+		}); err != nil {
+			api.handleCreateFailure(h, err)
+			return fmt.Errorf("Error creating machine: %w", err)
+		}
+
+		// TODO: Not really a fan of just checking "none" or "ci-test" here.
+		last := from+i == len(createPhases)-1 ||
+			phase == event.DriverCreate && (h.Driver.DriverName() == "none" || h.Driver.DriverName() == "ci-test")
+
+		if last {
+			h.CreateStatus = nil
+		} else {
+			h.CreateStatus.CompletedPhase = phase
+		}
+		if err := api.saveProgress(h); err != nil {
+			return fmt.Errorf("Error saving host to store after attempting creation: %w", err)
+		}
+
+		if last {
+			break
+		}
 	}
 
-	// We should check the connection to docker here
-	log.Info("Checking connection to Docker...")
-	if _, _, err = check.DefaultConnChecker.Check(h, false); err != nil {
-		return fmt.Errorf("Error checking the host: %s", err)
+	log.Debug("Reticulating splines...")
+
+	return nil
+}
+
+func (c *creation) run(ctx context.Context, phase event.Phase) error {
+	h := c.h
+
+	switch phase {
+	case event.DriverCreate:
+		if err := drivers.NewContextDriver(h.Driver).CreateContext(ctx); err != nil {
+			return fmt.Errorf("Error in driver during machine creation: %s", err)
+		}
+	case event.WaitRunning:
+		log.Info("Waiting for machine to be running, this may take a few minutes...")
+		if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Running)); err != nil {
+			return fmt.Errorf("Error waiting for machine to be running: %s", err)
+		}
+	case event.DetectOS:
+		log.Info("Detecting operating system of created instance...")
+		provisioner, err := provision.DetectProvisioner(h.SSHDriver())
+		if err != nil {
+			return fmt.Errorf("Error detecting OS: %s", err)
+		}
+		c.provisioner = provisioner
+	case event.Provision:
+		// The OS was detected by a previous run when resuming.
+		if c.provisioner == nil {
+			provisioner, err := provision.DetectProvisioner(h.SSHDriver())
+			if err != nil {
+				return fmt.Errorf("Error detecting OS: %s", err)
+			}
+			c.provisioner = provisioner
+		}
+
+		log.Infof("Provisioning with %s...", c.provisioner.String())
+		if err := c.provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+			return fmt.Errorf("Error running provisioning: %s", err)
+		}
+	case event.ConnCheck:
+		// We should check the connection to docker here
+		log.Info("Checking connection to Docker...")
+		if _, _, err := check.DefaultConnChecker.Check(h, false); err != nil {
+			return fmt.Errorf("Error checking the host: %s", err)
+		}
+
+		log.Info("Docker is up and running!")
 	}
 
-	log.Info("Docker is up and running!")
 	return nil
 }
 
+// handleCreateFailure applies the CreateFailurePolicy to h, whose creation
+// failed with err. Failures to clean up are only logged, the creation error
+// being the one worth reporting.
+func (api *Client) handleCreateFailure(h *host.Host, err error) {
+	switch api.CreateFailurePolicy {
+	case RollbackOnCreateFailure:
+		log.Infof("Removing %q after its creation failed...", h.Name)
+		if err := drivers.NewContextDriver(h.Driver).RemoveContext(context.Background()); err != nil {
+			log.Warnf("Error removing machine %q: %s", h.Name, err)
+		}
+		if err := api.Store.Remove(h.Name); err != nil {
+			log.Warnf("Error removing machine %q from the store: %s", h.Name, err)
+		}
+	case MarkOnCreateFailure:
+		status := &host.CreateStatus{
+			State: host.CreateFailed,
+			Error: err.Error(),
+		}
+		if h.CreateStatus != nil {
+			status.CompletedPhase = h.CreateStatus.CompletedPhase
+		}
+		var perr phaseError
+		if errors.As(err, &perr) {
+			status.Phase = perr.phase
+		}
+		h.CreateStatus = status
+		if err := api.saveProgress(h); err != nil {
+			log.Warnf("Error recording the failed creation of %q: %s", h.Name, err)
+		}
+	}
+}
+
 func (api *Client) Close() error {
+	api.sshPoolLock.Lock()
+	if api.sshPool != nil {
+		api.sshPool.Close()
+		api.sshPool = nil
+	}
+	api.sshPoolLock.Unlock()
+
 	return api.clientDriverFactory.Close()
 }
@@ -0,0 +1,629 @@
+package libmachine
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/event"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/persist/persisttest"
+	"github.com/docker/machine/libmachine/profile"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/ssh/testserver"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+type removableDriver struct {
+	*fakedriver.Driver
+	created int
+	removed bool
+}
+
+func (d *removableDriver) Create() error {
+	d.created++
+	return nil
+}
+
+func (d *removableDriver) Remove() error {
+	d.removed = true
+	return nil
+}
+
+type failingConnChecker struct {
+	err error
+}
+
+func (c *failingConnChecker) Check(*host.Host, bool) (string, *auth.Options, error) {
+	return "", nil, c.err
+}
+
+func newTestCreate(t *testing.T, policy CreateFailurePolicy) (*Client, *host.Host, *removableDriver, func()) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewFakeProvisioner(nil),
+	})
+	check.DefaultConnChecker = &failingConnChecker{err: errors.New("connection refused")}
+
+	driver := &removableDriver{
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+
+	certsDir := filepath.Join(tmpDir, "certs")
+	h := &host.Host{
+		Name:       "test",
+		DriverName: "fake",
+		Driver:     driver,
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{
+				CertDir:          certsDir,
+				CaCertPath:       filepath.Join(certsDir, "ca.pem"),
+				CaPrivateKeyPath: filepath.Join(certsDir, "ca-key.pem"),
+				ClientCertPath:   filepath.Join(certsDir, "cert.pem"),
+				ClientKeyPath:    filepath.Join(certsDir, "key.pem"),
+			},
+			EngineOptions: &engine.Options{},
+			SwarmOptions:  &swarm.Options{},
+		},
+	}
+
+	api := &Client{
+		certsDir:            certsDir,
+		Store:               &persisttest.FakeStore{},
+		storePath:           tmpDir,
+		CreateFailurePolicy: policy,
+	}
+
+	return api, h, driver, func() {
+		provision.SetDetector(&provision.StandardDetector{})
+		check.DefaultConnChecker = &check.MachineConnChecker{}
+		os.RemoveAll(tmpDir)
+	}
+}
+
+type failingPreCreateDriver struct {
+	*removableDriver
+}
+
+func (d *failingPreCreateDriver) PreCreateCheck() error {
+	return errors.New("VT-X is not enabled")
+}
+
+func TestCreatePreCreateCheckError(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	h.Driver = &failingPreCreateDriver{removableDriver: driver}
+
+	err := api.Create(h)
+
+	preCreateErr, ok := err.(mcnerror.ErrDuringPreCreate)
+	assert.True(t, ok, "unexpected error: %v", err)
+	assert.EqualError(t, preCreateErr.Cause, "VT-X is not enabled")
+	assert.True(t, errors.As(err, &mcnerror.ErrDuringPreCreate{}))
+	assert.Equal(t, 0, driver.created)
+}
+
+func TestCreateFailureKeepsMachineByDefault(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	assert.Error(t, api.Create(h))
+
+	exists, _ := api.Exists(h.Name)
+	assert.True(t, exists)
+	assert.False(t, driver.removed)
+	assert.Equal(t, &host.CreateStatus{
+		State:          host.CreateInProgress,
+		CompletedPhase: event.Provision,
+	}, h.CreateStatus)
+}
+
+func TestCreateFailureRollback(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, RollbackOnCreateFailure)
+	defer cleanup()
+
+	assert.EqualError(t, api.Create(h), "Error creating machine: Error checking the host: connection refused")
+
+	exists, _ := api.Exists(h.Name)
+	assert.False(t, exists)
+	assert.True(t, driver.removed)
+}
+
+func TestCreateFailureMark(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, MarkOnCreateFailure)
+	defer cleanup()
+
+	assert.Error(t, api.Create(h))
+
+	assert.False(t, driver.removed)
+	assert.Equal(t, &host.CreateStatus{
+		State:          host.CreateFailed,
+		CompletedPhase: event.Provision,
+		Phase:          event.ConnCheck,
+		Error:          "Error checking the host: connection refused",
+	}, h.CreateStatus)
+}
+
+func TestCreateCancelledBetweenPhases(t *testing.T) {
+	for _, policy := range []CreateFailurePolicy{RollbackOnCreateFailure, MarkOnCreateFailure} {
+		api, h, driver, cleanup := newTestCreate(t, policy)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		unsubscribe := api.Subscribe(event.SubscriberFunc(func(e event.Event) {
+			if e.Kind == event.Finished && e.Phase == event.DriverCreate {
+				cancel()
+			}
+		}))
+
+		err := api.CreateContext(ctx, h)
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+
+		exists, _ := api.Exists(h.Name)
+		if policy == RollbackOnCreateFailure {
+			assert.False(t, exists)
+			assert.True(t, driver.removed)
+		} else {
+			assert.True(t, exists)
+			assert.Equal(t, &host.CreateStatus{
+				State:          host.CreateFailed,
+				CompletedPhase: event.DriverCreate,
+				Phase:          event.WaitRunning,
+				Error:          context.Canceled.Error(),
+			}, h.CreateStatus)
+		}
+
+		unsubscribe()
+		cancel()
+		cleanup()
+	}
+}
+
+func TestCreateSuccessClearsStatus(t *testing.T) {
+	api, h, _, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	check.DefaultConnChecker = &failingConnChecker{}
+
+	assert.NoError(t, api.Create(h))
+	assert.Nil(t, h.CreateStatus)
+}
+
+// statusRecordingStore records the CreateStatus of each saved host.
+type statusRecordingStore struct {
+	*persisttest.FakeStore
+	saved []*host.CreateStatus
+}
+
+func (s *statusRecordingStore) Save(h *host.Host) error {
+	var status *host.CreateStatus
+	if h.CreateStatus != nil {
+		copied := *h.CreateStatus
+		status = &copied
+	}
+	s.saved = append(s.saved, status)
+	return s.FakeStore.Save(h)
+}
+
+func TestCreateClearsStatusWithLastPhase(t *testing.T) {
+	api, h, _, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	check.DefaultConnChecker = &failingConnChecker{}
+	store := &statusRecordingStore{FakeStore: &persisttest.FakeStore{}}
+	api.Store = store
+
+	assert.NoError(t, api.Create(h))
+
+	// A host is never saved as being created with no phase left to run.
+	for _, status := range store.saved {
+		if status != nil {
+			assert.NotEqual(t, event.ConnCheck, status.CompletedPhase)
+		}
+	}
+	assert.Nil(t, store.saved[len(store.saved)-1])
+}
+
+func TestSubscribeOnlyReceivesOwnHosts(t *testing.T) {
+	api, h, _, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	check.DefaultConnChecker = &failingConnChecker{}
+	other := &Client{}
+
+	events, unsubscribe := api.Events(100)
+	otherEvents, otherUnsubscribe := other.Events(100)
+
+	assert.NoError(t, api.Create(h))
+	assert.NoError(t, h.Stop())
+	unsubscribe()
+	otherUnsubscribe()
+
+	var phases []event.Phase
+	for e := range events {
+		if e.Kind == event.Started {
+			phases = append(phases, e.Phase)
+		}
+	}
+	assert.Equal(t, []event.Phase{event.Create, event.BootstrapCerts, event.PreCreateCheck, event.DriverCreate, event.WaitRunning, event.DetectOS, event.Provision, event.ConnCheck, event.Stop}, phases)
+
+	_, ok := <-otherEvents
+	assert.False(t, ok)
+}
+
+func TestCreateDoesNotOverwriteConcurrentChanges(t *testing.T) {
+	api, h, _, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+
+	check.DefaultConnChecker = &failingConnChecker{}
+	store := persist.NewFilestore(api.storePath, api.certsDir, api.certsDir)
+	api.Store = store
+
+	// Someone else, e.g. regenerate-certs, saves the machine while it is
+	// being provisioned.
+	unsubscribe := api.Subscribe(event.SubscriberFunc(func(e event.Event) {
+		if e.Kind == event.Started && e.Phase == event.Provision {
+			other, err := store.Load(h.Name)
+			assert.NoError(t, err)
+			assert.NoError(t, store.Save(other))
+		}
+	}))
+	defer unsubscribe()
+
+	err := api.Create(h)
+
+	var conflict mcnerror.ErrHostConflict
+	assert.True(t, errors.As(err, &conflict), "unexpected error: %v", err)
+}
+
+// registerTestDriver makes the driver of h loadable from the store by
+// ResumeCreate.
+func registerTestDriver(h *host.Host) func() {
+	d := h.Driver
+	drivers.Register(h.DriverName, func() drivers.Driver {
+		return d
+	})
+	h.RawDriver = []byte(`{}`)
+
+	return func() {
+		drivers.Unregister(h.DriverName)
+	}
+}
+
+// sshServerDriver is a driver whose machine is served by an SSH test server.
+type sshServerDriver struct {
+	*removableDriver
+	server *testserver.Server
+}
+
+func (d *sshServerDriver) GetSSHHostname() (string, error) {
+	return d.server.Host, nil
+}
+
+func (d *sshServerDriver) GetSSHPort() (int, error) {
+	return d.server.Port, nil
+}
+
+func (d *sshServerDriver) GetSSHUsername() string {
+	return "docker"
+}
+
+// sshProvisioner runs its SSH commands through the driver it was detected
+// with.
+type sshProvisioner struct {
+	provision.Provisioner
+	driver drivers.Driver
+}
+
+func (p *sshProvisioner) Provision(swarm.Options, auth.Options, engine.Options) error {
+	for _, command := range []string{"mkdir -p /etc/docker", "systemctl stop docker", "systemctl start docker"} {
+		if _, err := drivers.RunSSHCommandFromDriver(p.driver, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sshDetector struct{}
+
+func (sshDetector) DetectProvisioner(d drivers.Driver) (provision.Provisioner, error) {
+	return &sshProvisioner{Provisioner: provision.NewFakeProvisioner(d), driver: d}, nil
+}
+
+func TestSSHConnectionReuse(t *testing.T) {
+	for _, reuse := range []bool{false, true} {
+		api, h, driver, cleanup := newTestCreate(t, KeepOnCreateFailure)
+		api.clientDriverFactory = rpc.NewRPCClientDriverFactory()
+
+		server, err := testserver.NewServer(testserver.EchoHandler)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		provision.SetDetector(sshDetector{})
+		ssh.SetDefaultClient(ssh.Native)
+		api.SSHConnectionReuse = reuse
+
+		h.Driver = &sshServerDriver{removableDriver: driver, server: server}
+		unregister := registerTestDriver(h)
+		assert.NoError(t, api.Save(h))
+
+		loaded, err := api.Load(h.Name)
+		assert.NoError(t, err)
+		assert.NoError(t, loaded.ConfigureAuth())
+
+		// Without reuse, each of the 3 commands dials at least once.
+		if reuse {
+			assert.Equal(t, 1, server.Connections())
+		} else {
+			assert.True(t, server.Connections() >= 3, "%d connections", server.Connections())
+		}
+
+		assert.NoError(t, api.Close())
+		assert.Nil(t, api.sshPool)
+
+		ssh.SetDefaultClient(ssh.External)
+		unregister()
+		server.Close()
+		cleanup()
+	}
+}
+
+func TestResumeCreate(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, MarkOnCreateFailure)
+	defer cleanup()
+	defer registerTestDriver(h)()
+
+	assert.Error(t, api.Create(h))
+
+	check.DefaultConnChecker = &failingConnChecker{}
+
+	var phases []event.Phase
+	unsubscribe := api.Subscribe(event.SubscriberFunc(func(e event.Event) {
+		if e.Kind == event.Started {
+			phases = append(phases, e.Phase)
+		}
+	}))
+	defer unsubscribe()
+
+	assert.NoError(t, api.ResumeCreate(h.Name))
+
+	assert.Equal(t, 1, driver.created)
+	assert.Equal(t, []event.Phase{event.Create, event.ConnCheck}, phases)
+
+	stored, err := api.Store.Load(h.Name)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.CreateStatus)
+
+	assert.Equal(t, mcnerror.ErrHostNotResumable{Name: h.Name}, api.ResumeCreate(h.Name))
+}
+
+func TestResumeCreateSkipsCreatedMachine(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+	defer registerTestDriver(h)()
+
+	check.DefaultConnChecker = &failingConnChecker{}
+
+	// The process died while the driver was creating the machine.
+	h.CreateStatus = &host.CreateStatus{State: host.CreateInProgress}
+	assert.NoError(t, api.Save(h))
+
+	var phases []event.Phase
+	unsubscribe := api.Subscribe(event.SubscriberFunc(func(e event.Event) {
+		if e.Kind == event.Started {
+			phases = append(phases, e.Phase)
+		}
+	}))
+	defer unsubscribe()
+
+	assert.NoError(t, api.ResumeCreate(h.Name))
+
+	assert.Equal(t, 0, driver.created)
+	assert.Equal(t, []event.Phase{event.Create, event.WaitRunning, event.DetectOS, event.Provision, event.ConnCheck}, phases)
+}
+
+func TestResumeCreateAfterLastPhase(t *testing.T) {
+	api, h, driver, cleanup := newTestCreate(t, KeepOnCreateFailure)
+	defer cleanup()
+	defer registerTestDriver(h)()
+
+	// Saving the host as created failed after its last phase.
+	h.CreateStatus = &host.CreateStatus{
+		State:          host.CreateInProgress,
+		CompletedPhase: event.ConnCheck,
+	}
+	assert.NoError(t, api.Save(h))
+
+	var phases []event.Phase
+	unsubscribe := api.Subscribe(event.SubscriberFunc(func(e event.Event) {
+		if e.Kind == event.Started {
+			phases = append(phases, e.Phase)
+		}
+	}))
+	defer unsubscribe()
+
+	assert.NoError(t, api.ResumeCreate(h.Name))
+
+	assert.Equal(t, 0, driver.created)
+	assert.Equal(t, []event.Phase{event.Create}, phases)
+
+	stored, err := api.Store.Load(h.Name)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.CreateStatus)
+}
+
+func TestNewHostInProcessDriver(t *testing.T) {
+	drivers.Register("inproc", func() drivers.Driver {
+		return &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}}
+	})
+	defer drivers.Unregister("inproc")
+
+	api := &Client{certsDir: "certs", storePath: "store"}
+
+	h, err := api.NewHost("inproc", []byte(`{"MockName":"test","MockState":1}`))
+	assert.NoError(t, err)
+
+	d, ok := h.Driver.(*fakedriver.Driver)
+	assert.True(t, ok)
+	assert.Equal(t, "test", h.Name)
+	assert.Equal(t, state.Running, d.MockState)
+
+	_, err = api.NewHost("inproc", []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestLoadInProcessDriver(t *testing.T) {
+	drivers.Register("inproc", func() drivers.Driver {
+		return &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}}
+	})
+	defer drivers.Unregister("inproc")
+
+	api := &Client{
+		Store: &persisttest.FakeStore{
+			Hosts: []*host.Host{{
+				Name:       "test",
+				DriverName: "inproc",
+				RawDriver:  []byte(`{"MockName":"test","MockState":1,"MockIP":"1.2.3.4"}`),
+			}},
+		},
+	}
+
+	h, err := api.Load("test")
+	assert.NoError(t, err)
+
+	ip, err := h.Driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip)
+}
+
+func TestRepinDriver(t *testing.T) {
+	drivers.Register("inproc", func() drivers.Driver {
+		return &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}}
+	})
+	defer drivers.Unregister("inproc")
+
+	store := &persisttest.FakeStore{
+		Hosts: []*host.Host{{
+			Name:           "test",
+			DriverName:     "inproc",
+			DriverChecksum: "5e689e2b01672bf33996e75d5e372ff60c536ce1599a1458e867cd8f4bef5160",
+			RawDriver:      []byte(`{"MockName":"test","MockIP":"1.2.3.4"}`),
+		}},
+	}
+	api := &Client{Store: store}
+
+	assert.NoError(t, api.RepinDriver("test"))
+
+	h, err := store.Load("test")
+	assert.NoError(t, err)
+	assert.Empty(t, h.DriverChecksum)
+	assert.Equal(t, "1.2.3.4", h.Driver.(*fakedriver.Driver).MockIP)
+}
+
+func TestNewHostFromProfile(t *testing.T) {
+	drivers.Register("none", func() drivers.Driver {
+		return none.NewDriver("", "")
+	})
+	defer drivers.Unregister("none")
+
+	storePath, err := ioutil.TempDir("", "libmachine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	api := &Client{certsDir: "certs", storePath: storePath}
+	assert.NoError(t, api.Profiles().Save(&profile.Profile{
+		Name:          "remote",
+		DriverName:    "none",
+		DriverFlags:   map[string]interface{}{"url": "tcp://1.2.3.4:2376"},
+		EngineOptions: map[string]interface{}{"LogLevel": "debug"},
+	}))
+	assert.NoError(t, api.Profiles().Save(&profile.Profile{
+		Name:         "remote-swarm",
+		Parent:       "remote",
+		SwarmOptions: map[string]interface{}{"Master": true, "Discovery": "token://abc"},
+	}))
+
+	h, err := api.NewHostFromProfile("remote-swarm", "test", map[string]interface{}{"url": "tcp://5.6.7.8:2376"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", h.Name)
+	assert.Equal(t, "none", h.DriverName)
+	assert.Equal(t, "debug", h.HostOptions.EngineOptions.LogLevel)
+	assert.Equal(t, "overlay2", h.HostOptions.EngineOptions.StorageDriver)
+	assert.Equal(t, map[string]interface{}{"url": "tcp://5.6.7.8:2376"}, h.HostOptions.DriverFlags)
+
+	assert.True(t, h.HostOptions.SwarmOptions.Master)
+	assert.Equal(t, "token://abc", h.HostOptions.SwarmOptions.Discovery)
+	assert.Equal(t, "tcp://5.6.7.8:2376", h.Driver.(*none.Driver).URL)
+
+	_, err = api.NewHostFromProfile("remote", "test", map[string]interface{}{"unknown": "value"})
+	assert.EqualError(t, err, "Profile remote sets flag unknown, which driver none does not declare")
+
+	_, err = api.NewHostFromProfile("missing", "test", nil)
+	assert.Equal(t, profile.ErrProfileDoesNotExist{Name: "missing"}, err)
+}
+
+func TestExportProfile(t *testing.T) {
+	drivers.Register("none", func() drivers.Driver {
+		return none.NewDriver("", "")
+	})
+	defer drivers.Unregister("none")
+
+	storePath, err := ioutil.TempDir("", "libmachine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	api := &Client{
+		storePath: storePath,
+		Store: &persisttest.FakeStore{
+			Hosts: []*host.Host{{
+				Name:       "test",
+				DriverName: "none",
+				RawDriver:  []byte(`{"MachineName":"test","URL":"tcp://1.2.3.4:2376"}`),
+				HostOptions: &host.Options{
+					EngineOptions: &engine.Options{LogLevel: "debug"},
+					SwarmOptions:  &swarm.Options{},
+					AuthOptions:   &auth.Options{},
+					DriverFlags:   map[string]interface{}{"url": "tcp://1.2.3.4:2376"},
+				},
+			}},
+		},
+	}
+
+	_, err = api.ExportProfile("test", "exported", false)
+	assert.NoError(t, err)
+
+	_, err = api.ExportProfile("test", "exported", false)
+	assert.Equal(t, profile.ErrProfileExists{Name: "exported"}, err)
+
+	_, err = api.ExportProfile("test", "exported", true)
+	assert.NoError(t, err)
+
+	h, err := api.NewHostFromProfile("exported", "copy", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", h.HostOptions.EngineOptions.LogLevel)
+	assert.Equal(t, "tcp://1.2.3.4:2376", h.Driver.(*none.Driver).URL)
+}
@@ -3,12 +3,14 @@ package log
 import (
 	"io"
 	"regexp"
+	"sync"
 )
 
 const redactedText = "<REDACTED>"
 
 var (
-	logger = NewFmtMachineLogger()
+	logger     = NewFmtMachineLogger()
+	loggerLock sync.RWMutex
 
 	// (?s) enables '.' to match '\n' -- see https://golang.org/pkg/regexp/syntax/
 	certRegex = regexp.MustCompile("(?s)-----BEGIN CERTIFICATE-----.*-----END CERTIFICATE-----")
@@ -26,49 +28,68 @@ func stripSecrets(original []string) []string {
 }
 
 func Debug(args ...interface{}) {
-	logger.Debug(args...)
+	currentLogger().Debug(args...)
 }
 
 func Debugf(fmtString string, args ...interface{}) {
-	logger.Debugf(fmtString, args...)
+	currentLogger().Debugf(fmtString, args...)
 }
 
 func Error(args ...interface{}) {
-	logger.Error(args...)
+	currentLogger().Error(args...)
 }
 
 func Errorf(fmtString string, args ...interface{}) {
-	logger.Errorf(fmtString, args...)
+	currentLogger().Errorf(fmtString, args...)
 }
 
 func Info(args ...interface{}) {
-	logger.Info(args...)
+	currentLogger().Info(args...)
 }
 
 func Infof(fmtString string, args ...interface{}) {
-	logger.Infof(fmtString, args...)
+	currentLogger().Infof(fmtString, args...)
 }
 
 func Warn(args ...interface{}) {
-	logger.Warn(args...)
+	currentLogger().Warn(args...)
 }
 
 func Warnf(fmtString string, args ...interface{}) {
-	logger.Warnf(fmtString, args...)
+	currentLogger().Warnf(fmtString, args...)
+}
+
+// SetLogger replaces the logger used by the package functions, e.g. by one
+// which keeps the records of each machine apart.
+func SetLogger(l MachineLogger) {
+	loggerLock.Lock()
+	defer loggerLock.Unlock()
+	logger = l
+}
+
+// GetLogger returns the logger used by the package functions.
+func GetLogger() MachineLogger {
+	return currentLogger()
+}
+
+func currentLogger() MachineLogger {
+	loggerLock.RLock()
+	defer loggerLock.RUnlock()
+	return logger
 }
 
 func SetDebug(debug bool) {
-	logger.SetDebug(debug)
+	currentLogger().SetDebug(debug)
 }
 
 func SetOutWriter(out io.Writer) {
-	logger.SetOutWriter(out)
+	currentLogger().SetOutWriter(out)
 }
 
 func SetErrWriter(err io.Writer) {
-	logger.SetErrWriter(err)
+	currentLogger().SetErrWriter(err)
 }
 
 func History() []string {
-	return stripSecrets(logger.History())
+	return stripSecrets(currentLogger().History())
 }
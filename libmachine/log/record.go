@@ -0,0 +1,159 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Level is the severity of a Record.
+type Level string
+
+const (
+	DebugLevel Level = "debug"
+	InfoLevel  Level = "info"
+	WarnLevel  Level = "warn"
+	ErrorLevel Level = "error"
+)
+
+// Record is a structured log entry, e.g. one sent by a driver plugin.
+type Record struct {
+	Level   Level
+	Time    time.Time
+	Machine string
+	Message string
+	Fields  map[string]string
+}
+
+// String formats r the way plugin output is shown to the user: prefixed with
+// the machine name, and marked as such for debug records.
+func (r Record) String() string {
+	var b strings.Builder
+
+	if r.Machine != "" {
+		fmt.Fprintf(&b, "(%s) ", r.Machine)
+	}
+	if r.Level == DebugLevel {
+		b.WriteString("DBG | ")
+	}
+	b.WriteString(r.Message)
+
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, r.Fields[k])
+	}
+
+	return b.String()
+}
+
+// RecordLogger is implemented by MachineLoggers which handle structured
+// records themselves, e.g. to filter them or store them per machine.
+type RecordLogger interface {
+	LogRecord(r Record)
+}
+
+// LogRecord emits r through the current logger, as a structured record if it
+// is a RecordLogger, or formatted at r's level otherwise.
+func LogRecord(r Record) {
+	LogRecordTo(currentLogger(), r)
+}
+
+// LogRecordTo emits r through logger, as a structured record if it is a
+// RecordLogger, or formatted at r's level otherwise.
+func LogRecordTo(logger MachineLogger, r Record) {
+	if rl, ok := logger.(RecordLogger); ok {
+		rl.LogRecord(r)
+		return
+	}
+
+	switch r.Level {
+	case DebugLevel:
+		logger.Debug(r.String())
+	case WarnLevel:
+		logger.Warn(r.String())
+	case ErrorLevel:
+		logger.Error(r.String())
+	default:
+		logger.Info(r.String())
+	}
+}
+
+// Entry logs records carrying the same machine and fields.
+type Entry struct {
+	machine string
+	fields  map[string]string
+}
+
+// WithFields returns an Entry adding fields to the records it logs.
+func WithFields(fields map[string]string) Entry {
+	return Entry{}.WithFields(fields)
+}
+
+// WithMachine returns an Entry logging records about the named machine.
+func WithMachine(name string) Entry {
+	return Entry{machine: name}
+}
+
+// WithFields returns a copy of e adding fields to the records it logs.
+func (e Entry) WithFields(fields map[string]string) Entry {
+	merged := make(map[string]string, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	e.fields = merged
+	return e
+}
+
+func (e Entry) log(level Level, message string) {
+	LogRecord(Record{
+		Level:   level,
+		Time:    time.Now(),
+		Machine: e.machine,
+		Message: message,
+		Fields:  e.fields,
+	})
+}
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func (e Entry) Debug(args ...interface{}) {
+	e.log(DebugLevel, sprintln(args...))
+}
+
+func (e Entry) Debugf(fmtString string, args ...interface{}) {
+	e.log(DebugLevel, fmt.Sprintf(fmtString, args...))
+}
+
+func (e Entry) Error(args ...interface{}) {
+	e.log(ErrorLevel, sprintln(args...))
+}
+
+func (e Entry) Errorf(fmtString string, args ...interface{}) {
+	e.log(ErrorLevel, fmt.Sprintf(fmtString, args...))
+}
+
+func (e Entry) Info(args ...interface{}) {
+	e.log(InfoLevel, sprintln(args...))
+}
+
+func (e Entry) Infof(fmtString string, args ...interface{}) {
+	e.log(InfoLevel, fmt.Sprintf(fmtString, args...))
+}
+
+func (e Entry) Warn(args ...interface{}) {
+	e.log(WarnLevel, sprintln(args...))
+}
+
+func (e Entry) Warnf(fmtString string, args ...interface{}) {
+	e.log(WarnLevel, fmt.Sprintf(fmtString, args...))
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	MachineLogger
+	records []Record
+}
+
+func (l *recordingLogger) LogRecord(r Record) {
+	l.records = append(l.records, r)
+}
+
+func TestRecordString(t *testing.T) {
+	assert.Equal(t, "(test) DBG | booting", Record{Level: DebugLevel, Machine: "test", Message: "booting"}.String())
+	assert.Equal(t, "(test) booted attempt=2 vm=test", Record{
+		Level:   InfoLevel,
+		Machine: "test",
+		Message: "booted",
+		Fields:  map[string]string{"vm": "test", "attempt": "2"},
+	}.String())
+}
+
+func TestLogRecordFormatsForPlainLoggers(t *testing.T) {
+	testLogger := NewFmtMachineLogger()
+	defer SetLogger(GetLogger())
+	SetLogger(testLogger)
+
+	result := captureOutput(testLogger, func() { LogRecord(Record{Level: InfoLevel, Machine: "test", Message: "info"}) })
+
+	assert.Equal(t, "(test) info", result)
+}
+
+func TestLogRecordToRecordLogger(t *testing.T) {
+	testLogger := &recordingLogger{MachineLogger: NewFmtMachineLogger()}
+	defer SetLogger(GetLogger())
+	SetLogger(testLogger)
+
+	record := Record{Level: WarnLevel, Machine: "test", Message: "warn"}
+	LogRecord(record)
+
+	assert.Equal(t, []Record{record}, testLogger.records)
+}
+
+func TestEntryLogsFieldsAndMachine(t *testing.T) {
+	testLogger := &recordingLogger{MachineLogger: NewFmtMachineLogger()}
+	defer SetLogger(GetLogger())
+	SetLogger(testLogger)
+
+	WithMachine("test").WithFields(map[string]string{"vm": "test"}).Infof("booted after %d tries", 2)
+
+	assert.Len(t, testLogger.records, 1)
+	assert.Equal(t, InfoLevel, testLogger.records[0].Level)
+	assert.Equal(t, "test", testLogger.records[0].Machine)
+	assert.Equal(t, "booted after 2 tries", testLogger.records[0].Message)
+	assert.Equal(t, map[string]string{"vm": "test"}, testLogger.records[0].Fields)
+}
@@ -44,3 +44,33 @@ type ErrHostAlreadyInState struct {
 func (e ErrHostAlreadyInState) Error() string {
 	return fmt.Sprintf("Machine %q is already %s.", e.Name, strings.ToLower(e.State.String()))
 }
+
+type ErrHostLocked struct {
+	Name string
+	PID  int
+}
+
+func (e ErrHostLocked) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("Docker machine %q is locked by another process", e.Name)
+	}
+	return fmt.Sprintf("Docker machine %q is locked by another process (pid %d)", e.Name, e.PID)
+}
+
+type ErrHostNotResumable struct {
+	Name string
+}
+
+func (e ErrHostNotResumable) Error() string {
+	return fmt.Sprintf("Docker machine %q has no unfinished creation to resume", e.Name)
+}
+
+type ErrHostConflict struct {
+	Name     string
+	Revision int
+	Stored   int
+}
+
+func (e ErrHostConflict) Error() string {
+	return fmt.Sprintf("Docker machine %q was modified by another process since it was loaded (revision %d, stored revision %d)", e.Name, e.Revision, e.Stored)
+}
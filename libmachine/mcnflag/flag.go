@@ -1,6 +1,11 @@
 package mcnflag
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
 
 type Flag interface {
 	fmt.Stringer
@@ -69,3 +74,76 @@ func (f BoolFlag) String() string {
 func (f BoolFlag) Default() interface{} {
 	return nil
 }
+
+// Validator is implemented by flags which check the values they are given.
+type Validator interface {
+	// Validate returns an error if value is not a valid value of the
+	// flag. An empty value means the flag is not set, and is valid.
+	Validate(value interface{}) error
+}
+
+// Kind tells which DriverOptions accessor reads the value of a flag.
+type Kind string
+
+const (
+	KindString      Kind = "string"
+	KindStringSlice Kind = "stringSlice"
+	KindInt         Kind = "int"
+	KindBool        Kind = "bool"
+)
+
+// KindOf returns the kind of the values of f, or an empty kind if f is of an
+// unknown type. The typed flags, e.g. DurationFlag, have string values which
+// drivers parse once validated.
+func KindOf(f Flag) Kind {
+	switch reflect.Indirect(reflect.ValueOf(f)).Interface().(type) {
+	case StringFlag, DurationFlag, FloatFlag, EnumFlag, SizeFlag, CIDRFlag, URLFlag, PathFlag:
+		return KindString
+	case StringSliceFlag:
+		return KindStringSlice
+	case IntFlag:
+		return KindInt
+	case BoolFlag:
+		return KindBool
+	}
+
+	return ""
+}
+
+// FromJSONValue restores the type of a value of a flag of the given kind read
+// from JSON, where numbers are float64 or json.Number and lists are
+// []interface{}. When the kind is empty, i.e. unknown, whole numbers are
+// taken for ints and lists of strings for string slices.
+func FromJSONValue(kind Kind, value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if kind == "" || kind == KindInt {
+			if i, err := v.Int64(); err == nil {
+				return int(i)
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case float64:
+		if (kind == "" || kind == KindInt) && v == math.Trunc(v) {
+			return int(v)
+		}
+	case []interface{}:
+		if kind != "" && kind != KindStringSlice {
+			return value
+		}
+
+		strings := []string{}
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return value
+			}
+			strings = append(strings, s)
+		}
+		return strings
+	}
+
+	return value
+}
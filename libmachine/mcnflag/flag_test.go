@@ -0,0 +1,33 @@
+package mcnflag
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromJSONValue(t *testing.T) {
+	cases := []struct {
+		kind     Kind
+		value    interface{}
+		expected interface{}
+	}{
+		{KindInt, float64(3), 3},
+		{KindInt, 3.5, 3.5},
+		{KindString, float64(3), float64(3)},
+		{"", float64(3), 3},
+		{"", json.Number("3"), 3},
+		{"", json.Number("3.0"), 3.0},
+		{KindString, json.Number("3"), 3.0},
+		{KindStringSlice, []interface{}{"a", "b"}, []string{"a", "b"}},
+		{"", []interface{}{"a", "b"}, []string{"a", "b"}},
+		{"", []interface{}{"a", 1}, []interface{}{"a", 1}},
+		{KindString, []interface{}{"a"}, []interface{}{"a"}},
+		{KindBool, true, true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, FromJSONValue(c.kind, c.value), "%s %#v", c.kind, c.value)
+	}
+}
@@ -0,0 +1,275 @@
+package mcnflag
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The typed flags below pass their values to drivers as strings, read with
+// DriverOptions.String, the way they are typed on the command line. Their
+// values are validated before the driver is configured, so drivers can parse
+// them without checking again.
+
+// DurationFlag takes a duration, e.g. 90s or 5m, parsed by time.ParseDuration.
+type DurationFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  time.Duration
+}
+
+func (f DurationFlag) String() string {
+	return f.Name
+}
+
+func (f DurationFlag) Default() interface{} {
+	if f.Value == 0 {
+		return ""
+	}
+	return f.Value.String()
+}
+
+func (f DurationFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	_, err = time.ParseDuration(s)
+	return err
+}
+
+// FloatFlag takes a floating point number, parsed by strconv.ParseFloat.
+type FloatFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  float64
+}
+
+func (f FloatFlag) String() string {
+	return f.Name
+}
+
+func (f FloatFlag) Default() interface{} {
+	return strconv.FormatFloat(f.Value, 'g', -1, 64)
+}
+
+func (f FloatFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return fmt.Errorf("%q is not a number", s)
+	}
+	return nil
+}
+
+// EnumFlag takes one of a fixed set of values.
+type EnumFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  string
+	Values []string
+}
+
+func (f EnumFlag) String() string {
+	return f.Name
+}
+
+func (f EnumFlag) Default() interface{} {
+	return f.Value
+}
+
+func (f EnumFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	for _, allowed := range f.Values {
+		if s == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", s, strings.Join(f.Values, ", "))
+}
+
+// SizeFlag takes a size in MB, with an optional MB, GB or TB unit, e.g. 2048,
+// 2048MB or 2GB. Its values are parsed by ParseSize.
+type SizeFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	// Value is the default size in MB.
+	Value int
+}
+
+func (f SizeFlag) String() string {
+	return f.Name
+}
+
+func (f SizeFlag) Default() interface{} {
+	if f.Value == 0 {
+		return ""
+	}
+	return strconv.Itoa(f.Value) + "MB"
+}
+
+func (f SizeFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	_, err = ParseSize(s)
+	return err
+}
+
+var sizeUnits = []struct {
+	suffix string
+	mb     int
+}{
+	{"TB", 1024 * 1024},
+	{"GB", 1024},
+	{"MB", 1},
+	{"T", 1024 * 1024},
+	{"G", 1024},
+	{"M", 1},
+}
+
+// ParseSize returns the number of MB of a size, e.g. 2048 for 2GB. Sizes
+// without a unit are in MB, and units are case insensitive.
+func ParseSize(size string) (int, error) {
+	number, mb := strings.TrimSpace(size), 1
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(number), unit.suffix) {
+			number, mb = strings.TrimSpace(number[:len(number)-len(unit.suffix)]), unit.mb
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size, e.g. 2048MB or 2GB", size)
+	}
+	return n * mb, nil
+}
+
+// CIDRFlag takes an IP network in CIDR notation, e.g. 192.168.99.1/24.
+type CIDRFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  string
+}
+
+func (f CIDRFlag) String() string {
+	return f.Name
+}
+
+func (f CIDRFlag) Default() interface{} {
+	return f.Value
+}
+
+func (f CIDRFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	if _, _, err := net.ParseCIDR(s); err != nil {
+		return fmt.Errorf("%q is not a CIDR, e.g. 192.168.99.1/24", s)
+	}
+	return nil
+}
+
+// URLFlag takes an absolute URL. If Schemes is not empty, the scheme of the
+// URL must be one of them.
+type URLFlag struct {
+	Name    string
+	Usage   string
+	EnvVar  string
+	Value   string
+	Schemes []string
+}
+
+func (f URLFlag) String() string {
+	return f.Name
+}
+
+func (f URLFlag) Default() interface{} {
+	return f.Value
+}
+
+func (f URLFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" {
+		return err
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("%q is not an absolute URL", s)
+	}
+
+	if len(f.Schemes) == 0 {
+		return nil
+	}
+	for _, scheme := range f.Schemes {
+		if u.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q does not use one of the schemes %s", s, strings.Join(f.Schemes, ", "))
+}
+
+// PathFlag takes a file path. If MustExist is set, something must exist at
+// that path.
+type PathFlag struct {
+	Name      string
+	Usage     string
+	EnvVar    string
+	Value     string
+	MustExist bool
+}
+
+func (f PathFlag) String() string {
+	return f.Name
+}
+
+func (f PathFlag) Default() interface{} {
+	return f.Value
+}
+
+func (f PathFlag) Validate(value interface{}) error {
+	s, err := stringValue(value)
+	if err != nil || s == "" || !f.MustExist {
+		return err
+	}
+
+	if _, err := os.Stat(s); err != nil {
+		return fmt.Errorf("%q does not exist", s)
+	}
+	return nil
+}
+
+func stringValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	}
+
+	return "", errors.New("expected a string")
+}
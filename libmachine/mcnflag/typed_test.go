@@ -0,0 +1,90 @@
+package mcnflag
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	file, err := ioutil.TempFile("", "mcnflag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	cases := []struct {
+		flag  Validator
+		value interface{}
+		valid bool
+	}{
+		{DurationFlag{}, "90s", true},
+		{DurationFlag{}, "", true},
+		{DurationFlag{}, "90", false},
+		{DurationFlag{}, 90, false},
+		{FloatFlag{}, "0.5", true},
+		{FloatFlag{}, "half", false},
+		{EnumFlag{Values: []string{"on", "off"}}, "off", true},
+		{EnumFlag{Values: []string{"on", "off"}}, "maybe", false},
+		{SizeFlag{}, "2GB", true},
+		{SizeFlag{}, "2 gb", true},
+		{SizeFlag{}, "2048", true},
+		{SizeFlag{}, "2PB", false},
+		{SizeFlag{}, "-1MB", false},
+		{CIDRFlag{}, "192.168.99.1/24", true},
+		{CIDRFlag{}, "192.168.99.1", false},
+		{URLFlag{}, "https://example.com/install.sh", true},
+		{URLFlag{}, "example.com", false},
+		{URLFlag{Schemes: []string{"tcp"}}, "tcp://1.2.3.4:2376", true},
+		{URLFlag{Schemes: []string{"tcp"}}, "http://1.2.3.4:2376", false},
+		{PathFlag{}, "/does/not/exist", true},
+		{PathFlag{MustExist: true}, file.Name(), true},
+		{PathFlag{MustExist: true}, "/does/not/exist", false},
+	}
+
+	for _, c := range cases {
+		err := c.flag.Validate(c.value)
+		assert.Equal(t, c.valid, err == nil, "%T %v: %v", c.flag, c.value, err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		size     string
+		expected int
+	}{
+		{"20000", 20000},
+		{"512MB", 512},
+		{"512m", 512},
+		{"2GB", 2048},
+		{"1T", 1024 * 1024},
+	}
+
+	for _, c := range cases {
+		mb, err := ParseSize(c.size)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, mb, c.size)
+	}
+
+	_, err := ParseSize("GB")
+	assert.EqualError(t, err, `"GB" is not a size, e.g. 2048MB or 2GB`)
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, "1m30s", DurationFlag{Value: 90 * time.Second}.Default())
+	assert.Equal(t, "", DurationFlag{}.Default())
+	assert.Equal(t, "0.5", FloatFlag{Value: 0.5}.Default())
+	assert.Equal(t, "20000MB", SizeFlag{Value: 20000}.Default())
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Equal(t, KindString, KindOf(StringFlag{}))
+	assert.Equal(t, KindString, KindOf(&SizeFlag{}))
+	assert.Equal(t, KindStringSlice, KindOf(&StringSliceFlag{}))
+	assert.Equal(t, KindInt, KindOf(IntFlag{}))
+	assert.Equal(t, KindBool, KindOf(BoolFlag{}))
+}
@@ -0,0 +1,162 @@
+package mcnlock
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+var (
+	// DefaultTimeout is how long Lock waits for another process to release
+	// a lock when the Lock has no Timeout of its own.
+	DefaultTimeout = 30 * time.Second
+
+	retryInterval = 100 * time.Millisecond
+
+	errWouldBlock = errors.New("lock is held by another process")
+
+	heldLocks     = map[string]*heldLock{}
+	heldLocksLock = &sync.Mutex{}
+)
+
+type heldLock struct {
+	file  *os.File
+	owner *Lock
+	count int
+}
+
+// Lock is an advisory lock on a machine, shared between processes through a
+// lock file. It is reentrant: locking a Lock which is already held succeeds
+// immediately, and the lock file is only released by the matching last
+// Unlock. Other Locks on the same path wait for it to be released, whether
+// they are used in another process or in another goroutine of this one.
+type Lock struct {
+	// Name of the machine, reported when the lock cannot be acquired.
+	Name    string
+	Path    string
+	Timeout time.Duration
+}
+
+func New(name, path string, timeout time.Duration) *Lock {
+	return &Lock{
+		Name:    name,
+		Path:    path,
+		Timeout: timeout,
+	}
+}
+
+// Lock acquires the lock, waiting up to Timeout for another process or Lock
+// to release it. It returns mcnerror.ErrHostLocked if the wait times out.
+func (l *Lock) Lock() error {
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := l.tryLock()
+		if err == nil {
+			return nil
+		}
+		if err != errWouldBlock {
+			return fmt.Errorf("Error locking %s: %s", l.Path, err)
+		}
+		if time.Now().After(deadline) {
+			return mcnerror.ErrHostLocked{
+				Name: l.Name,
+				PID:  l.holderPID(),
+			}
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+func (l *Lock) tryLock() error {
+	heldLocksLock.Lock()
+	defer heldLocksLock.Unlock()
+
+	if held, ok := heldLocks[l.Path]; ok {
+		if held.owner != l {
+			return errWouldBlock
+		}
+		held.count++
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	// Record who holds the lock, for the benefit of those waiting on it.
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	heldLocks[l.Path] = &heldLock{
+		file:  file,
+		owner: l,
+		count: 1,
+	}
+
+	return nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	heldLocksLock.Lock()
+	defer heldLocksLock.Unlock()
+
+	held, ok := heldLocks[l.Path]
+	if !ok || held.owner != l {
+		return fmt.Errorf("Lock %s is not held", l.Path)
+	}
+
+	held.count--
+	if held.count > 0 {
+		return nil
+	}
+
+	delete(heldLocks, l.Path)
+
+	if err := unlockFile(held.file); err != nil {
+		held.file.Close()
+		return err
+	}
+
+	return held.file.Close()
+}
+
+// holderPID returns the PID of the process holding the lock, or 0 if it is
+// unknown.
+func (l *Lock) holderPID() int {
+	data, err := ioutil.ReadFile(l.Path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
@@ -0,0 +1,75 @@
+package mcnlock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockIsReentrant(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	lock := New("test", filepath.Join(tmpDir, "locks", "test.lock"), time.Second)
+
+	assert.NoError(t, lock.Lock())
+	assert.NoError(t, lock.Lock())
+	assert.NoError(t, lock.Unlock())
+	assert.NoError(t, lock.Unlock())
+	assert.Error(t, lock.Unlock())
+}
+
+func TestLockHeldByAnotherProcess(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "test.lock")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("4242"), 0600))
+
+	// A separate open file description is seen as another lock holder,
+	// exactly like another process would be.
+	other, err := os.OpenFile(path, os.O_RDWR, 0600)
+	assert.NoError(t, err)
+	defer other.Close()
+	assert.NoError(t, lockFile(other))
+
+	lock := New("test", path, 200*time.Millisecond)
+
+	assert.Equal(t, mcnerror.ErrHostLocked{Name: "test", PID: 4242}, lock.Lock())
+
+	assert.NoError(t, unlockFile(other))
+	assert.NoError(t, lock.Lock())
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestLockHeldByAnotherLockInProcess(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "test.lock")
+	lock := New("test", path, time.Second)
+	other := New("test", path, 200*time.Millisecond)
+
+	assert.NoError(t, lock.Lock())
+	assert.Equal(t, mcnerror.ErrHostLocked{Name: "test", PID: os.Getpid()}, other.Lock())
+	assert.Error(t, other.Unlock())
+
+	waiting := New("test", path, time.Second)
+	acquired := make(chan error)
+	go func() {
+		acquired <- waiting.Lock()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, lock.Unlock())
+	assert.NoError(t, <-acquired)
+	assert.NoError(t, waiting.Unlock())
+}
@@ -0,0 +1,25 @@
+package mcnlock
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// The locked byte lies far past the PID written in the file, so that other
+// processes are still able to read it.
+const lockOffset = 0x7fffffff
+
+func lockFile(file *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errWouldBlock
+	}
+	return err
+}
+
+func unlockFile(file *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, ol)
+}
@@ -1,6 +1,7 @@
 package mcnutils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -102,6 +103,24 @@ func WaitFor(f func() bool) error {
 	return WaitForSpecific(f, 60, 3*time.Second)
 }
 
+// WaitForContext is like WaitFor, but stops waiting as soon as ctx is done.
+func WaitForContext(ctx context.Context, f func() bool) error {
+	for i := 0; i < 60; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+		}
+	}
+	return fmt.Errorf("Maximum number of retries (%d) exceeded", 60)
+}
+
 // TruncateID returns a shorten id
 // Following two functions are from github.com/docker/docker/utils module. It
 // was way overkill to include the whole module, so we just have these bits
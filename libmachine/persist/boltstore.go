@@ -0,0 +1,204 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnlock"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltDBFile = "machines.db"
+)
+
+var (
+	boltMachinesBucket = []byte("machines")
+	// The backups of the configs of each machine, taken before migrations,
+	// are kept in a bucket named after the machine in boltBackupsBucket.
+	boltBackupsBucket = []byte("backups")
+
+	// Time to wait for another process to release the database.
+	boltOpenTimeout = 30 * time.Second
+)
+
+// BoltStore keeps the configuration of every machine in a single bbolt
+// database instead of one config.json per machine directory. Each operation
+// runs in its own transaction, and the database is only held open for the
+// duration of that operation, so several processes can safely share a store.
+//
+// Machine directories are still created under GetMachinesDir(), since drivers
+// keep their certificates, SSH keys and disks there.
+type BoltStore struct {
+	Path string
+	// LockTimeout is how long lifecycle operations on a loaded host wait for
+	// another process to release it. Zero means mcnlock.DefaultTimeout.
+	LockTimeout time.Duration
+}
+
+func NewBoltStore(path string) *BoltStore {
+	return &BoltStore{
+		Path: path,
+	}
+}
+
+func (s BoltStore) GetMachinesDir() string {
+	return filepath.Join(s.Path, "machines")
+}
+
+// DBPath returns the path of the bbolt database file.
+func (s BoltStore) DBPath() string {
+	return filepath.Join(s.Path, boltDBFile)
+}
+
+// Import copies every host of src into the database, overwriting those
+// already there. It is how the machines/*/config.json files of a Filestore
+// are migrated to a BoltStore, see CopyAllHosts.
+func (s BoltStore) Import(src Store) error {
+	return CopyAllHosts(s, src)
+}
+
+// Export copies every host of the database into dst, e.g. a Filestore on the
+// same path to migrate back to config.json files.
+func (s BoltStore) Export(dst Store) error {
+	return CopyAllHosts(dst, s)
+}
+
+// HostLock returns the lock guarding the lifecycle operations of the named
+// machine against other processes.
+func (s BoltStore) HostLock(name string) *mcnlock.Lock {
+	return mcnlock.New(name, filepath.Join(s.GetMachinesDir(), ".locks", name+".lock"), s.LockTimeout)
+}
+
+func (s BoltStore) withDB(readOnly bool, fn func(tx *bolt.Tx) error) error {
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(s.DBPath(), 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return fmt.Errorf("Error opening store database %s: %s", s.DBPath(), err)
+	}
+	defer db.Close()
+
+	if readOnly {
+		return db.View(func(tx *bolt.Tx) error {
+			if tx.Bucket(boltMachinesBucket) == nil {
+				return nil
+			}
+			return fn(tx)
+		})
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltMachinesBucket, boltBackupsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+func (s BoltStore) Save(host *host.Host) error {
+	return s.save(host, false)
+}
+
+// SaveIfUnchanged persists the host only if the stored copy still has the
+// revision the host was loaded with, and returns mcnerror.ErrHostConflict
+// otherwise. The check and the write happen in the same transaction.
+func (s BoltStore) SaveIfUnchanged(host *host.Host) error {
+	return s.save(host, true)
+}
+
+func (s BoltStore) save(host *host.Host, checkUnchanged bool) error {
+	// Ensure that the machine directory exists for the driver files.
+	if err := os.MkdirAll(filepath.Join(s.GetMachinesDir(), host.Name), 0700); err != nil {
+		return err
+	}
+
+	revision := host.Revision
+
+	err := s.withDB(false, func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMachinesBucket)
+
+		next, err := nextRevision(host, bucket.Get([]byte(host.Name)), checkUnchanged)
+		if err != nil {
+			return err
+		}
+
+		host.Revision = next
+
+		data, err := json.MarshalIndent(host, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(host.Name), data)
+	})
+	if err != nil {
+		host.Revision = revision
+	}
+
+	return err
+}
+
+func (s BoltStore) Remove(name string) error {
+	if err := s.withDB(false, func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltBackupsBucket).DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		return tx.Bucket(boltMachinesBucket).Delete([]byte(name))
+	}); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(s.GetMachinesDir(), name))
+}
+
+func (s BoltStore) List() ([]string, error) {
+	hostNames := []string{}
+
+	err := s.withDB(true, func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMachinesBucket).ForEach(func(k, _ []byte) error {
+			hostNames = append(hostNames, string(k))
+			return nil
+		})
+	})
+
+	return hostNames, err
+}
+
+func (s BoltStore) Exists(name string) (bool, error) {
+	exists := false
+
+	err := s.withDB(true, func(tx *bolt.Tx) error {
+		exists = tx.Bucket(boltMachinesBucket).Get([]byte(name)) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+// Load returns the named host. A config at an older config version is
+// backed up and migrated, see Backups and Rollback.
+func (s BoltStore) Load(name string) (*host.Host, error) {
+	h, report, err := s.migrateConfig(name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Needed() {
+		if h, _, err = s.migrateConfig(name, false); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	}
+
+	h.Locker = s.HostLock(name)
+
+	return h, nil
+}
@@ -0,0 +1,229 @@
+package persist
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+func getTestBoltStore(t *testing.T) *BoltStore {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBoltStore(tmpDir)
+}
+
+func TestBoltStoreSaveLoad(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	expectedURL := "unix:///foo/baz"
+	flags := hosttest.GetTestDriverFlags()
+	flags.Data["url"] = expectedURL
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Driver.SetConfigFromFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.GetMachinesDir(), h.Name)); err != nil {
+		t.Fatalf("Machine directory was not created: %s", err)
+	}
+
+	h, err = store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawDataDriver, ok := h.Driver.(*host.RawDataDriver)
+	if !ok {
+		t.Fatal("Expected driver loaded from store to be of type *host.RawDataDriver and it was not")
+	}
+
+	realDriver := none.NewDriver(h.Name, store.Path)
+	if err := json.Unmarshal(rawDataDriver.Data, &realDriver); err != nil {
+		t.Fatalf("Error unmarshaling rawDataDriver data into concrete 'none' driver: %s", err)
+	}
+
+	h.Driver = realDriver
+
+	actualURL, err := h.URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actualURL != expectedURL {
+		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
+	}
+}
+
+func TestBoltStoreLoadMissing(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	_, err := store.Load("missing")
+	if _, ok := err.(mcnerror.ErrHostDoesNotExist); !ok {
+		t.Fatalf("Expected ErrHostDoesNotExist, got %v", err)
+	}
+}
+
+func TestBoltStoreListExistsRemove(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	hosts, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 0 {
+		t.Fatalf("List returned %d items on an empty store", len(hosts))
+	}
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != h.Name {
+		t.Fatalf("List returned %v, expected [%s]", hosts, h.Name)
+	}
+
+	exists, err := store.Exists(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Host should exist after saving")
+	}
+
+	if err := store.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err = store.Exists(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Host should not exist after removing")
+	}
+
+	if _, err := os.Stat(filepath.Join(store.GetMachinesDir(), h.Name)); !os.IsNotExist(err) {
+		t.Fatal("Machine directory should be removed with the host")
+	}
+}
+
+func TestBoltStoreImportExportRoundTrip(t *testing.T) {
+	defer cleanup()
+
+	fileStore := getTestStore()
+	boltStore := NewBoltStore(fileStore.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fileStore.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := boltStore.Import(fileStore); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fileStore.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := boltStore.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.DriverName != h.DriverName {
+		t.Fatalf("Imported driver name is %q, expected %q", imported.DriverName, h.DriverName)
+	}
+
+	if err := boltStore.Export(fileStore); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := fileStore.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving into the file store bumps the revision.
+	exported.Revision = imported.Revision
+
+	expectedData, _ := json.Marshal(imported)
+	actualData, _ := json.Marshal(exported)
+	if string(expectedData) != string(actualData) {
+		t.Fatalf("Exported host differs from the imported one:\n%s\n%s", expectedData, actualData)
+	}
+}
+
+func TestBoltStoreSaveIfUnchanged(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SaveIfUnchanged(first); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedErr := mcnerror.ErrHostConflict{Name: h.Name, Revision: 1, Stored: 2}
+	if err := store.SaveIfUnchanged(second); err != expectedErr {
+		t.Fatalf("Expected %s, got %v", expectedErr, err)
+	}
+}
+
+func TestBoltStoreSaveFollowsStoredRevision(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	testSaveFollowsStoredRevision(t, store)
+}
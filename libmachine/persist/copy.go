@@ -0,0 +1,47 @@
+package persist
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// CopyHosts loads each named host from src and saves it into dst. This is
+// how a tree of machines/*/config.json files is imported into another Store,
+// e.g. a BoltStore, or exported back from it.
+//
+// Hosts which cannot be copied are skipped and reported together in the
+// returned error.
+func CopyHosts(dst, src Store, hostNames []string) error {
+	errs := []error{}
+
+	for _, hostName := range hostNames {
+		h, err := src.Load(hostName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error loading host %q: %s", hostName, err))
+			continue
+		}
+
+		if err := dst.Save(h); err != nil {
+			errs = append(errs, fmt.Errorf("Error saving host %q: %s", hostName, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return mcnutils.MultiError{Errs: errs}
+	}
+
+	return nil
+}
+
+// CopyAllHosts copies every host of src into dst. It migrates a whole store
+// from one backend to another, e.g. from a Filestore to a BoltStore, see
+// BoltStore.Import and BoltStore.Export.
+func CopyAllHosts(dst, src Store) error {
+	hostNames, err := src.List()
+	if err != nil {
+		return err
+	}
+
+	return CopyHosts(dst, src, hostNames)
+}
@@ -7,15 +7,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnlock"
 )
 
 type Filestore struct {
 	Path             string
 	CaCertPath       string
 	CaPrivateKeyPath string
+	// LockTimeout is how long to wait for another process to release a
+	// machine. Zero means mcnlock.DefaultTimeout.
+	LockTimeout time.Duration
 }
 
 func NewFilestore(path, caCertPath, caPrivateKeyPath string) *Filestore {
@@ -30,6 +35,13 @@ func (s Filestore) GetMachinesDir() string {
 	return filepath.Join(s.Path, "machines")
 }
 
+// HostLock returns the lock guarding the named machine against concurrent
+// use from other processes. Lock files live in a hidden directory, so that
+// they outlive the removal of the machine directory.
+func (s Filestore) HostLock(name string) *mcnlock.Lock {
+	return mcnlock.New(name, filepath.Join(s.GetMachinesDir(), ".locks", name+".lock"), s.LockTimeout)
+}
+
 func (s Filestore) saveToFile(data []byte, file string) error {
 	if _, err := os.Stat(file); os.IsNotExist(err) {
 		return ioutil.WriteFile(file, data, 0600)
@@ -57,13 +69,75 @@ func (s Filestore) saveToFile(data []byte, file string) error {
 	return err
 }
 
+// lockOf returns the lock attached to host if it guards the same machine, so
+// that a host can be saved while one of its lifecycle operations holds the
+// lock, and a new lock on the machine otherwise.
+func (s Filestore) lockOf(host *host.Host) *mcnlock.Lock {
+	lock := s.HostLock(host.Name)
+	if held, ok := host.Locker.(*mcnlock.Lock); ok && held.Path == lock.Path {
+		return held
+	}
+	return lock
+}
+
+// Save persists the host while holding its lock. The lock is also attached to
+// the host, if it has none yet, to guard its lifecycle operations.
 func (s Filestore) Save(host *host.Host) error {
-	data, err := json.MarshalIndent(host, "", "    ")
+	lock := s.lockOf(host)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if host.Locker == nil {
+		host.Locker = lock
+	}
+
+	return s.save(host, false)
+}
+
+// SaveIfUnchanged persists the host only if the stored copy still has the
+// revision the host was loaded with, and returns mcnerror.ErrHostConflict
+// otherwise.
+func (s Filestore) SaveIfUnchanged(host *host.Host) error {
+	lock := s.lockOf(host)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return s.save(host, true)
+}
+
+// save writes the config of host with the revision following the stored one.
+// The caller must hold the lock of the host.
+func (s Filestore) save(host *host.Host, checkUnchanged bool) error {
+	stored, err := ioutil.ReadFile(filepath.Join(s.GetMachinesDir(), host.Name, "config.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	next, err := nextRevision(host, stored, checkUnchanged)
 	if err != nil {
 		return err
 	}
 
-	hostPath := filepath.Join(s.GetMachinesDir(), host.Name)
+	revision := host.Revision
+	host.Revision = next
+
+	data, err := json.MarshalIndent(host, "", "    ")
+	if err == nil {
+		err = s.writeConfig(host.Name, data)
+	}
+	if err != nil {
+		host.Revision = revision
+	}
+
+	return err
+}
+
+func (s Filestore) writeConfig(name string, data []byte) error {
+	hostPath := filepath.Join(s.GetMachinesDir(), name)
 
 	// Ensure that the directory we want to save to exists.
 	if err := os.MkdirAll(hostPath, 0700); err != nil {
@@ -74,6 +148,12 @@ func (s Filestore) Save(host *host.Host) error {
 }
 
 func (s Filestore) Remove(name string) error {
+	lock := s.HostLock(name)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
 	hostPath := filepath.Join(s.GetMachinesDir(), name)
 	return os.RemoveAll(hostPath)
 }
@@ -117,7 +197,7 @@ func (s Filestore) loadConfig(h *host.Host) error {
 	// struct in the migration.
 	name := h.Name
 
-	migratedHost, migrationPerformed, err := host.MigrateHost(h, data)
+	migratedHost, report, err := host.MigrateHostWithReport(h, data)
 	if err != nil {
 		return fmt.Errorf("Error getting migrated host: %s", err)
 	}
@@ -127,12 +207,13 @@ func (s Filestore) loadConfig(h *host.Host) error {
 	h.Name = name
 
 	// If we end up performing a migration, we should save afterwards so we don't have to do it again on subsequent invocations.
-	if migrationPerformed {
-		if err := s.saveToFile(data, filepath.Join(s.GetMachinesDir(), h.Name, "config.json.bak")); err != nil {
+	// The original config is backed up first, so that it can be restored with Rollback.
+	if report.Needed() {
+		if err := s.backupConfig(h.Name, report.FromVersion, data); err != nil {
 			return fmt.Errorf("Error attempting to save backup after migration: %s", err)
 		}
 
-		if err := s.Save(h); err != nil {
+		if err := s.save(h, false); err != nil {
 			return fmt.Errorf("Error saving config after migration was performed: %s", err)
 		}
 	}
@@ -141,6 +222,12 @@ func (s Filestore) loadConfig(h *host.Host) error {
 }
 
 func (s Filestore) Load(name string) (*host.Host, error) {
+	lock := s.HostLock(name)
+	if err := lock.Lock(); err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
 	hostPath := filepath.Join(s.GetMachinesDir(), name)
 
 	if _, err := os.Stat(hostPath); os.IsNotExist(err) {
@@ -157,5 +244,23 @@ func (s Filestore) Load(name string) (*host.Host, error) {
 		return nil, err
 	}
 
+	host.Locker = lock
+
 	return host, nil
 }
+
+// Validate checks the stored config of a machine against the JSON Schema of
+// its config version, see host.ValidateConfig.
+func (s Filestore) Validate(name string) error {
+	data, err := ioutil.ReadFile(filepath.Join(s.GetMachinesDir(), name, "config.json"))
+	if os.IsNotExist(err) {
+		return mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return host.ValidateConfig(data)
+}
@@ -6,13 +6,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/drivers/none"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnlock"
+	"github.com/docker/machine/libmachine/mcnutils"
 )
 
 func cleanup() {
@@ -250,3 +255,240 @@ func TestStoreLoad(t *testing.T) {
 		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
 	}
 }
+
+func TestStoreLoadAttachesLock(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := h.Locker.(*mcnlock.Lock); !ok {
+		t.Fatalf("Expected host loaded from store to have a lock, got %v", h.Locker)
+	}
+
+	hosts, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 {
+		t.Fatalf("Lock files should not be listed as hosts, got %v", hosts)
+	}
+}
+
+func TestStoreSaveWhileHostLocked(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	store.LockTimeout = 200 * time.Millisecond
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Locker.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Locker.Unlock()
+
+	// The host is saved with the lock it holds...
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...but another copy of it has to wait for the lock.
+	other, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(other); err == nil {
+		t.Fatal("Expected saving another copy of a locked host to fail")
+	} else if _, ok := err.(mcnerror.ErrHostLocked); !ok {
+		t.Fatalf("Expected ErrHostLocked, got %v", err)
+	}
+}
+
+func TestStoreSaveIfUnchanged(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SaveIfUnchanged(h); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SaveIfUnchanged(first); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedErr := mcnerror.ErrHostConflict{Name: h.Name, Revision: 1, Stored: 2}
+	if err := store.SaveIfUnchanged(second); err != expectedErr {
+		t.Fatalf("Expected %s, got %v", expectedErr, err)
+	}
+
+	if second.Revision != 1 {
+		t.Fatalf("Revision should be left untouched on conflict, got %d", second.Revision)
+	}
+}
+
+// testSaveFollowsStoredRevision saves two copies of a host loaded at the same
+// revision, the second of which must not reuse the revision of the first.
+func testSaveFollowsStoredRevision(t *testing.T, store ConditionalStore) {
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Revision != 3 {
+		t.Fatalf("Expected revision 3 after two saves, got %d", second.Revision)
+	}
+
+	// The first copy was overwritten, so it cannot be saved conditionally.
+	expectedErr := mcnerror.ErrHostConflict{Name: h.Name, Revision: 2, Stored: 3}
+	if err := store.SaveIfUnchanged(first); err != expectedErr {
+		t.Fatalf("Expected %s, got %v", expectedErr, err)
+	}
+}
+
+func TestStoreSaveFollowsStoredRevision(t *testing.T) {
+	defer cleanup()
+
+	testSaveFollowsStoredRevision(t, getTestStore())
+}
+
+func TestListByLabels(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+
+	for name, labels := range map[string]map[string]string{
+		"ci-prod":  {"team": "ci", "env": "prod"},
+		"ci-test":  {"team": "ci", "env": "test"},
+		"web-prod": {"team": "web", "env": "prod"},
+	} {
+		h, err := hosttest.GetDefaultTestHost()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Name = name
+		h.HostOptions.Labels = labels
+
+		if err := store.Save(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hostNames, err := ListByLabels(store, "team=ci,env!=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hostNames, []string{"ci-test"}) {
+		t.Fatalf("Expected [ci-test], got %v", hostNames)
+	}
+
+	if _, err := ListByLabels(store, "=ci"); err == nil {
+		t.Fatal("Expected an error for an invalid selector")
+	}
+}
+
+func TestListByLabelsSkipsBrokenHosts(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Name = "ci"
+	h.HostOptions.Labels = map[string]string{"team": "ci"}
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	brokenDir := filepath.Join(store.GetMachinesDir(), "broken")
+	if err := os.MkdirAll(brokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(brokenDir, "config.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, hostsInError, err := LoadByLabels(store, []string{"broken", "ci"}, "team=ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "ci" {
+		t.Fatalf("Expected host ci to be selected, got %v", hosts)
+	}
+	if _, ok := hostsInError["broken"]; !ok || len(hostsInError) != 1 {
+		t.Fatalf("Expected only broken to fail loading, got %v", hostsInError)
+	}
+
+	hostNames, err := ListByLabels(store, "team=ci")
+	if !reflect.DeepEqual(hostNames, []string{"ci"}) {
+		t.Fatalf("Expected [ci], got %v", hostNames)
+	}
+	if multiErr, ok := err.(mcnutils.MultiError); !ok || len(multiErr.Errs) != 1 {
+		t.Fatalf("Expected the broken host to be reported, got %v", err)
+	}
+}
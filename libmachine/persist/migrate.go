@@ -0,0 +1,300 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MigrationResult tells how the config of a machine is, or would be,
+// migrated. Err is set if the config could not be read or migrated.
+type MigrationResult struct {
+	Name   string
+	Report *host.MigrationReport
+	Err    error
+}
+
+// Migrator is implemented by stores which can migrate the configs of all
+// their machines at once.
+type Migrator interface {
+	Store
+
+	// MigrateAll migrates the configs of all the machines which are not
+	// at the current config version, and returns the result for each of
+	// them, along with those whose config cannot be read. With dryRun,
+	// nothing is changed.
+	MigrateAll(dryRun bool) ([]MigrationResult, error)
+
+	// Backups returns the names of the backups of the config of a machine
+	// taken before migrations, oldest first.
+	Backups(name string) ([]string, error)
+
+	// Rollback restores the config of a machine from one of its backups.
+	Rollback(name, backup string) error
+}
+
+func (s Filestore) MigrateAll(dryRun bool) ([]MigrationResult, error) {
+	names, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MigrationResult{}
+	for _, name := range names {
+		result := s.migrate(name, dryRun)
+		if result.Err != nil || result.Report.Needed() {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+func (s Filestore) migrate(name string, dryRun bool) MigrationResult {
+	result := MigrationResult{Name: name}
+
+	lock := s.HostLock(name)
+	if result.Err = lock.Lock(); result.Err != nil {
+		return result
+	}
+	defer lock.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(s.GetMachinesDir(), name, "config.json"))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if _, result.Report, result.Err = host.MigrateHostWithReport(&host.Host{Name: name}, data); result.Err != nil {
+		return result
+	}
+
+	if !dryRun && result.Report.Needed() {
+		result.Err = s.loadConfig(&host.Host{Name: name})
+	}
+
+	return result
+}
+
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// backupName returns the name of the backup of a config taken now, before it
+// is migrated from configVersion: config.json.v<configVersion>-<UTC time>.bak.
+func backupName(configVersion int) string {
+	return fmt.Sprintf("config.json.v%d-%s.bak", configVersion, time.Now().UTC().Format(backupTimeFormat))
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, "config.json.") && strings.HasSuffix(name, ".bak")
+}
+
+// sortBackups sorts backup names by the time they were taken, oldest first.
+func sortBackups(names []string) {
+	timeOf := func(name string) string {
+		name = strings.TrimSuffix(name, ".bak")
+		return name[strings.LastIndex(name, "-")+1:]
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return timeOf(names[i]) < timeOf(names[j])
+	})
+}
+
+// backupConfig saves the config of a machine before it is migrated from
+// configVersion, see backupName. An existing backup is never overwritten.
+func (s Filestore) backupConfig(name string, configVersion int, data []byte) error {
+	f, err := os.OpenFile(filepath.Join(s.GetMachinesDir(), name, backupName(configVersion)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Backups returns the names of the backups of the config of a machine taken
+// before migrations, oldest first.
+func (s Filestore) Backups(name string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(s.GetMachinesDir(), name))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && isBackupName(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	sortBackups(names)
+
+	return names, nil
+}
+
+// Rollback restores the config of a machine from one of its backups, see
+// Backups. The restored config is migrated again the next time it is loaded
+// by a version of Docker Machine using a newer config version.
+func (s Filestore) Rollback(name, backup string) error {
+	backups, err := s.Backups(name)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, b := range backups {
+		found = found || b == backup
+	}
+	if !found {
+		return fmt.Errorf("Machine %q has no config backup %q", name, backup)
+	}
+
+	lock := s.HostLock(name)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(s.GetMachinesDir(), name, backup))
+	if err != nil {
+		return err
+	}
+
+	return s.saveToFile(data, filepath.Join(s.GetMachinesDir(), name, "config.json"))
+}
+
+func (s BoltStore) MigrateAll(dryRun bool) ([]MigrationResult, error) {
+	names, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MigrationResult{}
+	for _, name := range names {
+		result := MigrationResult{Name: name}
+		_, result.Report, result.Err = s.migrateConfig(name, dryRun)
+		if result.Err != nil || result.Report.Needed() {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// migrateConfig returns the named host, migrated to the current config
+// version. Unless dryRun, a migrated config is backed up and saved in the
+// transaction it is read in.
+func (s BoltStore) migrateConfig(name string, dryRun bool) (*host.Host, *host.MigrationReport, error) {
+	var (
+		h      *host.Host
+		report *host.MigrationReport
+	)
+
+	err := s.withDB(dryRun, func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltMachinesBucket).Get([]byte(name))
+		if v == nil {
+			return nil
+		}
+		// The value is only valid until the transaction changes the database.
+		data := append([]byte{}, v...)
+
+		var err error
+		if h, report, err = host.MigrateHostWithReport(&host.Host{Name: name}, data); err != nil {
+			return fmt.Errorf("Error getting migrated host: %s", err)
+		}
+		h.Name = name
+
+		if dryRun || !report.Needed() {
+			return nil
+		}
+
+		backups, err := tx.Bucket(boltBackupsBucket).CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		backup := []byte(backupName(report.FromVersion))
+		if backups.Get(backup) != nil {
+			return fmt.Errorf("Config backup %s of machine %s already exists", backup, name)
+		}
+		if err := backups.Put(backup, data); err != nil {
+			return fmt.Errorf("Error attempting to save backup after migration: %s", err)
+		}
+
+		if h.Revision, err = nextRevision(h, data, false); err != nil {
+			return err
+		}
+
+		migrated, err := json.MarshalIndent(h, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(boltMachinesBucket).Put([]byte(name), migrated)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if h == nil {
+		return nil, nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+
+	return h, report, nil
+}
+
+// Backups returns the names of the backups of the config of a machine taken
+// before migrations, oldest first.
+func (s BoltStore) Backups(name string) ([]string, error) {
+	names := []string{}
+
+	err := s.withDB(true, func(tx *bolt.Tx) error {
+		backups := tx.Bucket(boltBackupsBucket)
+		if backups != nil {
+			backups = backups.Bucket([]byte(name))
+		}
+		if backups == nil {
+			return nil
+		}
+
+		return backups.ForEach(func(k, _ []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortBackups(names)
+
+	return names, nil
+}
+
+// Rollback restores the config of a machine from one of its backups, see
+// Backups. The restored config is migrated again the next time it is loaded
+// by a version of Docker Machine using a newer config version.
+func (s BoltStore) Rollback(name, backup string) error {
+	return s.withDB(false, func(tx *bolt.Tx) error {
+		var data []byte
+		if backups := tx.Bucket(boltBackupsBucket).Bucket([]byte(name)); backups != nil {
+			data = backups.Get([]byte(backup))
+		}
+		if data == nil {
+			return fmt.Errorf("Machine %q has no config backup %q", name, backup)
+		}
+
+		return tx.Bucket(boltMachinesBucket).Put([]byte(name), append([]byte{}, data...))
+	})
+}
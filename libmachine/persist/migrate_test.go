@@ -0,0 +1,200 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+const v1Config = `{
+	"ConfigVersion": 1,
+	"Driver": {"MachineName": "old", "IPAddress": "1.2.3.4"},
+	"DriverName": "none",
+	"HostOptions": {
+		"EngineOptions": {},
+		"SwarmOptions": {},
+		"AuthOptions": {"StorePath": "/store/machines/old"}
+	}
+}`
+
+func writeConfig(t *testing.T, store Filestore, name, config string) {
+	dir := filepath.Join(store.GetMachinesDir(), name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readConfig(t *testing.T, store Filestore, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(store.GetMachinesDir(), name, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMigrateAll(t *testing.T) {
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, store.Save(h))
+
+	writeConfig(t, store, "old", v1Config)
+	writeConfig(t, store, "broken", "{")
+
+	results, err := store.MigrateAll(true)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	assert.Equal(t, "broken", results[0].Name)
+	assert.Error(t, results[0].Err)
+
+	assert.Equal(t, "old", results[1].Name)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, 1, results[1].Report.FromVersion)
+	assert.Equal(t, version.ConfigVersion, results[1].Report.ToVersion)
+	assert.Len(t, results[1].Report.Steps, version.ConfigVersion-1)
+	assert.Equal(t, v1Config, readConfig(t, store, "old"))
+
+	backups, err := store.Backups("old")
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+
+	results, err = store.MigrateAll(false)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[1].Err)
+
+	migrated, err := store.Load("old")
+	assert.NoError(t, err)
+	assert.Equal(t, version.ConfigVersion, migrated.ConfigVersion)
+
+	backups, err = store.Backups("old")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+	assert.Regexp(t, regexp.MustCompile(`^config\.json\.v1-\d{8}T\d{6}\.\d{9}Z\.bak$`), backups[0])
+
+	results, err = store.MigrateAll(true)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	assert.NoError(t, store.Rollback("old", backups[0]))
+	assert.Equal(t, v1Config, readConfig(t, store, "old"))
+
+	assert.EqualError(t, store.Rollback("old", "../../config.json"), `Machine "old" has no config backup "../../config.json"`)
+
+	_, err = store.Load("old")
+	assert.NoError(t, err)
+
+	again, err := store.Backups("old")
+	assert.NoError(t, err)
+	assert.Len(t, again, 2)
+	assert.Equal(t, backups[0], again[0])
+}
+
+func writeBoltConfig(t *testing.T, store *BoltStore, name, config string) {
+	if err := store.withDB(false, func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMachinesBucket).Put([]byte(name), []byte(config))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltStoreMigrateAll(t *testing.T) {
+	store := getTestBoltStore(t)
+	defer os.RemoveAll(store.Path)
+
+	var migrator Migrator = store
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, store.Save(h))
+
+	writeBoltConfig(t, store, "old", v1Config)
+	writeBoltConfig(t, store, "broken", "{")
+
+	results, err := migrator.MigrateAll(true)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "broken", results[0].Name)
+	assert.Error(t, results[0].Err)
+	assert.Equal(t, "old", results[1].Name)
+	assert.Equal(t, 1, results[1].Report.FromVersion)
+
+	backups, err := migrator.Backups("old")
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+
+	results, err = migrator.MigrateAll(false)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[1].Err)
+
+	migrated, err := store.Load("old")
+	assert.NoError(t, err)
+	assert.Equal(t, version.ConfigVersion, migrated.ConfigVersion)
+
+	backups, err = migrator.Backups("old")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	assert.NoError(t, migrator.Rollback("old", backups[0]))
+	assert.EqualError(t, migrator.Rollback("old", "missing"), `Machine "old" has no config backup "missing"`)
+
+	// Loading the rolled back config migrates it again.
+	migrated, err = store.Load("old")
+	assert.NoError(t, err)
+	assert.Equal(t, version.ConfigVersion, migrated.ConfigVersion)
+
+	again, err := migrator.Backups("old")
+	assert.NoError(t, err)
+	assert.Len(t, again, 2)
+	assert.Equal(t, backups[0], again[0])
+
+	assert.NoError(t, store.Remove("old"))
+	backups, err = migrator.Backups("old")
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestStorePreservesUnknownFields(t *testing.T) {
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	writeConfig(t, store, "future", `{
+		"ConfigVersion": 3,
+		"Driver": {"MachineName": "future", "StorePath": "/store"},
+		"DriverName": "none",
+		"HostOptions": {"EngineOptions": {}, "SwarmOptions": {}, "AuthOptions": {}, "Quota": 10},
+		"Name": "future",
+		"Tags": ["a"]
+	}`)
+	assert.NoError(t, store.Validate("future"))
+
+	h, err := store.Load("future")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(h))
+
+	saved := readConfig(t, store, "future")
+	assert.Contains(t, saved, `"Tags": [`)
+	assert.Contains(t, saved, `"Quota": 10`)
+	assert.NoError(t, store.Validate("future"))
+
+	writeConfig(t, store, "invalid", `{"ConfigVersion": 3, "Driver": {}, "DriverName": "none", "HostOptions": {}, "Name": 1}`)
+	assert.EqualError(t, store.Validate("invalid"), "Invalid config version 3: Name: expected string, got integer")
+}
@@ -56,6 +56,12 @@ func (fs *FakeStore) Remove(name string) error {
 
 func (fs *FakeStore) Save(host *host.Host) error {
 	if fs.SaveErr == nil {
+		for i, h := range fs.Hosts {
+			if h.Name == host.Name {
+				fs.Hosts[i] = host
+				return nil
+			}
+		}
 		fs.Hosts = append(fs.Hosts, host)
 		return nil
 	}
@@ -1,7 +1,12 @@
 package persist
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnutils"
 )
 
 type Store interface {
@@ -21,6 +26,54 @@ type Store interface {
 	Save(host *host.Host) error
 }
 
+// ConditionalStore is implemented by stores which can refuse to overwrite a
+// host that was changed by someone else since it was loaded.
+type ConditionalStore interface {
+	Store
+
+	// SaveIfUnchanged persists a machine in the store, unless the stored
+	// machine has a different revision than the one being saved, in which
+	// case it returns mcnerror.ErrHostConflict.
+	SaveIfUnchanged(host *host.Host) error
+}
+
+// nextRevision returns the revision to save host with, which follows the one
+// of its stored configuration, data, or is 1 if the host was never saved.
+// Deriving it from the stored revision, rather than the one host was loaded
+// with, keeps two hosts saved from the same revision from getting the same
+// one. With checkUnchanged, it returns mcnerror.ErrHostConflict if the stored
+// revision is not the one of host.
+func nextRevision(h *host.Host, data []byte, checkUnchanged bool) (int, error) {
+	var stored struct {
+		Revision int
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &stored); err != nil {
+			if checkUnchanged {
+				return 0, err
+			}
+			// An unreadable config is overwritten by a plain save.
+			stored.Revision = 0
+		}
+	}
+
+	if checkUnchanged && stored.Revision != h.Revision {
+		return 0, mcnerror.ErrHostConflict{
+			Name:     h.Name,
+			Revision: h.Revision,
+			Stored:   stored.Revision,
+		}
+	}
+
+	// Hosts copied from another store keep counting from their revision.
+	if h.Revision > stored.Revision {
+		return h.Revision + 1, nil
+	}
+
+	return stored.Revision + 1, nil
+}
+
 func LoadHosts(s Store, hostNames []string) ([]*host.Host, map[string]error) {
 	loadedHosts := []*host.Host{}
 	errors := map[string]error{}
@@ -45,3 +98,74 @@ func LoadAllHosts(s Store) ([]*host.Host, map[string]error, error) {
 	loadedHosts, hostInError := LoadHosts(s, hostNames)
 	return loadedHosts, hostInError, nil
 }
+
+// LoadByLabels loads the named hosts and returns those whose labels match
+// selector, e.g. "team=ci,env!=prod". See host.ParseLabelSelector for its
+// syntax. Like with LoadHosts, hosts which cannot be loaded are skipped and
+// returned with their error, so that a broken config does not prevent
+// selecting the other hosts.
+func LoadByLabels(s Store, hostNames []string, selector string) ([]*host.Host, map[string]error, error) {
+	labelSelector, err := host.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loadedHosts, hostsInError := LoadHosts(s, hostNames)
+
+	matching := []*host.Host{}
+	for _, h := range loadedHosts {
+		if h.MatchesLabels(labelSelector) {
+			matching = append(matching, h)
+		}
+	}
+
+	return matching, hostsInError, nil
+}
+
+// ListByLabels returns the names of the hosts whose labels match selector,
+// see LoadByLabels. Hosts which cannot be loaded are left out, and reported
+// together in a mcnutils.MultiError returned along with the other names.
+func ListByLabels(s Store, selector string) ([]string, error) {
+	labelSelector, err := host.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	hostNames, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	if labelSelector.Empty() {
+		return hostNames, nil
+	}
+
+	hosts, hostsInError, err := LoadByLabels(s, hostNames, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []string{}
+	for _, h := range hosts {
+		matching = append(matching, h.Name)
+	}
+
+	return matching, hostsError(hostNames, hostsInError)
+}
+
+// hostsError aggregates the errors of hostsInError, in the order of
+// hostNames, or returns nil if there is none.
+func hostsError(hostNames []string, hostsInError map[string]error) error {
+	errs := []error{}
+	for _, hostName := range hostNames {
+		if err, ok := hostsInError[hostName]; ok {
+			errs = append(errs, fmt.Errorf("Error loading host %q: %s", hostName, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return mcnutils.MultiError{Errs: errs}
+	}
+
+	return nil
+}
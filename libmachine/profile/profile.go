@@ -0,0 +1,203 @@
+// Package profile stores named machine configurations which new machines
+// are created from: a driver, the values of its create flags, and overrides
+// of the engine, swarm and auth options.
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+type Profile struct {
+	// Name is the name of the file the profile is stored in.
+	Name string `json:"-"`
+
+	// Parent names the profile this one inherits from. The settings of
+	// the parent apply unless this profile overrides them.
+	Parent string `json:",omitempty"`
+
+	DriverName string `json:",omitempty"`
+
+	// DriverFlags are the values of the driver create flags, by flag name.
+	// They are only inherited by profiles using the same driver.
+	DriverFlags map[string]interface{} `json:",omitempty"`
+
+	// EngineOptions, SwarmOptions and AuthOptions override the fields they
+	// set in the options of new hosts, e.g. {"StorageDriver": "overlay2"}.
+	EngineOptions map[string]interface{} `json:",omitempty"`
+	SwarmOptions  map[string]interface{} `json:",omitempty"`
+	AuthOptions   map[string]interface{} `json:",omitempty"`
+}
+
+// exportedAuthOptions are the auth options which are not specific to a
+// machine, and are exported by FromHost.
+var exportedAuthOptions = []string{
+	"CertDir",
+	"CaCertPath",
+	"CaPrivateKeyPath",
+	"CaCertRemotePath",
+	"ClientKeyPath",
+	"ClientCertPath",
+	"ServerCertRemotePath",
+	"ServerKeyRemotePath",
+	"ServerCertSANs",
+}
+
+// FromHost returns a profile creating machines configured like h. The
+// driver flags are only known for hosts created from a profile, see
+// host.Options.DriverFlags.
+func FromHost(name string, h *host.Host) (*Profile, error) {
+	p := &Profile{
+		Name:        name,
+		DriverName:  h.DriverName,
+		DriverFlags: h.HostOptions.DriverFlags,
+	}
+
+	var err error
+	if p.EngineOptions, err = toMap(h.HostOptions.EngineOptions); err != nil {
+		return nil, err
+	}
+
+	if p.SwarmOptions, err = toMap(h.HostOptions.SwarmOptions); err != nil {
+		return nil, err
+	}
+	// The swarm address defaults to the address of the machine.
+	delete(p.SwarmOptions, "Address")
+
+	authOptions, err := toMap(h.HostOptions.AuthOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range exportedAuthOptions {
+		if value, ok := authOptions[key]; ok {
+			if p.AuthOptions == nil {
+				p.AuthOptions = map[string]interface{}{}
+			}
+			p.AuthOptions[key] = value
+		}
+	}
+
+	return p, nil
+}
+
+// inherit returns p with the settings of parent it does not override.
+func (p *Profile) inherit(parent *Profile) *Profile {
+	merged := &Profile{
+		Name:          p.Name,
+		DriverName:    p.DriverName,
+		DriverFlags:   p.DriverFlags,
+		EngineOptions: mergeMaps(parent.EngineOptions, p.EngineOptions),
+		SwarmOptions:  mergeMaps(parent.SwarmOptions, p.SwarmOptions),
+		AuthOptions:   mergeMaps(parent.AuthOptions, p.AuthOptions),
+	}
+
+	if merged.DriverName == "" {
+		merged.DriverName = parent.DriverName
+	}
+	if merged.DriverName == parent.DriverName {
+		merged.DriverFlags = mergeMaps(parent.DriverFlags, p.DriverFlags)
+	}
+
+	return merged
+}
+
+// ApplyOptions sets the fields of opts overridden by the profile.
+func (p *Profile) ApplyOptions(opts *host.Options) error {
+	for _, o := range []struct {
+		name      string
+		overrides map[string]interface{}
+		options   interface{}
+	}{
+		{"EngineOptions", p.EngineOptions, opts.EngineOptions},
+		{"SwarmOptions", p.SwarmOptions, opts.SwarmOptions},
+		{"AuthOptions", p.AuthOptions, opts.AuthOptions},
+	} {
+		if len(o.overrides) == 0 {
+			continue
+		}
+
+		data, err := json.Marshal(o.overrides)
+		if err != nil {
+			return err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(o.options); err != nil {
+			return fmt.Errorf("Profile %s has invalid %s: %s", p.Name, o.name, err)
+		}
+	}
+
+	return nil
+}
+
+// FlagValues returns the values of flags set by the profile, overridden by
+// overrides, and the flags' defaults for the others, the way the CLI passes
+// them to drivers. It fails if a flag which is not declared is set.
+func (p *Profile) FlagValues(flags []mcnflag.Flag, overrides map[string]interface{}) (map[string]interface{}, error) {
+	set := mergeMaps(p.DriverFlags, overrides)
+
+	values := map[string]interface{}{}
+	for _, f := range flags {
+		value, ok := set[f.String()]
+		if !ok {
+			value = defaultValue(f)
+		}
+		values[f.String()] = mcnflag.FromJSONValue(mcnflag.KindOf(f), value)
+		delete(set, f.String())
+	}
+
+	for name := range set {
+		return nil, fmt.Errorf("Profile %s sets flag %s, which driver %s does not declare", p.Name, name, p.DriverName)
+	}
+
+	return values, nil
+}
+
+func defaultValue(f mcnflag.Flag) interface{} {
+	switch mcnflag.KindOf(f) {
+	case mcnflag.KindStringSlice:
+		if value, _ := f.Default().([]string); value != nil {
+			return value
+		}
+		return []string{}
+	case mcnflag.KindBool:
+		return false
+	}
+
+	return f.Default()
+}
+
+func mergeMaps(parent, child map[string]interface{}) map[string]interface{} {
+	if len(parent) == 0 && len(child) == 0 {
+		return nil
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
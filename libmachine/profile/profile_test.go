@@ -0,0 +1,199 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewStore(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestStore(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	p := &Profile{Name: "small", DriverName: "virtualbox", DriverFlags: map[string]interface{}{"virtualbox-memory": 512}}
+	assert.NoError(t, store.Save(p))
+	assert.NoError(t, store.Save(&Profile{Name: "base"}))
+
+	names, err = store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base", "small"}, names)
+
+	loaded, err := store.Load("small")
+	assert.NoError(t, err)
+	assert.Equal(t, "small", loaded.Name)
+	assert.Equal(t, "virtualbox", loaded.DriverName)
+	assert.Equal(t, map[string]interface{}{"virtualbox-memory": float64(512)}, loaded.DriverFlags)
+
+	assert.NoError(t, store.Remove("small"))
+	_, err = store.Load("small")
+	assert.Equal(t, ErrProfileDoesNotExist{"small"}, err)
+	assert.Equal(t, ErrProfileDoesNotExist{"small"}, store.Remove("small"))
+
+	assert.Equal(t, ErrInvalidProfileName{"../escape"}, store.Save(&Profile{Name: "../escape"}))
+
+	assert.NoError(t, store.Create(&Profile{Name: "created", DriverName: "none"}))
+	assert.Equal(t, ErrProfileExists{"created"}, store.Create(&Profile{Name: "created"}))
+	loaded, err = store.Load("created")
+	assert.NoError(t, err)
+	assert.Equal(t, "none", loaded.DriverName)
+}
+
+func TestResolve(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	for _, p := range []*Profile{
+		{
+			Name:          "base",
+			DriverName:    "virtualbox",
+			DriverFlags:   map[string]interface{}{"virtualbox-memory": 1024, "virtualbox-cpu-count": 1},
+			EngineOptions: map[string]interface{}{"StorageDriver": "overlay2", "LogLevel": "info"},
+		},
+		{
+			Name:          "big",
+			Parent:        "base",
+			DriverFlags:   map[string]interface{}{"virtualbox-memory": 4096},
+			EngineOptions: map[string]interface{}{"LogLevel": "debug"},
+		},
+		{
+			Name:         "big-swarm",
+			Parent:       "big",
+			SwarmOptions: map[string]interface{}{"IsSwarm": true},
+		},
+		{
+			Name:        "cloud",
+			Parent:      "big",
+			DriverName:  "amazonec2",
+			DriverFlags: map[string]interface{}{"amazonec2-region": "eu-west-1"},
+		},
+	} {
+		assert.NoError(t, store.Save(p))
+	}
+
+	p, err := store.Resolve("big-swarm")
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{
+		Name:          "big-swarm",
+		DriverName:    "virtualbox",
+		DriverFlags:   map[string]interface{}{"virtualbox-memory": float64(4096), "virtualbox-cpu-count": float64(1)},
+		EngineOptions: map[string]interface{}{"StorageDriver": "overlay2", "LogLevel": "debug"},
+		SwarmOptions:  map[string]interface{}{"IsSwarm": true},
+	}, p)
+
+	p, err = store.Resolve("cloud")
+	assert.NoError(t, err)
+	assert.Equal(t, "amazonec2", p.DriverName)
+	assert.Equal(t, map[string]interface{}{"amazonec2-region": "eu-west-1"}, p.DriverFlags)
+	assert.Equal(t, "debug", p.EngineOptions["LogLevel"])
+}
+
+func TestResolveErrors(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	assert.NoError(t, store.Save(&Profile{Name: "a", Parent: "b"}))
+	assert.NoError(t, store.Save(&Profile{Name: "b", Parent: "a"}))
+	assert.NoError(t, store.Save(&Profile{Name: "orphan", Parent: "missing"}))
+
+	_, err := store.Resolve("a")
+	assert.EqualError(t, err, "Profiles inherit from each other: a -> b -> a")
+
+	_, err = store.Resolve("orphan")
+	assert.Equal(t, ErrProfileDoesNotExist{"missing"}, err)
+}
+
+func TestApplyOptions(t *testing.T) {
+	opts := &host.Options{
+		EngineOptions: &engine.Options{StorageDriver: "overlay2", TLSVerify: true},
+		SwarmOptions:  &swarm.Options{Image: "swarm:latest"},
+		AuthOptions:   &auth.Options{},
+	}
+
+	p := &Profile{
+		Name:          "test",
+		EngineOptions: map[string]interface{}{"LogLevel": "debug", "Dns": []interface{}{"8.8.8.8"}},
+		AuthOptions:   map[string]interface{}{"ServerCertSANs": []interface{}{"example.com"}},
+	}
+	assert.NoError(t, p.ApplyOptions(opts))
+	assert.Equal(t, &engine.Options{StorageDriver: "overlay2", TLSVerify: true, LogLevel: "debug", DNS: []string{"8.8.8.8"}}, opts.EngineOptions)
+	assert.Equal(t, &swarm.Options{Image: "swarm:latest"}, opts.SwarmOptions)
+	assert.Equal(t, []string{"example.com"}, opts.AuthOptions.ServerCertSANs)
+
+	p = &Profile{Name: "test", SwarmOptions: map[string]interface{}{"Imag": "swarm"}}
+	assert.EqualError(t, p.ApplyOptions(opts), `Profile test has invalid SwarmOptions: json: unknown field "Imag"`)
+}
+
+func TestFlagValues(t *testing.T) {
+	flags := []mcnflag.Flag{
+		mcnflag.IntFlag{Name: "memory", Value: 1024},
+		mcnflag.StringSliceFlag{Name: "tags"},
+		mcnflag.BoolFlag{Name: "nat"},
+		mcnflag.StringFlag{Name: "name", Value: "default"},
+	}
+
+	p := &Profile{
+		Name:        "test",
+		DriverName:  "fake",
+		DriverFlags: map[string]interface{}{"memory": float64(2048), "tags": []interface{}{"a", "b"}},
+	}
+
+	values, err := p.FlagValues(flags, map[string]interface{}{"name": "custom"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"memory": 2048,
+		"tags":   []string{"a", "b"},
+		"nat":    false,
+		"name":   "custom",
+	}, values)
+
+	_, err = p.FlagValues(flags, map[string]interface{}{"unknown": 1})
+	assert.EqualError(t, err, "Profile test sets flag unknown, which driver fake does not declare")
+}
+
+func TestFromHost(t *testing.T) {
+	h := &host.Host{
+		Name:       "dev",
+		DriverName: "virtualbox",
+		HostOptions: &host.Options{
+			EngineOptions: &engine.Options{StorageDriver: "overlay2"},
+			SwarmOptions:  &swarm.Options{Address: "192.168.99.100", Image: "swarm:latest"},
+			AuthOptions: &auth.Options{
+				CaCertPath:     "/certs/ca.pem",
+				ServerCertPath: "/machines/dev/server.pem",
+				StorePath:      "/machines/dev",
+			},
+			DriverFlags: map[string]interface{}{"virtualbox-memory": 2048},
+		},
+	}
+
+	p, err := FromHost("dev", h)
+	assert.NoError(t, err)
+	assert.Equal(t, "virtualbox", p.DriverName)
+	assert.Equal(t, map[string]interface{}{"virtualbox-memory": 2048}, p.DriverFlags)
+	assert.Equal(t, "overlay2", p.EngineOptions["StorageDriver"])
+	assert.Equal(t, "swarm:latest", p.SwarmOptions["Image"])
+	assert.NotContains(t, p.SwarmOptions, "Address")
+	assert.Equal(t, "/certs/ca.pem", p.AuthOptions["CaCertPath"])
+	assert.NotContains(t, p.AuthOptions, "ServerCertPath")
+	assert.NotContains(t, p.AuthOptions, "StorePath")
+}
@@ -0,0 +1,199 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/host"
+)
+
+type ErrProfileDoesNotExist struct {
+	Name string
+}
+
+func (e ErrProfileDoesNotExist) Error() string {
+	return fmt.Sprintf("Profile %q does not exist", e.Name)
+}
+
+type ErrProfileExists struct {
+	Name string
+}
+
+func (e ErrProfileExists) Error() string {
+	return fmt.Sprintf("Profile %q already exists", e.Name)
+}
+
+type ErrInvalidProfileName struct {
+	Name string
+}
+
+func (e ErrInvalidProfileName) Error() string {
+	return fmt.Sprintf("Invalid profile name %q. Allowed chars are: 0-9a-zA-Z . -", e.Name)
+}
+
+type ErrProfileCycle struct {
+	Names []string
+}
+
+func (e ErrProfileCycle) Error() string {
+	return fmt.Sprintf("Profiles inherit from each other: %s", strings.Join(e.Names, " -> "))
+}
+
+// Store keeps one <name>.json file per profile in a directory.
+type Store struct {
+	Path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		Path: path,
+	}
+}
+
+func (s *Store) profilePath(name string) (string, error) {
+	if !host.ValidateHostName(name) {
+		return "", ErrInvalidProfileName{name}
+	}
+
+	return filepath.Join(s.Path, name+".json"), nil
+}
+
+func (s *Store) Save(p *Profile) error {
+	return s.write(p, os.O_TRUNC)
+}
+
+// Create saves a new profile, failing with ErrProfileExists rather than
+// replacing an existing one.
+func (s *Store) Create(p *Profile) error {
+	err := s.write(p, os.O_EXCL)
+	if os.IsExist(err) {
+		return ErrProfileExists{p.Name}
+	}
+	return err
+}
+
+func (s *Store) write(p *Profile, flag int) error {
+	path, err := s.profilePath(p.Name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Load returns a profile as it is stored, without the settings it inherits,
+// see Resolve.
+func (s *Store) Load(name string) (*Profile, error) {
+	path, err := s.profilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrProfileDoesNotExist{name}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("Error loading profile %s: %s", name, err)
+	}
+	p.Name = name
+
+	return p, nil
+}
+
+// Resolve returns a profile along with the settings it inherits from its
+// parents.
+func (s *Store) Resolve(name string) (*Profile, error) {
+	p, err := s.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := []*Profile{p}
+	seen := map[string]bool{name: true}
+	for parentName := p.Parent; parentName != ""; parentName = chain[len(chain)-1].Parent {
+		if seen[parentName] {
+			names := []string{}
+			for _, p := range chain {
+				names = append(names, p.Name)
+			}
+			return nil, ErrProfileCycle{append(names, parentName)}
+		}
+		seen[parentName] = true
+
+		parent, err := s.Load(parentName)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, parent)
+	}
+
+	resolved := chain[len(chain)-1]
+	for i := len(chain) - 2; i >= 0; i-- {
+		resolved = chain[i].inherit(resolved)
+	}
+	resolved.Parent = ""
+
+	return resolved, nil
+}
+
+// List returns the names of the stored profiles, sorted.
+func (s *Store) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *Store) Remove(name string) error {
+	path, err := s.profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return ErrProfileDoesNotExist{name}
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,11 +1,14 @@
 package provision
 
 import (
+	"io"
+
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -27,6 +30,10 @@ func (fp *FakeProvisioner) SSHCommand(args string) (string, error) {
 	return "", nil
 }
 
+func (fp *FakeProvisioner) CopyToHost(src io.Reader, dst string, opts ssh.CopyOptions) error {
+	return nil
+}
+
 func (fp *FakeProvisioner) String() string {
 	return "fakeprovisioner"
 }
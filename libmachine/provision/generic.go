@@ -3,11 +3,13 @@ package provision
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -32,6 +34,10 @@ func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
 }
 
+func (sshCmder GenericSSHCommander) CopyToHost(src io.Reader, dst string, opts ssh.CopyOptions) error {
+	return drivers.CopyToHostFromDriver(sshCmder.Driver, src, dst, opts)
+}
+
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -111,7 +117,7 @@ DOCKER_OPTS='
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
 {{ end }}
 '
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.Env}}export {{ printf "%q" . }}
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -9,6 +10,7 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -24,6 +26,10 @@ const (
 type SSHCommander interface {
 	// Short-hand for accessing an SSH command from the driver.
 	SSHCommand(args string) (string, error)
+
+	// CopyToHost writes the content of src to the file dst on the host,
+	// without passing it through a shell command.
+	CopyToHost(src io.Reader, dst string, opts ssh.CopyOptions) error
 }
 
 type Detector interface {
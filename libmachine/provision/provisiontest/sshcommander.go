@@ -1,7 +1,13 @@
 // Package provisiontest provides utilities for testing provisioners
 package provisiontest
 
-import "errors"
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/machine/libmachine/ssh"
+)
 
 // FakeSSHCommanderOptions is intended to create a FakeSSHCommander without actually knowing the underlying sshcommands by passing it to NewSSHCommander
 type FakeSSHCommanderOptions struct {
@@ -13,6 +19,8 @@ type FakeSSHCommanderOptions struct {
 // Extend it when needed
 type FakeSSHCommander struct {
 	Responses map[string]string
+	// Files records the content of the files copied to the host, by path
+	Files map[string]string
 }
 
 // NewFakeSSHCommander creates a FakeSSHCommander without actually knowing the underlying sshcommands
@@ -37,3 +45,17 @@ func (sshCmder *FakeSSHCommander) SSHCommand(args string) (string, error) {
 	}
 	return response, nil
 }
+
+// CopyToHost is an implementation of provision.SSHCommander.CopyToHost recording the copied files in Files
+func (sshCmder *FakeSSHCommander) CopyToHost(src io.Reader, dst string, opts ssh.CopyOptions) error {
+	content, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	if sshCmder.Files == nil {
+		sshCmder.Files = map[string]string{}
+	}
+	sshCmder.Files[dst] = string(content)
+	return nil
+}
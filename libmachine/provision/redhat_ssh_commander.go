@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
@@ -43,3 +44,23 @@ output  : %s`, args, err, output)
 
 	return output, nil
 }
+
+func (sshCmder RedHatSSHCommander) CopyToHost(src io.Reader, dst string, opts ssh.CopyOptions) error {
+	if !opts.Sudo {
+		return drivers.CopyToHostFromDriver(sshCmder.Driver, src, dst, opts)
+	}
+
+	// sudo needs a tty, so the file is copied to a temporary file of the
+	// SSH user and then installed with SSHCommand.
+	tmp, err := ssh.RemoteTempPath()
+	if err != nil {
+		return err
+	}
+
+	if err := drivers.CopyToHostFromDriver(sshCmder.Driver, src, tmp, ssh.CopyOptions{Mode: 0600}); err != nil {
+		return err
+	}
+
+	_, err = sshCmder.SSHCommand(ssh.InstallCommand(tmp, dst, opts))
+	return err
+}
@@ -2,8 +2,8 @@ package provision
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -17,6 +17,7 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/ssh"
 )
 
 type DockerOptions struct {
@@ -123,38 +124,22 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 
-	// upload certs and configure TLS auth
-	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
-	if err != nil {
-		return err
-	}
-
-	serverCert, err := ioutil.ReadFile(authOptions.ServerCertPath)
-	if err != nil {
-		return err
-	}
-	serverKey, err := ioutil.ReadFile(authOptions.ServerKeyPath)
-	if err != nil {
-		return err
-	}
-
 	log.Info("Copying certs to the remote machine...")
 
-	// printf will choke if we don't pass a format string because of the
-	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
-
-	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
-		return err
-	}
-
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
-		return err
+	certs := []struct {
+		localPath  string
+		remotePath string
+		mode       os.FileMode
+	}{
+		{authOptions.CaCertPath, authOptions.CaCertRemotePath, 0644},
+		{authOptions.ServerCertPath, authOptions.ServerCertRemotePath, 0644},
+		{authOptions.ServerKeyPath, authOptions.ServerKeyRemotePath, 0600},
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
-		return err
+	for _, c := range certs {
+		if err := copyFileToHost(p, c.localPath, c.remotePath, ssh.CopyOptions{Mode: c.mode, Sudo: true}); err != nil {
+			return err
+		}
 	}
 
 	dockerURL, err := driver.GetURL()
@@ -182,7 +167,11 @@ func ConfigureAuth(p Provisioner) error {
 
 	log.Info("Setting Docker configuration on the remote daemon...")
 
-	if _, err = p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s \"%s\" | sudo tee %s", path.Dir(dkrcfg.EngineOptionsPath), dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
+	if _, err = p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", path.Dir(dkrcfg.EngineOptionsPath))); err != nil {
+		return err
+	}
+
+	if err := p.CopyToHost(strings.NewReader(dkrcfg.EngineOptions), dkrcfg.EngineOptionsPath, ssh.CopyOptions{Sudo: true}); err != nil {
 		return err
 	}
 
@@ -193,6 +182,16 @@ func ConfigureAuth(p Provisioner) error {
 	return WaitForDocker(p, dockerPort)
 }
 
+func copyFileToHost(p Provisioner, localPath, remotePath string, opts ssh.CopyOptions) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.CopyToHost(f, remotePath, opts)
+}
+
 func matchNetstatOut(reDaemonListening, netstatOut string) bool {
 	// TODO: I would really prefer this be a Scanner directly on
 	// the STDOUT of the executed command than to do all the string
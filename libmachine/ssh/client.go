@@ -33,12 +33,22 @@ type Client interface {
 	// Wait waits for the command started by the Start function to exit. The
 	// returned error follows the same logic as in the exec.Cmd.Wait function.
 	Wait() error
+
+	// CopyTo writes the content of src to the file dst on the host, see
+	// CopyOptions.
+	CopyTo(src io.Reader, dst string, opts CopyOptions) error
+
+	// CopyFrom writes the content of the file src on the host to dst.
+	CopyFrom(src string, dst io.Writer) error
 }
 
 type ExternalClient struct {
 	BaseArgs   []string
 	BinaryPath string
 	cmd        *exec.Cmd
+	user       string
+	host       string
+	scpArgs    []string
 }
 
 type NativeClient struct {
@@ -52,6 +62,10 @@ type NativeClient struct {
 type Auth struct {
 	Passwords []string
 	Keys      []string
+	// KnownHosts is the known_hosts file holding the key of the host. If
+	// set, the key is recorded there on the first connection and the host
+	// must present the same key on the next ones.
+	KnownHosts string
 }
 
 type ClientType string
@@ -117,6 +131,12 @@ func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
 		return nil, fmt.Errorf("Error getting config for native Go SSH: %s", err)
 	}
 
+	if auth.KnownHosts != "" {
+		if err := verifyHostKey(&config, host, port, auth.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+
 	return &NativeClient{
 		Config:   config,
 		Hostname: host,
@@ -154,20 +174,27 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	}, nil
 }
 
-func (client *NativeClient) dialSuccess() bool {
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
-	if err != nil {
-		log.Debugf("Error dialing TCP: %s", err)
-		return false
-	}
-	closeConn(conn)
-	return true
-}
-
 func (client *NativeClient) session() (*ssh.Client, *ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
+	// Waiting does not help if the host key changed.
+	var hostKeyErr error
+	if err := mcnutils.WaitFor(func() bool {
+		conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+		if err != nil {
+			log.Debugf("Error dialing TCP: %s", err)
+			if IsHostKeyMismatch(err) {
+				hostKeyErr = err
+				return true
+			}
+			return false
+		}
+		closeConn(conn)
+		return true
+	}); err != nil {
 		return nil, nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
 	}
+	if hostKeyErr != nil {
+		return nil, nil, hostKeyErr
+	}
 
 	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
 	if err != nil {
@@ -181,7 +208,7 @@ func (client *NativeClient) session() (*ssh.Client, *ssh.Session, error) {
 func (client *NativeClient) Output(command string) (string, error) {
 	conn, session, err := client.session()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	defer closeConn(conn)
 	defer session.Close()
@@ -194,7 +221,7 @@ func (client *NativeClient) Output(command string) (string, error) {
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	conn, session, err := client.session()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	defer closeConn(conn)
 	defer session.Close()
@@ -331,10 +358,12 @@ func (client *NativeClient) Shell(args ...string) error {
 func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (*ExternalClient, error) {
 	client := &ExternalClient{
 		BinaryPath: sshBinaryPath,
+		user:       user,
+		host:       host,
 	}
 
-	args := baseSSHArgs
-	args = append(args, fmt.Sprintf("%s@%s", user, host))
+	// The options shared by ssh and scp.
+	args := []string{}
 
 	// If no identities are explicitly provided, also look at the identities
 	// offered by ssh-agent
@@ -367,10 +396,24 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 		}
 	}
 
-	// Set which port to use for SSH.
-	args = append(args, "-p", fmt.Sprintf("%d", port))
+	sshArgs := baseSSHArgs
+	if auth.KnownHosts != "" {
+		acceptNew := sshSupportsAcceptNew(sshBinaryPath)
+		if !acceptNew {
+			if err := recordHostKey(user, host, port, auth.KnownHosts); err != nil {
+				return nil, err
+			}
+		}
+		sshArgs = knownHostsSSHArgs(auth.KnownHosts, acceptNew)
+	}
 
-	client.BaseArgs = args
+	client.BaseArgs = append(append([]string{}, sshArgs...), fmt.Sprintf("%s@%s", user, host))
+	client.BaseArgs = append(client.BaseArgs, args...)
+	client.scpArgs = append(append([]string{"-q"}, sshArgs...), args...)
+
+	// Set which port to use for SSH.
+	client.BaseArgs = append(client.BaseArgs, "-p", fmt.Sprintf("%d", port))
+	client.scpArgs = append(client.scpArgs, "-P", fmt.Sprintf("%d", port))
 
 	return client, nil
 }
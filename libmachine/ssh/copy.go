@@ -0,0 +1,256 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultCopyMode is the mode of the files written by CopyTo when
+// CopyOptions.Mode is not set.
+const DefaultCopyMode os.FileMode = 0644
+
+// CopyOptions tells how CopyTo writes a file on the host.
+type CopyOptions struct {
+	// Mode of the file, DefaultCopyMode if not set.
+	Mode os.FileMode
+	// Owner of the file, as user or user:group. The file belongs to the
+	// SSH user if not set.
+	Owner string
+	// Sudo writes the file as root, for paths the SSH user cannot write.
+	Sudo bool
+}
+
+// copyTo writes the content of src to a temporary file of the host with
+// upload, then installs it at dst with run. The content is never part of a
+// command, so it can be anything and is not logged.
+func copyTo(src io.Reader, dst string, opts CopyOptions, upload func(src io.Reader, path string) error, run func(command string) (string, error)) error {
+	tmp, err := RemoteTempPath()
+	if err != nil {
+		return err
+	}
+
+	if err := upload(src, tmp); err != nil {
+		return fmt.Errorf("Error copying %s to the host: %s", dst, err)
+	}
+
+	if output, err := run(InstallCommand(tmp, dst, opts)); err != nil {
+		return fmt.Errorf("Error installing %s on the host: %s: %s", dst, err, strings.TrimSpace(output))
+	}
+
+	return nil
+}
+
+// RemoteTempPath returns a path to upload a file to before it is installed,
+// only readable by the SSH user.
+func RemoteTempPath() (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return "/tmp/.docker-machine-copy-" + hex.EncodeToString(random), nil
+}
+
+// InstallCommand returns the shell command moving the uploaded file tmp to
+// dst, with the mode and owner of opts.
+func InstallCommand(tmp, dst string, opts CopyOptions) string {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = DefaultCopyMode
+	}
+
+	command := fmt.Sprintf("install -m %04o", mode.Perm())
+	if opts.Sudo {
+		command = "sudo " + command
+	}
+
+	if opts.Owner != "" {
+		parts := strings.SplitN(opts.Owner, ":", 2)
+		command += " -o " + shellQuote(parts[0])
+		if len(parts) == 2 && parts[1] != "" {
+			command += " -g " + shellQuote(parts[1])
+		}
+	}
+
+	return fmt.Sprintf("%s %s %s; status=$?; rm -f %s; exit $status", command, shellQuote(tmp), shellQuote(dst), shellQuote(tmp))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func (client *NativeClient) CopyTo(src io.Reader, dst string, opts CopyOptions) error {
+	upload := func(src io.Reader, path string) error {
+		return client.withSFTP(func(sftp *sftpClient) error {
+			return sftp.WriteFile(path, src, 0600)
+		})
+	}
+
+	return copyTo(src, dst, opts, upload, client.Output)
+}
+
+func (client *NativeClient) CopyFrom(src string, dst io.Writer) error {
+	return client.withSFTP(func(sftp *sftpClient) error {
+		return sftp.ReadFile(src, dst)
+	})
+}
+
+func (client *NativeClient) withSFTP(f func(*sftpClient) error) error {
+	conn, session, err := client.session()
+	if err != nil {
+		return err
+	}
+	defer closeConn(conn)
+
+	sftp, err := newSFTPClient(session)
+	if err != nil {
+		return err
+	}
+	defer sftp.Close()
+
+	return f(sftp)
+}
+
+func (client *PooledClient) CopyTo(src io.Reader, dst string, opts CopyOptions) error {
+	if client.external != nil {
+		return client.external.CopyTo(src, dst, opts)
+	}
+
+	upload := func(src io.Reader, path string) error {
+		err := client.withSFTP(func(sftp *sftpClient) error {
+			return sftp.WriteFile(path, src, 0600)
+		})
+		if err == errExternalFallback {
+			return client.external.upload(src, path)
+		}
+		return err
+	}
+
+	return copyTo(src, dst, opts, upload, client.Output)
+}
+
+func (client *PooledClient) CopyFrom(src string, dst io.Writer) error {
+	if client.external != nil {
+		return client.external.CopyFrom(src, dst)
+	}
+
+	err := client.withSFTP(func(sftp *sftpClient) error {
+		return sftp.ReadFile(src, dst)
+	})
+	if err == errExternalFallback {
+		return client.external.CopyFrom(src, dst)
+	}
+
+	return err
+}
+
+// errExternalFallback is returned by withSFTP when the client falls back to
+// the external client.
+var errExternalFallback = errors.New("Native Go SSH cannot authenticate")
+
+// withSFTP runs f with an SFTP client on the pooled connection.
+func (client *PooledClient) withSFTP(f func(*sftpClient) error) error {
+	conn, session, err := client.session()
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return errExternalFallback
+	}
+	defer client.pool.release(client.key, conn, false)
+
+	sftp, err := newSFTPClient(session)
+	if err != nil {
+		return err
+	}
+	defer sftp.Close()
+
+	return f(sftp)
+}
+
+func (client *ExternalClient) CopyTo(src io.Reader, dst string, opts CopyOptions) error {
+	return copyTo(src, dst, opts, client.upload, client.Output)
+}
+
+func (client *ExternalClient) upload(src io.Reader, path string) error {
+	f, err := ioutil.TempFile("", "docker-machine-copy-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return client.scp(f.Name(), client.remotePath(path))
+}
+
+func (client *ExternalClient) CopyFrom(src string, dst io.Writer) error {
+	f, err := ioutil.TempFile("", "docker-machine-copy-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := client.scp(client.remotePath(src), f.Name()); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+func (client *ExternalClient) remotePath(path string) string {
+	host := client.host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return fmt.Sprintf("%s@%s:%s", client.user, host, path)
+}
+
+func (client *ExternalClient) scp(src, dst string) error {
+	args := append(append([]string{}, client.scpArgs...), src, dst)
+	cmd := exec.Command(client.scpBinaryPath(), args...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// scpBinaryPath returns the scp binary installed along the ssh binary, or
+// the one found in the PATH.
+func (client *ExternalClient) scpBinaryPath() string {
+	name := "scp"
+	if runtime.GOOS == "windows" {
+		name = "scp.exe"
+	}
+
+	sibling := filepath.Join(filepath.Dir(client.BinaryPath), name)
+	if _, err := os.Stat(sibling); err == nil {
+		return sibling
+	}
+
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+
+	return name
+}
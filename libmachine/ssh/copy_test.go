@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/ssh/testserver"
+	"github.com/stretchr/testify/assert"
+)
+
+// A certificate-like content, with the characters which broke copying files
+// with printf.
+const copyContent = "-----BEGIN CERTIFICATE-----\n%s 'quoted' \"double\" $HOME `id` \\n\n-----END CERTIFICATE-----\n"
+
+func TestInstallCommand(t *testing.T) {
+	cases := []struct {
+		opts     CopyOptions
+		expected string
+	}{
+		{
+			opts:     CopyOptions{},
+			expected: `install -m 0644 '/tmp/x' '/etc/docker/ca.pem'; status=$?; rm -f '/tmp/x'; exit $status`,
+		},
+		{
+			opts:     CopyOptions{Mode: 0600, Owner: "root:docker", Sudo: true},
+			expected: `sudo install -m 0600 -o 'root' -g 'docker' '/tmp/x' '/etc/docker/ca.pem'; status=$?; rm -f '/tmp/x'; exit $status`,
+		},
+		{
+			opts:     CopyOptions{Owner: "docker"},
+			expected: `install -m 0644 -o 'docker' '/tmp/x' '/etc/docker/ca.pem'; status=$?; rm -f '/tmp/x'; exit $status`,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, InstallCommand("/tmp/x", "/etc/docker/ca.pem", c.opts))
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/etc/it'\''s here'`, shellQuote("/etc/it's here"))
+}
+
+func testCopy(t *testing.T, client Client) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "ca.pem")
+
+	err = client.CopyTo(strings.NewReader(copyContent), dst, CopyOptions{Mode: 0600})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, copyContent, string(content))
+
+	fi, err := os.Stat(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	var buf bytes.Buffer
+	assert.NoError(t, client.CopyFrom(dst, &buf))
+	assert.Equal(t, copyContent, buf.String())
+
+	assert.Error(t, client.CopyFrom(filepath.Join(dir, "missing"), &buf))
+	assert.Error(t, client.CopyTo(strings.NewReader(copyContent), filepath.Join(dir, "missing", "ca.pem"), CopyOptions{}))
+}
+
+func TestNativeClientCopy(t *testing.T) {
+	server, err := testserver.NewServer(testserver.ShellHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	testCopy(t, client)
+
+	for _, command := range server.Commands() {
+		assert.NotContains(t, command, "CERTIFICATE")
+	}
+}
+
+// openSSHSFTPServers are where distributions install the sftp-server of
+// OpenSSH.
+var openSSHSFTPServers = []string{
+	"/usr/lib/openssh/sftp-server",
+	"/usr/libexec/openssh/sftp-server",
+	"/usr/lib/ssh/sftp-server",
+	"/usr/libexec/sftp-server",
+}
+
+func TestNativeClientCopyWithOpenSSHSFTPServer(t *testing.T) {
+	sftpServer := ""
+	for _, path := range openSSHSFTPServers {
+		if _, err := os.Stat(path); err == nil {
+			sftpServer = path
+			break
+		}
+	}
+	if sftpServer == "" {
+		t.Skip("OpenSSH sftp-server not found")
+	}
+
+	server, err := testserver.NewUnstartedServer(testserver.ShellHandler)
+	assert.NoError(t, err)
+	server.SFTPServer = sftpServer
+	assert.NoError(t, server.Start())
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	testCopy(t, client)
+}
+
+func TestPooledClientCopy(t *testing.T) {
+	server, err := testserver.NewServer(testserver.ShellHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	testCopy(t, client)
+
+	assert.Equal(t, 1, server.Connections())
+}
+
+func TestExternalClientCopy(t *testing.T) {
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil {
+		t.Skip("ssh binary not found")
+	}
+	if _, err := exec.LookPath("scp"); err != nil {
+		t.Skip("scp binary not found")
+	}
+
+	server, err := testserver.NewServer(testserver.ShellHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	client, err := NewExternalClient(sshBinaryPath, "docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	testCopy(t, client)
+}
@@ -0,0 +1,315 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// knownHostsMutex serializes the reads and writes of known_hosts files,
+	// as several clients of the same machine can record its key at once.
+	knownHostsMutex sync.Mutex
+
+	// acceptNewSupport caches whether each ssh binary supports
+	// StrictHostKeyChecking=accept-new, see sshSupportsAcceptNew.
+	acceptNewSupport      = map[string]bool{}
+	acceptNewSupportMutex sync.Mutex
+
+	openSSHVersionRegex = regexp.MustCompile(`OpenSSH_(?:for_Windows_)?(\d+)\.(\d+)`)
+)
+
+// ErrHostKeyMismatch is returned when a host presents another key than the
+// one recorded in its known_hosts file.
+type ErrHostKeyMismatch struct {
+	Host        string
+	Fingerprint string
+	KnownHosts  string
+}
+
+func (e ErrHostKeyMismatch) Error() string {
+	return fmt.Sprintf("SSH host key of %s (%s) does not match the known host key in %s: if the machine was rebuilt, rotate its host key, otherwise someone may be intercepting the connection", e.Host, e.Fingerprint, e.KnownHosts)
+}
+
+// IsHostKeyMismatch tells whether err, possibly returned by a dial, is due
+// to a host key mismatch.
+func IsHostKeyMismatch(err error) bool {
+	if _, ok := err.(ErrHostKeyMismatch); ok {
+		return true
+	}
+
+	return err != nil && strings.Contains(err.Error(), "does not match the known host key")
+}
+
+// verifyHostKey makes config check the key of the host at host:port against
+// the known_hosts file knownHosts. The key is recorded in the file the first
+// time the host is seen.
+func verifyHostKey(config *ssh.ClientConfig, host string, port int, knownHosts string) error {
+	algorithms, err := knownHostKeyAlgorithms(knownHosts, net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+
+	// Only negotiate the types of the keys already known, otherwise a host
+	// with keys of several types would look like it changed its key.
+	config.HostKeyAlgorithms = algorithms
+	config.HostKeyCallback = trustOnFirstUse(knownHosts)
+
+	return nil
+}
+
+func trustOnFirstUse(knownHosts string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMutex.Lock()
+		defer knownHostsMutex.Unlock()
+
+		if err := ensureKnownHosts(knownHosts); err != nil {
+			return err
+		}
+
+		callback, err := knownhosts.New(knownHosts)
+		if err != nil {
+			return err
+		}
+
+		err = callback(hostname, remote, key)
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return ErrHostKeyMismatch{
+				Host:        hostname,
+				Fingerprint: ssh.FingerprintSHA256(key),
+				KnownHosts:  knownHosts,
+			}
+		}
+
+		log.Debugf("Recording SSH host key of %s (%s) in %s", hostname, ssh.FingerprintSHA256(key), knownHosts)
+
+		return appendKnownHost(knownHosts, hostname, key)
+	}
+}
+
+func ensureKnownHosts(knownHosts string) error {
+	if err := os.MkdirAll(filepath.Dir(knownHosts), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(knownHosts, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func appendKnownHost(knownHosts, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(knownHosts, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// knownHostKeyAlgorithms returns the types of the keys recorded for address
+// in knownHosts, or nil if there is none.
+func knownHostKeyAlgorithms(knownHosts, address string) ([]string, error) {
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	data, err := ioutil.ReadFile(knownHosts)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	address = knownhosts.Normalize(address)
+
+	var algorithms []string
+	for len(data) > 0 {
+		marker, hosts, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", knownHosts, err)
+		}
+		data = rest
+
+		if marker != "" {
+			continue
+		}
+		for _, host := range hosts {
+			if knownhosts.Normalize(host) == address {
+				algorithms = append(algorithms, key.Type())
+			}
+		}
+	}
+
+	return algorithms, nil
+}
+
+// ForgetKnownHosts removes the known_hosts file knownHosts, so that the key
+// presented by the host on the next connection is trusted and recorded again.
+func ForgetKnownHosts(knownHosts string) error {
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	if err := os.Remove(knownHosts); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// RotateKnownHosts replaces the key recorded in the known_hosts file
+// knownHosts with the one the host presents when connect runs, e.g. after
+// the host was rebuilt. The previous key is restored if connect fails.
+func RotateKnownHosts(knownHosts string, connect func() error) error {
+	backup := knownHosts + ".old"
+
+	knownHostsMutex.Lock()
+	if err := os.Rename(knownHosts, backup); err != nil {
+		if !os.IsNotExist(err) {
+			knownHostsMutex.Unlock()
+			return err
+		}
+		backup = ""
+	}
+	knownHostsMutex.Unlock()
+
+	// The key is recorded by connect, which takes knownHostsMutex itself.
+	err := connect()
+
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	if backup == "" {
+		return err
+	}
+
+	if err != nil {
+		if restoreErr := os.Rename(backup, knownHosts); restoreErr != nil {
+			log.Warnf("Error restoring the previous SSH host keys of %s: %s", knownHosts, restoreErr)
+		}
+		return err
+	}
+
+	return os.Remove(backup)
+}
+
+// supportsAcceptNew tells whether version, as printed by ssh -V, is OpenSSH
+// 7.6 or later, the first version supporting
+// StrictHostKeyChecking=accept-new.
+func supportsAcceptNew(version string) bool {
+	match := openSSHVersionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return false
+	}
+
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+
+	return major > 7 || (major == 7 && minor >= 6)
+}
+
+// sshSupportsAcceptNew tells whether the ssh binary at sshBinaryPath
+// supports StrictHostKeyChecking=accept-new. Its version is only checked
+// once.
+func sshSupportsAcceptNew(sshBinaryPath string) bool {
+	acceptNewSupportMutex.Lock()
+	defer acceptNewSupportMutex.Unlock()
+
+	if supported, ok := acceptNewSupport[sshBinaryPath]; ok {
+		return supported
+	}
+
+	output, err := exec.Command(sshBinaryPath, "-V").CombinedOutput()
+	supported := err == nil && supportsAcceptNew(string(output))
+	if !supported {
+		log.Debugf("%s does not support StrictHostKeyChecking=accept-new, SSH host keys are recorded by the native client: %s", sshBinaryPath, strings.TrimSpace(string(output)))
+	}
+	acceptNewSupport[sshBinaryPath] = supported
+
+	return supported
+}
+
+// recordHostKey records the key of the host at host:port in knownHosts
+// unless one is already known, for ssh binaries which cannot do it
+// themselves. The key is exchanged before authenticating, so the failure of
+// the authentication, or of the connection, is only logged.
+func recordHostKey(user string, host string, port int, knownHosts string) error {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	algorithms, err := knownHostKeyAlgorithms(knownHosts, address)
+	if err != nil {
+		return err
+	}
+	if len(algorithms) > 0 {
+		return nil
+	}
+
+	var keyErr error
+	trust := trustOnFirstUse(knownHosts)
+	config := &ssh.ClientConfig{
+		User: user,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			keyErr = trust(hostname, remote, key)
+			return keyErr
+		},
+		Timeout: poolDialTimeout,
+	}
+
+	client, err := ssh.Dial("tcp", address, config)
+	if err == nil {
+		client.Close()
+	} else if keyErr == nil {
+		log.Debugf("Connection to %s after recording its SSH host key: %s", address, err)
+	}
+
+	return keyErr
+}
+
+// knownHostsSSHArgs returns baseSSHArgs, making ssh verify host keys against
+// knownHosts. With acceptNew, ssh records the keys of new hosts itself,
+// otherwise they must be recorded first, see recordHostKey.
+func knownHostsSSHArgs(knownHosts string, acceptNew bool) []string {
+	strictHostKeyChecking := "StrictHostKeyChecking=yes"
+	if acceptNew {
+		strictHostKeyChecking = "StrictHostKeyChecking=accept-new"
+	}
+
+	args := append([]string{}, baseSSHArgs...)
+	for i, arg := range args {
+		switch arg {
+		case "StrictHostKeyChecking=no":
+			args[i] = strictHostKeyChecking
+		case "UserKnownHostsFile=/dev/null":
+			args[i] = fmt.Sprintf("UserKnownHostsFile=\"%s\"", knownHosts)
+		}
+	}
+
+	return args
+}
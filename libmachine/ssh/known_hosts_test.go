@@ -0,0 +1,227 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/ssh/testserver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newKnownHosts(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "machine", "known_hosts"), func() { os.RemoveAll(dir) }
+}
+
+func writeOtherHostKey(t *testing.T, knownHosts string, server *testserver.Server) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(knownHosts), 0700))
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.Addr())}, publicKey) + "\n"
+	assert.NoError(t, ioutil.WriteFile(knownHosts, []byte(line), 0600))
+}
+
+func TestNativeClientRecordsHostKey(t *testing.T) {
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		client, err := NewNativeClient("docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+		assert.NoError(t, err)
+
+		output, err := client.Output("uptime")
+		assert.NoError(t, err)
+		assert.Equal(t, "uptime", output)
+	}
+
+	content, err := ioutil.ReadFile(knownHosts)
+	assert.NoError(t, err)
+	assert.Equal(t, knownhosts.Line([]string{knownhosts.Normalize(server.Addr())}, server.HostKey.PublicKey())+"\n", string(content))
+}
+
+func TestNativeClientRejectsChangedHostKey(t *testing.T) {
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	writeOtherHostKey(t, knownHosts, server)
+
+	client, err := NewNativeClient("docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+
+	_, err = client.Output("uptime")
+	assert.True(t, IsHostKeyMismatch(err), "unexpected error: %v", err)
+	assert.Empty(t, server.Commands())
+}
+
+func TestPooledClientRejectsChangedHostKey(t *testing.T) {
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	writeOtherHostKey(t, knownHosts, server)
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+
+	_, err = client.Output("uptime")
+	assert.True(t, IsHostKeyMismatch(err), "unexpected error: %v", err)
+	assert.Nil(t, client.(*PooledClient).external)
+}
+
+func TestExternalClientVerifiesHostKey(t *testing.T) {
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil {
+		t.Skip("ssh binary not found")
+	}
+
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(knownHosts), 0700))
+
+	external, err := NewExternalClient(sshBinaryPath, "docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+
+	output, err := external.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+
+	// The key recorded by ssh is verified by the native client.
+	native, err := NewNativeClient("docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+
+	_, err = native.Output("uptime")
+	assert.NoError(t, err)
+
+	writeOtherHostKey(t, knownHosts, server)
+
+	_, err = external.Output("uptime")
+	assert.Error(t, err)
+	assert.Len(t, server.Commands(), 2)
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	algorithms, err := knownHostKeyAlgorithms(knownHosts, "192.168.99.100:22")
+	assert.NoError(t, err)
+	assert.Nil(t, algorithms)
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	writeOtherHostKey(t, knownHosts, server)
+
+	algorithms, err = knownHostKeyAlgorithms(knownHosts, server.Addr())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ecdsa-sha2-nistp256"}, algorithms)
+
+	algorithms, err = knownHostKeyAlgorithms(knownHosts, "192.168.99.100:22")
+	assert.NoError(t, err)
+	assert.Nil(t, algorithms)
+}
+
+func TestKnownHostsSSHArgs(t *testing.T) {
+	args := strings.Join(knownHostsSSHArgs("/machines/dev/known_hosts", true), " ")
+
+	assert.Contains(t, args, "-o StrictHostKeyChecking=accept-new")
+	assert.Contains(t, args, `-o UserKnownHostsFile="/machines/dev/known_hosts"`)
+	assert.NotContains(t, args, "UserKnownHostsFile=/dev/null")
+	assert.Contains(t, strings.Join(baseSSHArgs, " "), "StrictHostKeyChecking=no")
+
+	args = strings.Join(knownHostsSSHArgs("/machines/dev/known_hosts", false), " ")
+	assert.Contains(t, args, "-o StrictHostKeyChecking=yes")
+}
+
+func TestSupportsAcceptNew(t *testing.T) {
+	cases := map[string]bool{
+		"OpenSSH_9.2p1 Debian-2+deb12u7, OpenSSL 3.0.17 1 Jul 2025": true,
+		"OpenSSH_7.6p1 Ubuntu-4, OpenSSL 1.0.2n  7 Dec 2017":        true,
+		"OpenSSH_7.4p1, OpenSSL 1.0.2k-fips  26 Jan 2017":           false,
+		"OpenSSH_6.6.1p1 Ubuntu-2ubuntu2.13":                        false,
+		"OpenSSH_for_Windows_8.1p1, LibreSSL 3.0.2":                 true,
+		"usage: ssh [-46AaCfGgKkMNnqsTtVvXxYy]":                     false,
+	}
+
+	for version, expected := range cases {
+		assert.Equal(t, expected, supportsAcceptNew(version), version)
+	}
+}
+
+func TestExternalClientRecordsHostKeyWithoutAcceptNew(t *testing.T) {
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil {
+		t.Skip("ssh binary not found")
+	}
+
+	acceptNewSupportMutex.Lock()
+	acceptNewSupport[sshBinaryPath] = false
+	acceptNewSupportMutex.Unlock()
+	defer func() {
+		acceptNewSupportMutex.Lock()
+		delete(acceptNewSupport, sshBinaryPath)
+		acceptNewSupportMutex.Unlock()
+	}()
+
+	knownHosts, cleanup := newKnownHosts(t)
+	defer cleanup()
+
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	external, err := NewExternalClient(sshBinaryPath, "docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+	assert.Contains(t, external.BaseArgs, "StrictHostKeyChecking=yes")
+
+	algorithms, err := knownHostKeyAlgorithms(knownHosts, server.Addr())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{server.HostKey.PublicKey().Type()}, algorithms)
+
+	output, err := external.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+
+	writeOtherHostKey(t, knownHosts, server)
+
+	external, err = NewExternalClient(sshBinaryPath, "docker", server.Host, server.Port, &Auth{KnownHosts: knownHosts})
+	assert.NoError(t, err)
+	_, err = external.Output("uptime")
+	assert.Error(t, err)
+}
@@ -0,0 +1,362 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// DefaultPoolIdleTimeout is how long a Pool keeps a connection open
+	// after its last command.
+	DefaultPoolIdleTimeout = 30 * time.Second
+
+	poolDialTimeout = 10 * time.Second
+)
+
+// Pool keeps the connections of native SSH clients open, so that all the
+// commands run on a host with the same credentials go through a single
+// connection, each in its own session. A connection is closed once it has
+// not been used for IdleTimeout.
+type Pool struct {
+	IdleTimeout time.Duration
+
+	mutex sync.Mutex
+	conns map[string]*pooledConn
+	// external records the clients which fell back to the external
+	// client, so that the native one is not tried for each command.
+	external map[string]bool
+}
+
+type pooledConn struct {
+	client *ssh.Client
+	users  int
+	timer  *time.Timer
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		IdleTimeout: DefaultPoolIdleTimeout,
+		conns:       map[string]*pooledConn{},
+		external:    map[string]bool{},
+	}
+}
+
+// PooledClient is a Client running its commands on the connections of a
+// Pool. If the native client cannot authenticate, e.g. because the key is
+// only available from ssh-agent, it falls back to the external client.
+type PooledClient struct {
+	pool   *Pool
+	key    string
+	user   string
+	auth   *Auth
+	native NativeClient
+
+	external    *ExternalClient
+	openConn    *pooledConn
+	openSession *ssh.Session
+}
+
+// NewClient returns a client for host whose connection is shared with the
+// other clients of the pool for the same host and credentials.
+func (p *Pool) NewClient(user string, host string, port int, auth *Auth) (Client, error) {
+	client := &PooledClient{
+		pool: p,
+		key:  poolKey(user, host, port, auth),
+		user: user,
+		auth: auth,
+		native: NativeClient{
+			Hostname: host,
+			Port:     port,
+		},
+	}
+
+	p.mutex.Lock()
+	external := p.external[client.key]
+	p.mutex.Unlock()
+
+	if external {
+		if err := client.fallback(nil); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	config, err := NewNativeConfig(user, auth)
+	if err != nil {
+		if fallbackErr := client.fallback(err); fallbackErr != nil {
+			return nil, fmt.Errorf("Error getting config for native Go SSH: %s", err)
+		}
+		return client, nil
+	}
+	if config.Timeout == 0 {
+		config.Timeout = poolDialTimeout
+	}
+	if auth.KnownHosts != "" {
+		if err := verifyHostKey(&config, host, port, auth.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+	client.native.Config = config
+
+	return client, nil
+}
+
+// CloseIdle closes the connections which are not running a command.
+func (p *Pool) CloseIdle() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, conn := range p.conns {
+		if conn.users == 0 {
+			p.remove(key, conn)
+		}
+	}
+}
+
+// Close closes all the connections, including those running a command.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, conn := range p.conns {
+		p.remove(key, conn)
+	}
+}
+
+func poolKey(user string, host string, port int, auth *Auth) string {
+	return fmt.Sprintf("%s@%s keys=%q passwords=%q known_hosts=%q", user, net.JoinHostPort(host, strconv.Itoa(port)), auth.Keys, auth.Passwords, auth.KnownHosts)
+}
+
+// acquire returns the connection of a client, dialing it if needed. It must
+// be released once the command is done.
+func (p *Pool) acquire(client *PooledClient) (*pooledConn, error) {
+	p.mutex.Lock()
+	if conn, ok := p.conns[client.key]; ok {
+		conn.users++
+		if conn.timer != nil {
+			conn.timer.Stop()
+			conn.timer = nil
+		}
+		p.mutex.Unlock()
+		return conn, nil
+	}
+	p.mutex.Unlock()
+
+	log.Debugf("Opening SSH connection to %s@%s:%d", client.user, client.native.Hostname, client.native.Port)
+	sshClient, err := ssh.Dial("tcp", net.JoinHostPort(client.native.Hostname, strconv.Itoa(client.native.Port)), &client.native.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Another command may have dialed the host in the meantime.
+	if conn, ok := p.conns[client.key]; ok {
+		closeConn(sshClient)
+		conn.users++
+		if conn.timer != nil {
+			conn.timer.Stop()
+			conn.timer = nil
+		}
+		return conn, nil
+	}
+
+	conn := &pooledConn{client: sshClient, users: 1}
+	p.conns[client.key] = conn
+	return conn, nil
+}
+
+// release hands a connection back to the pool once a command is done. A
+// broken connection is closed right away, others after IdleTimeout.
+func (p *Pool) release(key string, conn *pooledConn, broken bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	conn.users--
+	if broken {
+		p.remove(key, conn)
+		return
+	}
+
+	if conn.users == 0 && p.conns[key] == conn {
+		conn.timer = time.AfterFunc(p.IdleTimeout, func() {
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+
+			if conn.users == 0 {
+				p.remove(key, conn)
+			}
+		})
+	}
+}
+
+// remove closes a connection. The caller must hold the mutex.
+func (p *Pool) remove(key string, conn *pooledConn) {
+	if p.conns[key] == conn {
+		delete(p.conns, key)
+	}
+	if conn.timer != nil {
+		conn.timer.Stop()
+		conn.timer = nil
+	}
+	closeConn(conn.client)
+}
+
+// session opens a session on the connection of a client. If the connection
+// was dropped, e.g. because the host rebooted, it is dialed again once.
+func (p *Pool) session(client *PooledClient) (*pooledConn, *ssh.Session, error) {
+	conn, err := p.acquire(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := conn.client.NewSession()
+	if err == nil {
+		return conn, session, nil
+	}
+
+	log.Debugf("Error opening SSH session on a pooled connection, dialing again: %s", err)
+	p.release(client.key, conn, true)
+
+	conn, err = p.acquire(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err = conn.client.NewSession()
+	if err != nil {
+		p.release(client.key, conn, true)
+		return nil, nil, err
+	}
+
+	return conn, session, nil
+}
+
+// fallback switches the client to the external client after the native one
+// failed with err. It fails if there is no ssh binary.
+func (client *PooledClient) fallback(err error) error {
+	sshBinaryPath, lookErr := exec.LookPath("ssh")
+	if lookErr != nil {
+		return lookErr
+	}
+
+	external, extErr := NewExternalClient(sshBinaryPath, client.user, client.native.Hostname, client.native.Port, client.auth)
+	if extErr != nil {
+		return extErr
+	}
+
+	if err != nil {
+		log.Debugf("Native Go SSH failed, using SSH client type: external: %s", err)
+	}
+
+	client.pool.mutex.Lock()
+	client.pool.external[client.key] = true
+	client.pool.mutex.Unlock()
+
+	client.external = external
+	return nil
+}
+
+// session opens a session for a command, falling back to the external
+// client if the native one cannot authenticate. It returns a nil session in
+// that case.
+func (client *PooledClient) session() (*pooledConn, *ssh.Session, error) {
+	conn, session, err := client.pool.session(client)
+	if err != nil && isAuthError(err) && client.fallback(err) == nil {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
+	}
+
+	return conn, session, nil
+}
+
+func isAuthError(err error) bool {
+	return strings.Contains(err.Error(), "unable to authenticate")
+}
+
+func (client *PooledClient) Output(command string) (string, error) {
+	if client.external != nil {
+		return client.external.Output(command)
+	}
+
+	conn, session, err := client.session()
+	if err != nil {
+		return "", err
+	}
+	if session == nil {
+		return client.external.Output(command)
+	}
+	defer client.pool.release(client.key, conn, false)
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+
+	return string(output), err
+}
+
+// Shell opens an interactive shell on a connection of its own, as it is
+// usually long-lived.
+func (client *PooledClient) Shell(args ...string) error {
+	if client.external != nil {
+		return client.external.Shell(args...)
+	}
+
+	return client.native.Shell(args...)
+}
+
+func (client *PooledClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	if client.external != nil {
+		return client.external.Start(command)
+	}
+
+	conn, session, err := client.session()
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil {
+		return client.external.Start(command)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err == nil {
+		var stderr io.Reader
+		if stderr, err = session.StderrPipe(); err == nil {
+			if err = session.Start(command); err == nil {
+				client.openConn = conn
+				client.openSession = session
+				return ioutil.NopCloser(stdout), ioutil.NopCloser(stderr), nil
+			}
+		}
+	}
+
+	_ = session.Close()
+	client.pool.release(client.key, conn, false)
+	return nil, nil, err
+}
+
+func (client *PooledClient) Wait() error {
+	if client.external != nil {
+		return client.external.Wait()
+	}
+
+	err := client.openSession.Wait()
+	_ = client.openSession.Close()
+	client.pool.release(client.key, client.openConn, false)
+
+	client.openSession = nil
+	client.openConn = nil
+	return err
+}
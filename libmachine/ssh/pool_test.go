@@ -0,0 +1,205 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/ssh/testserver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestServer(t testing.TB) *testserver.Server {
+	server, err := testserver.NewServer(testserver.EchoHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server
+}
+
+func TestPooledClientReusesConnection(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	for i := 0; i < 10; i++ {
+		client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+		assert.NoError(t, err)
+
+		output, err := client.Output(fmt.Sprintf("echo %d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("echo %d", i), output)
+	}
+
+	assert.Equal(t, 1, server.Connections())
+}
+
+func TestPooledClientStart(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	stdout, _, err := client.Start("uname")
+	assert.NoError(t, err)
+
+	output, err := ioutil.ReadAll(stdout)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Wait())
+	assert.Equal(t, "uname", string(output))
+
+	_, err = client.Output("uptime")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, server.Connections())
+}
+
+func TestPooledClientExitStatus(t *testing.T) {
+	server, err := testserver.NewServer(func(command string, stdin io.Reader, stdout, stderr io.Writer) uint32 {
+		fmt.Fprint(stderr, "not found")
+		return 127
+	})
+	assert.NoError(t, err)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	output, err := client.Output("missing")
+	assert.Equal(t, "not found", output)
+	assert.IsType(t, &ssh.ExitError{}, err)
+	assert.Equal(t, 127, err.(*ssh.ExitError).ExitStatus())
+}
+
+func TestPooledClientDialsAgainAfterDisconnection(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	_, err = client.Output("reboot")
+	assert.NoError(t, err)
+
+	server.CloseConnections()
+
+	output, err := client.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+	assert.Equal(t, 2, server.Connections())
+}
+
+func TestPoolClosesIdleConnections(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	pool := NewPool()
+	pool.IdleTimeout = 10 * time.Millisecond
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	assert.NoError(t, err)
+
+	_, err = client.Output("uptime")
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	pool.mutex.Lock()
+	assert.Empty(t, pool.conns)
+	pool.mutex.Unlock()
+
+	_, err = client.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.Connections())
+
+	pool.CloseIdle()
+
+	pool.mutex.Lock()
+	assert.Empty(t, pool.conns)
+	pool.mutex.Unlock()
+}
+
+func TestPooledClientFallsBackToExternalClient(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh binary not found")
+	}
+
+	server, err := testserver.NewUnstartedServer(testserver.EchoHandler)
+	assert.NoError(t, err)
+	server.Password = "secret"
+	assert.NoError(t, server.Start())
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	auth := &Auth{Passwords: []string{"wrong"}}
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, auth)
+	assert.NoError(t, err)
+
+	// The external client does not use passwords either, so the command
+	// still fails.
+	_, err = client.Output("uptime")
+	assert.Error(t, err)
+	assert.IsType(t, &ExternalClient{}, client.(*PooledClient).external)
+
+	client, err = pool.NewClient("docker", server.Host, server.Port, auth)
+	assert.NoError(t, err)
+	assert.IsType(t, &ExternalClient{}, client.(*PooledClient).external)
+}
+
+func BenchmarkNativeClientOutput(b *testing.B) {
+	server := newTestServer(b)
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", server.Host, server.Port, &Auth{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Output("uptime"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPooledClientOutput(b *testing.B) {
+	server := newTestServer(b)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+
+	client, err := pool.NewClient("docker", server.Host, server.Port, &Auth{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Output("uptime"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
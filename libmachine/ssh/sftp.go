@@ -0,0 +1,297 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The subset of the SFTP version 3 protocol used to read and write whole
+// files, see draft-ietf-secsh-filexfer-02.
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpWrite   = 6
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+
+	sftpOK  = 0
+	sftpEOF = 1
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+
+	sftpAttrPermissions = 0x04
+
+	// sftpChunkSize is the size of the reads and writes, which servers
+	// are required to accept.
+	sftpChunkSize = 32 * 1024
+)
+
+// SFTPError is a failure reported by an SFTP server.
+type SFTPError struct {
+	Code    uint32
+	Message string
+}
+
+func (e SFTPError) Error() string {
+	return fmt.Sprintf("sftp error %d: %s", e.Code, e.Message)
+}
+
+type sftpClient struct {
+	session *ssh.Session
+	w       io.WriteCloser
+	r       io.Reader
+	id      uint32
+}
+
+// newSFTPClient starts the sftp subsystem on session, which it closes when
+// it is closed.
+func newSFTPClient(session *ssh.Session) (*sftpClient, error) {
+	var err error
+
+	client := &sftpClient{session: session}
+	if client.w, err = session.StdinPipe(); err == nil {
+		if client.r, err = session.StdoutPipe(); err == nil {
+			err = session.RequestSubsystem("sftp")
+		}
+	}
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if err := client.writePacket(sftpInit, appendUint32(nil, 3)); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if packetType, _, err := client.readPacket(); err != nil || packetType != sftpVersion {
+		client.Close()
+		if err == nil {
+			err = fmt.Errorf("unexpected SFTP packet %d", packetType)
+		}
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (c *sftpClient) Close() error {
+	c.w.Close()
+	return c.session.Close()
+}
+
+// WriteFile creates, or truncates, the file path and writes the content of
+// src to it.
+func (c *sftpClient) WriteFile(path string, src io.Reader, mode os.FileMode) error {
+	payload := appendString(nil, path)
+	payload = appendUint32(payload, sftpFlagWrite|sftpFlagCreate|sftpFlagTrunc)
+	payload = appendUint32(payload, sftpAttrPermissions)
+	payload = appendUint32(payload, uint32(mode.Perm()))
+
+	handle, err := c.open(payload)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, sftpChunkSize)
+	offset := uint64(0)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			payload := appendString(nil, handle)
+			payload = appendUint64(payload, offset)
+			payload = appendString(payload, string(buf[:n]))
+
+			if err := c.expectStatus(c.request(sftpWrite, payload)); err != nil {
+				c.close(handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			c.close(handle)
+			return readErr
+		}
+	}
+
+	return c.close(handle)
+}
+
+// ReadFile writes the content of the file path to dst.
+func (c *sftpClient) ReadFile(path string, dst io.Writer) error {
+	payload := appendString(nil, path)
+	payload = appendUint32(payload, sftpFlagRead)
+	payload = appendUint32(payload, 0)
+
+	handle, err := c.open(payload)
+	if err != nil {
+		return err
+	}
+
+	offset := uint64(0)
+	for {
+		payload := appendString(nil, handle)
+		payload = appendUint64(payload, offset)
+		payload = appendUint32(payload, sftpChunkSize)
+
+		packetType, data, err := c.request(sftpRead, payload)
+		if err == nil && packetType == sftpStatus {
+			if err = statusError(data); err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = fmt.Errorf("unexpected SFTP status")
+			}
+		} else if err == nil && packetType != sftpData {
+			err = fmt.Errorf("unexpected SFTP packet %d", packetType)
+		}
+		if err != nil {
+			c.close(handle)
+			return err
+		}
+
+		chunk, _ := readString(data)
+		if _, err := dst.Write(chunk); err != nil {
+			c.close(handle)
+			return err
+		}
+		offset += uint64(len(chunk))
+	}
+
+	return c.close(handle)
+}
+
+func (c *sftpClient) open(payload []byte) (string, error) {
+	packetType, data, err := c.request(sftpOpen, payload)
+	if err != nil {
+		return "", err
+	}
+
+	switch packetType {
+	case sftpHandle:
+		handle, _ := readString(data)
+		return string(handle), nil
+	case sftpStatus:
+		if err := statusError(data); err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("unexpected SFTP packet %d", packetType)
+}
+
+func (c *sftpClient) close(handle string) error {
+	return c.expectStatus(c.request(sftpClose, appendString(nil, handle)))
+}
+
+func (c *sftpClient) expectStatus(packetType byte, data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if packetType != sftpStatus {
+		return fmt.Errorf("unexpected SFTP packet %d", packetType)
+	}
+
+	return statusError(data)
+}
+
+// request sends a request and returns the type and the payload, after the
+// request id, of the response.
+func (c *sftpClient) request(packetType byte, payload []byte) (byte, []byte, error) {
+	c.id++
+	if err := c.writePacket(packetType, append(appendUint32(nil, c.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+
+	responseType, data, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != c.id {
+		return 0, nil, fmt.Errorf("unexpected SFTP response id")
+	}
+
+	return responseType, data[4:], nil
+}
+
+func (c *sftpClient) writePacket(packetType byte, payload []byte) error {
+	packet := appendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, packetType)
+
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+func (c *sftpClient) readPacket() (byte, []byte, error) {
+	var length uint32
+	if err := binary.Read(c.r, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+	if length == 0 {
+		return 0, nil, fmt.Errorf("empty SFTP packet")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return data[0], data[1:], nil
+}
+
+// statusError returns the error reported by a status response, io.EOF at the
+// end of a file, or nil.
+func statusError(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("short SFTP status")
+	}
+
+	code := binary.BigEndian.Uint32(data)
+	message, _ := readString(data[4:])
+	switch code {
+	case sftpOK:
+		return nil
+	case sftpEOF:
+		return io.EOF
+	}
+
+	return SFTPError{Code: code, Message: string(message)}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func readString(data []byte) ([]byte, []byte) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return nil, nil
+	}
+
+	return data[4 : 4+n], data[4+n:]
+}
@@ -1,6 +1,13 @@
 package sshtest
 
-import "io"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/machine/libmachine/ssh"
+)
 
 type CmdResult struct {
 	Out string
@@ -10,6 +17,8 @@ type CmdResult struct {
 type FakeClient struct {
 	ActivatedShell []string
 	Outputs        map[string]CmdResult
+	Files          map[string]string
+	FileOptions    map[string]ssh.CopyOptions
 }
 
 func (fsc *FakeClient) Output(command string) (string, error) {
@@ -29,3 +38,28 @@ func (fsc *FakeClient) Start(command string) (io.ReadCloser, io.ReadCloser, erro
 func (fsc *FakeClient) Wait() error {
 	return nil
 }
+
+func (fsc *FakeClient) CopyTo(src io.Reader, dst string, opts ssh.CopyOptions) error {
+	content, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	if fsc.Files == nil {
+		fsc.Files = map[string]string{}
+		fsc.FileOptions = map[string]ssh.CopyOptions{}
+	}
+	fsc.Files[dst] = string(content)
+	fsc.FileOptions[dst] = opts
+	return nil
+}
+
+func (fsc *FakeClient) CopyFrom(src string, dst io.Writer) error {
+	content, ok := fsc.Files[src]
+	if !ok {
+		return fmt.Errorf("no such file: %s", src)
+	}
+
+	_, err := io.Copy(dst, bytes.NewBufferString(content))
+	return err
+}
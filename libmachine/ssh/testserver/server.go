@@ -0,0 +1,283 @@
+// Package testserver provides an SSH server to test SSH clients against
+package testserver
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Handler runs a command sent by a client of a Server and returns its exit
+// status.
+type Handler func(command string, stdin io.Reader, stdout, stderr io.Writer) uint32
+
+// EchoHandler writes the command it is given to stdout.
+func EchoHandler(command string, stdin io.Reader, stdout, stderr io.Writer) uint32 {
+	fmt.Fprint(stdout, command)
+	return 0
+}
+
+// Server is an SSH server listening on localhost, running the commands of
+// its clients with a Handler and serving the local filesystem over SFTP. It
+// is meant for testing SSH clients.
+type Server struct {
+	Host    string
+	Port    int
+	HostKey ssh.Signer
+	Handler Handler
+
+	// Password and AuthorizedKey are the credentials accepted by the
+	// server. If neither is set, clients are accepted without
+	// authentication.
+	Password      string
+	AuthorizedKey ssh.PublicKey
+
+	// SFTPServer is the path of an sftp-server binary, e.g. OpenSSH's,
+	// run for the sftp subsystem instead of ServeSFTP.
+	SFTPServer string
+
+	listener    net.Listener
+	mutex       sync.Mutex
+	conns       map[net.Conn]bool
+	connections int
+	commands    []string
+	wg          sync.WaitGroup
+}
+
+// NewServer starts a Server running commands with handler.
+func NewServer(handler Handler) (*Server, error) {
+	s, err := NewUnstartedServer(handler)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, s.Start()
+}
+
+// NewUnstartedServer returns a Server which accepts connections once Start
+// is called, so that its credentials can be set.
+func NewUnstartedServer(handler Handler) (*Server, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		HostKey: hostKey,
+		Handler: handler,
+		conns:   map[net.Conn]bool{},
+	}, nil
+}
+
+// Start listens on a random port of localhost.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+	s.Host = host
+	s.Port, _ = strconv.Atoi(port)
+
+	config := &ssh.ServerConfig{
+		NoClientAuth: s.Password == "" && s.AuthorizedKey == nil,
+	}
+	if s.Password != "" {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != s.Password {
+				return nil, fmt.Errorf("wrong password for %s", conn.User())
+			}
+			return nil, nil
+		}
+	}
+	if s.AuthorizedKey != nil {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), s.AuthorizedKey.Marshal()) {
+				return nil, fmt.Errorf("unknown public key for %s", conn.User())
+			}
+			return nil, nil
+		}
+	}
+	config.AddHostKey(s.HostKey)
+
+	s.wg.Add(1)
+	go s.serve(config)
+
+	return nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Connections returns how many connections the server accepted.
+func (s *Server) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.connections
+}
+
+// OpenConnections returns how many connections are open.
+func (s *Server) OpenConnections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.conns)
+}
+
+// Commands returns the commands run by the server, in order.
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.commands...)
+}
+
+// CloseConnections drops the open connections, as if the host rebooted.
+func (s *Server) CloseConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and drops its connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) serve(config *ssh.ServerConfig) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.connections++
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn, config)
+
+			s.mutex.Lock()
+			delete(s.conns, conn)
+			s.mutex.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleSession(channel, requests)
+		}()
+	}
+}
+
+func (s *Server) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			s.mutex.Lock()
+			s.commands = append(s.commands, payload.Command)
+			s.mutex.Unlock()
+
+			status := s.Handler(payload.Command, channel, channel, channel.Stderr())
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			status := uint32(0)
+			if err := s.serveSFTP(channel); err != nil {
+				status = 1
+			}
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "env", "pty-req":
+			req.Reply(true, nil)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *Server) serveSFTP(channel ssh.Channel) error {
+	if s.SFTPServer == "" {
+		return ServeSFTP(channel)
+	}
+
+	cmd := exec.Command(s.SFTPServer)
+	cmd.Stdin = channel
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+	return cmd.Run()
+}
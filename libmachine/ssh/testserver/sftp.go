@@ -0,0 +1,339 @@
+package testserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ShellHandler runs commands with the local sh, so that clients of a Server
+// act on the files of the test machine.
+func ShellHandler(command string, stdin io.Reader, stdout, stderr io.Writer) uint32 {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return uint32(exitErr.ExitCode())
+		}
+		fmt.Fprint(stderr, err)
+		return 255
+	}
+
+	return 0
+}
+
+// The SFTP version 3 packets and status codes served by a Server, see
+// draft-ietf-secsh-filexfer-02.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+	sftpFailure          = 4
+	sftpOpUnsupported    = 8
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagAppend = 0x04
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+	sftpFlagExcl   = 0x20
+
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrACModTime   = 0x08
+	sftpAttrExtended    = 0x80000000
+)
+
+var errShortPacket = errors.New("short SFTP packet")
+
+type sftpPacket []byte
+
+func (p *sftpPacket) uint32() uint32 {
+	if len(*p) < 4 {
+		*p = nil
+		return 0
+	}
+	v := binary.BigEndian.Uint32(*p)
+	*p = (*p)[4:]
+	return v
+}
+
+func (p *sftpPacket) uint64() uint64 {
+	return uint64(p.uint32())<<32 | uint64(p.uint32())
+}
+
+func (p *sftpPacket) string() string {
+	n := int(p.uint32())
+	if len(*p) < n {
+		*p = nil
+		return ""
+	}
+	s := string((*p)[:n])
+	*p = (*p)[n:]
+	return s
+}
+
+// attrs reads file attributes, returning the permissions if they are set.
+func (p *sftpPacket) attrs() (os.FileMode, bool) {
+	flags := p.uint32()
+	if flags&sftpAttrSize != 0 {
+		p.uint64()
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		p.uint32()
+		p.uint32()
+	}
+	var perm uint32
+	if flags&sftpAttrPermissions != 0 {
+		perm = p.uint32()
+	}
+	if flags&sftpAttrACModTime != 0 {
+		p.uint32()
+		p.uint32()
+	}
+	if flags&sftpAttrExtended != 0 {
+		for n := p.uint32(); n > 0; n-- {
+			p.string()
+			p.string()
+		}
+	}
+
+	return os.FileMode(perm).Perm(), flags&sftpAttrPermissions != 0
+}
+
+type sftpResponse []byte
+
+func newSFTPResponse(packetType byte, id uint32) *sftpResponse {
+	r := sftpResponse{packetType}
+	r.uint32(id)
+	return &r
+}
+
+func (r *sftpResponse) uint32(v uint32) *sftpResponse {
+	*r = append(*r, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return r
+}
+
+func (r *sftpResponse) uint64(v uint64) *sftpResponse {
+	return r.uint32(uint32(v >> 32)).uint32(uint32(v))
+}
+
+func (r *sftpResponse) string(s string) *sftpResponse {
+	r.uint32(uint32(len(s)))
+	*r = append(*r, s...)
+	return r
+}
+
+func (r *sftpResponse) attrs(fi os.FileInfo) *sftpResponse {
+	mode := uint32(fi.Mode().Perm())
+	switch {
+	case fi.IsDir():
+		mode |= 0040000
+	case fi.Mode().IsRegular():
+		mode |= 0100000
+	}
+
+	return r.uint32(sftpAttrSize | sftpAttrPermissions | sftpAttrACModTime).
+		uint64(uint64(fi.Size())).
+		uint32(mode).
+		uint32(uint32(fi.ModTime().Unix())).
+		uint32(uint32(fi.ModTime().Unix()))
+}
+
+func (r *sftpResponse) status(err error) *sftpResponse {
+	code := uint32(sftpOK)
+	message := "OK"
+	switch {
+	case err == nil:
+	case err == io.EOF:
+		code, message = sftpEOF, "EOF"
+	case os.IsNotExist(err):
+		code, message = sftpNoSuchFile, err.Error()
+	case os.IsPermission(err):
+		code, message = sftpPermissionDenied, err.Error()
+	default:
+		code, message = sftpFailure, err.Error()
+	}
+
+	return r.uint32(code).string(message).string("")
+}
+
+// ServeSFTP serves the files of the local filesystem with the SFTP version
+// 3 protocol over rw, until it is closed.
+func ServeSFTP(rw io.ReadWriter) error {
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for {
+		var length uint32
+		if err := binary.Read(rw, binary.BigEndian, &length); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(rw, data); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errShortPacket
+		}
+
+		packetType, p := data[0], sftpPacket(data[1:])
+		if packetType == sftpInit {
+			response := sftpResponse{sftpVersion}
+			if err := writeSFTPResponse(rw, *response.uint32(3)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id := p.uint32()
+		response := serveSFTPRequest(files, packetType, id, &p)
+		if err := writeSFTPResponse(rw, *response); err != nil {
+			return err
+		}
+	}
+}
+
+func serveSFTPRequest(files map[string]*os.File, packetType byte, id uint32, p *sftpPacket) *sftpResponse {
+	switch packetType {
+	case sftpOpen:
+		path, pflags := p.string(), p.uint32()
+		perm, ok := p.attrs()
+		if !ok {
+			perm = 0644
+		}
+
+		flags := 0
+		switch {
+		case pflags&sftpFlagRead != 0 && pflags&sftpFlagWrite != 0:
+			flags = os.O_RDWR
+		case pflags&sftpFlagWrite != 0:
+			flags = os.O_WRONLY
+		}
+		for pflag, flag := range map[uint32]int{sftpFlagAppend: os.O_APPEND, sftpFlagCreate: os.O_CREATE, sftpFlagTrunc: os.O_TRUNC, sftpFlagExcl: os.O_EXCL} {
+			if pflags&pflag != 0 {
+				flags |= flag
+			}
+		}
+
+		f, err := os.OpenFile(path, flags, perm)
+		if err != nil {
+			return newSFTPResponse(sftpStatus, id).status(err)
+		}
+		handle := strconv.Itoa(len(files)) + ":" + path
+		files[handle] = f
+		return newSFTPResponse(sftpHandle, id).string(handle)
+	case sftpClose:
+		handle := p.string()
+		f, ok := files[handle]
+		if !ok {
+			return newSFTPResponse(sftpStatus, id).status(os.ErrInvalid)
+		}
+		delete(files, handle)
+		return newSFTPResponse(sftpStatus, id).status(f.Close())
+	case sftpRead:
+		f, offset, length := files[p.string()], p.uint64(), p.uint32()
+		if f == nil {
+			return newSFTPResponse(sftpStatus, id).status(os.ErrInvalid)
+		}
+		data := make([]byte, length)
+		n, err := f.ReadAt(data, int64(offset))
+		if n == 0 && err != nil {
+			return newSFTPResponse(sftpStatus, id).status(err)
+		}
+		return newSFTPResponse(sftpData, id).string(string(data[:n]))
+	case sftpWrite:
+		f, offset, data := files[p.string()], p.uint64(), p.string()
+		if f == nil {
+			return newSFTPResponse(sftpStatus, id).status(os.ErrInvalid)
+		}
+		_, err := f.WriteAt([]byte(data), int64(offset))
+		return newSFTPResponse(sftpStatus, id).status(err)
+	case sftpStat, sftpLstat:
+		fi, err := os.Stat(p.string())
+		if err != nil {
+			return newSFTPResponse(sftpStatus, id).status(err)
+		}
+		return newSFTPResponse(sftpAttrs, id).attrs(fi)
+	case sftpFstat:
+		f := files[p.string()]
+		if f == nil {
+			return newSFTPResponse(sftpStatus, id).status(os.ErrInvalid)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return newSFTPResponse(sftpStatus, id).status(err)
+		}
+		return newSFTPResponse(sftpAttrs, id).attrs(fi)
+	case sftpSetstat:
+		path := p.string()
+		var err error
+		if perm, ok := p.attrs(); ok {
+			err = os.Chmod(path, perm)
+		}
+		return newSFTPResponse(sftpStatus, id).status(err)
+	case sftpFsetstat:
+		f := files[p.string()]
+		if f == nil {
+			return newSFTPResponse(sftpStatus, id).status(os.ErrInvalid)
+		}
+		var err error
+		if perm, ok := p.attrs(); ok {
+			err = f.Chmod(perm)
+		}
+		return newSFTPResponse(sftpStatus, id).status(err)
+	case sftpRealpath:
+		path, err := filepath.Abs(p.string())
+		if err != nil {
+			return newSFTPResponse(sftpStatus, id).status(err)
+		}
+		return newSFTPResponse(sftpName, id).uint32(1).string(path).string(path).uint32(0)
+	}
+
+	response := newSFTPResponse(sftpStatus, id)
+	return response.uint32(sftpOpUnsupported).string("unsupported operation").string("")
+}
+
+func writeSFTPResponse(w io.Writer, response sftpResponse) error {
+	data := make([]byte, 4, 4+len(response))
+	binary.BigEndian.PutUint32(data, uint32(len(response)))
+
+	_, err := w.Write(append(data, response...))
+	return err
+}
@@ -0,0 +1,54 @@
+package testserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeSFTPWithOpenSSHClient(t *testing.T) {
+	sftpPath, err := exec.LookPath("sftp")
+	if err != nil {
+		t.Skip("OpenSSH sftp client not found")
+	}
+
+	server, err := NewServer(ShellHandler)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := "-----BEGIN CERTIFICATE-----\nsome 'quoted' content\n-----END CERTIFICATE-----\n"
+	src := filepath.Join(dir, "src.pem")
+	assert.NoError(t, ioutil.WriteFile(src, []byte(content), 0600))
+
+	uploaded := filepath.Join(dir, "uploaded.pem")
+	downloaded := filepath.Join(dir, "downloaded.pem")
+	batch := filepath.Join(dir, "batch")
+	assert.NoError(t, ioutil.WriteFile(batch, []byte(fmt.Sprintf("put %s %s\nget %s %s\n", src, uploaded, uploaded, downloaded)), 0600))
+
+	out, err := exec.Command(sftpPath,
+		"-b", batch,
+		"-P", strconv.Itoa(server.Port),
+		"-F", "/dev/null",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"docker@"+server.Host,
+	).CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	for _, path := range []string{uploaded, downloaded} {
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+}